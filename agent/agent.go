@@ -1,7 +1,6 @@
 package agent
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -19,11 +18,16 @@ import (
 	"github.com/mitchellh/go-homedir"
 	"github.com/opentracing/opentracing-go"
 
+	"go.undefinedlabs.com/scopeagent/agent/ingest"
 	"go.undefinedlabs.com/scopeagent/env"
 	scopeError "go.undefinedlabs.com/scopeagent/errors"
 	"go.undefinedlabs.com/scopeagent/instrumentation"
 	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
 	"go.undefinedlabs.com/scopeagent/reflection"
+	"go.undefinedlabs.com/scopeagent/reporters/console"
+	"go.undefinedlabs.com/scopeagent/reporters/html"
+	"go.undefinedlabs.com/scopeagent/reporters/junit"
 	"go.undefinedlabs.com/scopeagent/runner"
 	"go.undefinedlabs.com/scopeagent/tags"
 	"go.undefinedlabs.com/scopeagent/tracer"
@@ -44,6 +48,20 @@ type (
 		setGlobalTracer  bool
 		panicAsFail      bool
 		failRetriesCount int
+		retriesOverrides map[string]int
+		retryDelay       time.Duration
+		retryBackoff     float64
+		retryOn          []runner.FailureKind
+		maxTotalRetries  int
+		testTimeout      time.Duration
+		testTimeouts     map[string]time.Duration
+
+		leakCheck      bool
+		leakCheckGrace time.Duration
+		leakCheckFail  bool
+
+		resourceMetricsDisabled bool
+		parallelCoverage        bool
 
 		recorder         *SpanRecorder
 		recorderFilename string
@@ -51,6 +69,52 @@ type (
 
 		optionalRecorders []tracer.SpanRecorder
 
+		spoolEnabled bool
+		spoolPath    string
+
+		offlineExportPath string
+
+		junitReportPath string
+		htmlReportPath  string
+		consoleReport   bool
+		localReportMode bool
+
+		coverageExportPath    string
+		coverageExportPerTest bool
+
+		testImpactEnabled bool
+		testImpactBaseRef string
+		testImpact        *testImpact
+
+		benchmarkBaselineEnabled bool
+		benchmarkBaseRef         string
+		benchmarkThreshold       float64
+		benchmarkFailRun         bool
+		benchmarkBaseline        *benchmarkBaseline
+
+		quarantineEnabled bool
+		quarantineFile    string
+		flakyThreshold    float64
+		testHistory       *testHistory
+
+		shardIndex           int
+		shardTotal           int
+		shardDurationsFile   string
+		shardDurationsExport string
+
+		shuffle         bool
+		shuffleSeed     int64
+		shuffleSubTests bool
+
+		propagationFormats []tracer.PropagationFormat
+
+		sampler              tracer.Sampler
+		samplingRate         float64
+		samplingRateSet      bool
+		samplingRateLimit    float64
+		samplingRateLimitSet bool
+		samplerConfig        *agentSampler
+
 		userAgent string
 		agentType string
 
@@ -168,6 +232,74 @@ func WithRetriesOnFail(retriesCount int) Option {
 	}
 }
 
+// Sets the retries by package or by test (`{package}.{test name}`), overrides the global retries count
+func WithRetriesOverrides(retries map[string]int) Option {
+	return func(agent *Agent) {
+		agent.retriesOverrides = retries
+	}
+}
+
+// Sets the delay before the first retry of a failed test, the delay is multiplied by the backoff in the next retries
+func WithRetryDelay(delay time.Duration, backoff float64) Option {
+	return func(agent *Agent) {
+		agent.retryDelay = delay
+		agent.retryBackoff = backoff
+	}
+}
+
+// Retries only the failures of the given kinds
+func WithRetryOn(kinds ...runner.FailureKind) Option {
+	return func(agent *Agent) {
+		agent.retryOn = kinds
+	}
+}
+
+// Sets the max number of retries of all the tests in the run
+func WithMaxTotalRetries(retries int) Option {
+	return func(agent *Agent) {
+		agent.maxTotalRetries = retries
+	}
+}
+
+// Sets the max duration of each test run, the goroutines stacks are reported in the test span when a test times out
+func WithTestTimeout(timeout time.Duration) Option {
+	return func(agent *Agent) {
+		agent.testTimeout = timeout
+	}
+}
+
+// Sets the timeouts by package or by test (`{package}.{test name}`), overrides the global test timeout
+func WithTestTimeouts(timeouts map[string]time.Duration) Option {
+	return func(agent *Agent) {
+		agent.testTimeouts = timeouts
+	}
+}
+
+// Enables the goroutine leak check, the goroutines started by a test and still running after the grace period are
+// reported as a warning in the test span, and the test fails if failTest is true
+func WithGoroutineLeakCheck(grace time.Duration, failTest bool) Option {
+	return func(agent *Agent) {
+		agent.leakCheck = true
+		agent.leakCheckGrace = grace
+		agent.leakCheckFail = failTest
+	}
+}
+
+// Disables the resource usage metrics (CPU time, memory allocations, GC, goroutines and open files) of the test spans
+func WithoutResourceMetrics() Option {
+	return func(agent *Agent) {
+		agent.resourceMetricsDisabled = true
+	}
+}
+
+// Enables the per test coverage of the parallel tests (`t.Parallel()`), when the coverage is enabled the parallel
+// tests are run sequentially so the coverage counters can be attributed to each test
+func WithParallelTestsCoverage() Option {
+	return func(agent *Agent) {
+		agent.parallelCoverage = true
+	}
+}
+
 func WithHandlePanicAsFail() Option {
 	return func(agent *Agent) {
 		agent.panicAsFail = true
@@ -180,6 +312,185 @@ func WithRecorders(recorders ...tracer.SpanRecorder) Option {
 	}
 }
 
+func WithSpoolEnabled() Option {
+	return func(agent *Agent) {
+		agent.spoolEnabled = true
+	}
+}
+
+func WithSpoolPath(path string) Option {
+	return func(agent *Agent) {
+		agent.spoolEnabled = true
+		agent.spoolPath = path
+	}
+}
+
+// Writes the ingest payloads to files in the given path instead of sending them to Scope
+func WithOfflineExport(path string) Option {
+	return func(agent *Agent) {
+		agent.offlineExportPath = path
+	}
+}
+
+// Sets the formats used to propagate the span context in http headers and grpc metadata
+func WithPropagationFormats(formats ...tracer.PropagationFormat) Option {
+	return func(agent *Agent) {
+		agent.propagationFormats = formats
+	}
+}
+
+// Sets a custom sampler for the root spans, test spans are always sampled and child spans follow the root span decision
+func WithSampler(sampler tracer.Sampler) Option {
+	return func(agent *Agent) {
+		agent.sampler = sampler
+	}
+}
+
+// Sets the ratio (0 to 1) of traces sampled
+func WithSamplingRate(rate float64) Option {
+	return func(agent *Agent) {
+		agent.samplingRate = rate
+		agent.samplingRateSet = true
+	}
+}
+
+// Sets the max number of traces sampled per second and operation
+func WithSamplingRateLimit(tracesPerSecond float64) Option {
+	return func(agent *Agent) {
+		agent.samplingRateLimit = tracesPerSecond
+		agent.samplingRateLimitSet = true
+	}
+}
+
+// Writes a JUnit XML report of the test results to the given path when the agent stops
+func WithJUnitReport(path string) Option {
+	return func(agent *Agent) {
+		agent.junitReportPath = path
+	}
+}
+
+// Writes a self-contained HTML report of the test results (with the logs, failures and child spans of each test)
+// to the given path when the agent stops
+func WithHTMLReport(path string) Option {
+	return func(agent *Agent) {
+		agent.htmlReportPath = path
+	}
+}
+
+// Prints the progress of the tests and a summary of the results (failed, flaky, skipped, cached and slowest tests)
+// to the standard output
+func WithConsoleReport() Option {
+	return func(agent *Agent) {
+		agent.consoleReport = true
+	}
+}
+
+// Exports the coverage of the test run to the given folder when the agent stops (Go cover profile, LCOV
+// and Cobertura XML), the coverage of the test processes of the same `go test` command is merged
+func WithCoverageExport(folder string) Option {
+	return func(agent *Agent) {
+		agent.coverageExportPath = folder
+	}
+}
+
+// Exports the coverage profile of each test (in the `tests` folder of the coverage export)
+func WithPerTestCoverageExport() Option {
+	return func(agent *Agent) {
+		agent.coverageExportPerTest = true
+	}
+}
+
+// Enables the local test impact analysis, the tests whose covered code has not changed since the
+// nearest ancestor of the base ref with coverage data are skipped (reported as cached)
+func WithTestImpactAnalysis(baseRef string) Option {
+	return func(agent *Agent) {
+		agent.testImpactEnabled = true
+		agent.testImpactBaseRef = baseRef
+	}
+}
+
+// Enables the quarantine mode, the quarantined tests still run and report but their failures don't fail the test run.
+// The flaky tests detected by the local history are quarantined, the remote configuration can also quarantine tests
+func WithQuarantine() Option {
+	return func(agent *Agent) {
+		agent.quarantineEnabled = true
+	}
+}
+
+// Enables the quarantine mode and quarantines the tests (`{package}.{test name}`, one per line) of the file
+func WithQuarantineFile(path string) Option {
+	return func(agent *Agent) {
+		agent.quarantineEnabled = true
+		agent.quarantineFile = path
+	}
+}
+
+// Sets the min flakiness score (between 0 and 1) of the tests quarantined by the local history
+func WithFlakyThreshold(score float64) Option {
+	return func(agent *Agent) {
+		agent.flakyThreshold = score
+	}
+}
+
+// Enables the benchmark regression detection, the benchmarks are compared against the results stored for the
+// nearest ancestor of the base ref (a branch or a commit) and the remote configuration
+func WithBenchmarkBaseline(baseRef string) Option {
+	return func(agent *Agent) {
+		agent.benchmarkBaselineEnabled = true
+		agent.benchmarkBaseRef = baseRef
+	}
+}
+
+// Sets the min change (ratio) of a benchmark reported as a regression or improvement, and if the test run
+// fails when there are regressions
+func WithBenchmarkRegressionThreshold(threshold float64, failRun bool) Option {
+	return func(agent *Agent) {
+		agent.benchmarkThreshold = threshold
+		agent.benchmarkFailRun = failRun
+	}
+}
+
+// Runs only the deterministic share of the tests of the shard (zero based index) of the total shards
+func WithSharding(index int, total int) Option {
+	return func(agent *Agent) {
+		agent.shardIndex = index
+		agent.shardTotal = total
+	}
+}
+
+// Balances the shards using the test durations of a JSON file (`{"{suite}.{test}": "1.5s"}`) shared by all the
+// shards, every shard must read the same file to get the same split (see WithShardDurationsExport)
+func WithShardBalancing(durationsFile string) Option {
+	return func(agent *Agent) {
+		agent.shardDurationsFile = durationsFile
+	}
+}
+
+// Writes the test durations of the local test history to a JSON file when the agent stops, in the format of
+// WithShardBalancing. The durations already in the file are kept, so the shards can merge their durations in
+// a file shared by the next runs (ex: a CI cache).
+func WithShardDurationsExport(durationsFile string) Option {
+	return func(agent *Agent) {
+		agent.shardDurationsExport = durationsFile
+	}
+}
+
+// Shuffles the tests order with the seed (a random seed is used if zero), the seed is printed and
+// reported in the agent metadata and in the test spans so the order can be reproduced
+func WithShuffle(seed int64) Option {
+	return func(agent *Agent) {
+		agent.shuffle = true
+		agent.shuffleSeed = seed
+	}
+}
+
+// Shuffles the sub tests tables shuffled with `Test.Shuffle` (requires the tests shuffle)
+func WithSubTestsShuffle() Option {
+	return func(agent *Agent) {
+		agent.shuffleSubTests = true
+	}
+}
+
 func WithGlobalPanicHandler() Option {
 	return func(agent *Agent) {
 		reflection.AddPanicHandler(func(e interface{}) {
@@ -214,43 +525,34 @@ func NewAgent(options ...Option) (*Agent, error) {
 
 	agent.debugMode = agent.debugMode || env.ScopeDebug.Value
 
-	configProfile := GetConfigCurrentProfile()
-
-	if agent.apiKey == "" || agent.apiEndpoint == "" {
-		if dsn, set := env.ScopeDsn.Tuple(); set && dsn != "" {
-			dsnApiKey, dsnApiEndpoint, dsnErr := parseDSN(dsn)
-			if dsnErr != nil {
-				agent.logger.Printf("Error parsing dsn value: %v\n", dsnErr)
-			} else {
-				agent.apiKey = dsnApiKey
-				agent.apiEndpoint = dsnApiEndpoint
-			}
-		} else {
-			agent.logger.Println("environment variable $SCOPE_DSN not found")
-		}
+	if agent.offlineExportPath == "" {
+		agent.offlineExportPath = env.ScopeOfflineExportPath.Value
 	}
 
-	if agent.apiKey == "" {
-		if apiKey, set := env.ScopeApiKey.Tuple(); set && apiKey != "" {
-			agent.apiKey = apiKey
-		} else if configProfile != nil {
-			agent.logger.Println("API key found in the native app configuration")
-			agent.apiKey = configProfile.ApiKey
-		} else {
-			agent.logger.Println("API key not found, agent can't be started")
-			return nil, errors.New("Scope DSN not found. Tests will run but no results will be reported to Scope. More info at https://docs.scope.dev/")
+	if agent.junitReportPath == "" {
+		agent.junitReportPath = env.ScopeTestingJUnitReport.Value
+	}
+	if agent.htmlReportPath == "" {
+		agent.htmlReportPath = env.ScopeTestingHTMLReport.Value
+	}
+	agent.consoleReport = agent.consoleReport || env.ScopeTestingConsoleReport.Value
+
+	if err := agent.loadCredentials(); err != nil {
+		switch {
+		case agent.isOffline():
+			agent.logger.Printf("offline export mode enabled, payloads will be written to: %s", agent.offlineExportPath)
+		case agent.hasLocalReports():
+			// Without credentials the test results are only written to the local reports
+			agent.localReportMode = true
+			agent.logger.Printf("local report mode enabled, the test results won't be sent to Scope: %v", err)
+		default:
+			return nil, err
 		}
 	}
-
-	if agent.apiEndpoint == "" {
-		if endpoint, set := env.ScopeApiEndpoint.Tuple(); set && endpoint != "" {
-			agent.apiEndpoint = endpoint
-		} else if configProfile != nil {
-			agent.logger.Println("API endpoint found in the native app configuration")
-			agent.apiEndpoint = configProfile.ApiEndpoint
-		} else {
-			agent.logger.Printf("using default endpoint: %v\n", endpoint)
-			agent.apiEndpoint = endpoint
+	if agent.isOffline() {
+		if err := os.MkdirAll(agent.offlineExportPath, 0755); err != nil {
+			agent.logger.Printf("error creating the offline export path: %v", err)
+			return nil, err
 		}
 	}
 
@@ -368,6 +670,23 @@ func NewAgent(options ...Option) (*Agent, error) {
 		agent.failRetriesCount = env.ScopeTestingFailRetries.Value
 	}
 	agent.panicAsFail = agent.panicAsFail || env.ScopeTestingPanicAsFail.Value
+	agent.loadRetryPolicy()
+	agent.loadTestTimeouts()
+	if !agent.leakCheck && env.ScopeTestingGoroutineLeaks.Value {
+		agent.leakCheck = true
+		agent.leakCheckGrace = env.ScopeTestingGoroutineLeaksGrace.Value
+		agent.leakCheckFail = env.ScopeTestingGoroutineLeaksFail.Value
+	}
+	if agent.leakCheck {
+		config.SetGoroutineLeakCheck(agent.leakCheckGrace, agent.leakCheckFail)
+	}
+	config.SetResourceMetrics(!agent.resourceMetricsDisabled && env.ScopeTestingResourceMetrics.Value)
+	config.SetParallelCoverage(agent.parallelCoverage || env.ScopeTestingCoverageParallel.Value)
+
+	agent.spoolEnabled = agent.spoolEnabled || env.ScopeSpoolEnabled.Value
+	if agent.spoolPath == "" {
+		agent.spoolPath = env.ScopeSpoolPath.Value
+	}
 
 	agent.flushFrequency = nonTestingModeFrequency
 	if agent.testingMode {
@@ -381,28 +700,134 @@ func NewAgent(options ...Option) (*Agent, error) {
 	//
 	agent.cache = newLocalCache(agent.getRemoteConfigRequest(), cacheTimeout, agent.debugMode, agent.logger)
 
-	agent.recorder = NewSpanRecorder(agent)
-	var recorder tracer.SpanRecorder = agent.recorder
-	if agent.optionalRecorders != nil {
-		recorders := append(agent.optionalRecorders, agent.recorder)
-		recorder = tracer.NewMultiRecorder(recorders...)
+	if agent.testingMode && agent.junitReportPath != "" {
+		agent.optionalRecorders = append(agent.optionalRecorders, junit.NewRecorder(agent.junitReportPath))
+	}
+
+	if agent.testingMode && agent.htmlReportPath != "" {
+		agent.optionalRecorders = append(agent.optionalRecorders, html.NewRecorder(agent.htmlReportPath))
+	}
+
+	if agent.testingMode && agent.consoleReport {
+		agent.optionalRecorders = append(agent.optionalRecorders, console.NewRecorder(os.Stdout))
+	}
+
+	if agent.coverageExportPath == "" {
+		agent.coverageExportPath = env.ScopeTestingCoverageExport.Value
+	}
+	agent.coverageExportPerTest = agent.coverageExportPerTest || env.ScopeTestingCoverageExportPerTest.Value
+	if agent.testingMode && agent.coverageExportPath != "" {
+		agent.setupCoverageExport(sourceRoot)
+	}
+
+	agent.testImpactEnabled = agent.testImpactEnabled || env.ScopeTestingImpactAnalysis.Value
+	if agent.testImpactBaseRef == "" {
+		agent.testImpactBaseRef = env.ScopeTestingImpactBaseRef.Value
+	}
+	if agent.testingMode && agent.testImpactEnabled {
+		agent.setupTestImpact()
+	}
+
+	agent.benchmarkBaselineEnabled = agent.benchmarkBaselineEnabled || env.ScopeTestingBenchmarkBaseline.Value
+	if agent.benchmarkBaseRef == "" {
+		agent.benchmarkBaseRef = env.ScopeTestingBenchmarkBaseRef.Value
+	}
+	if agent.benchmarkThreshold <= 0 {
+		agent.benchmarkThreshold = env.ScopeTestingBenchmarkThreshold.Value
+	}
+	agent.benchmarkFailRun = agent.benchmarkFailRun || env.ScopeTestingBenchmarkFail.Value
+	if agent.testingMode && agent.benchmarkBaselineEnabled {
+		agent.setupBenchmarkBaseline()
+	}
+
+	if agent.quarantineFile == "" {
+		agent.quarantineFile = env.ScopeTestingQuarantineFile.Value
+	}
+	agent.quarantineEnabled = agent.quarantineEnabled || env.ScopeTestingQuarantine.Value || agent.quarantineFile != ""
+	if agent.flakyThreshold <= 0 {
+		agent.flakyThreshold = env.ScopeTestingFlakyThreshold.Value
+	}
+	if agent.testingMode {
+		agent.setupTestHistory(sourceRoot)
+	}
+
+	if agent.shardTotal == 0 {
+		agent.shardIndex = env.ScopeTestingShardIndex.Value
+		agent.shardTotal = env.ScopeTestingShardTotal.Value
+	}
+	if agent.shardDurationsFile == "" {
+		agent.shardDurationsFile = env.ScopeTestingShardDurations.Value
+	}
+	if agent.shardDurationsExport == "" {
+		agent.shardDurationsExport = env.ScopeTestingShardDurationsExport.Value
+	}
+	if agent.testHistory != nil {
+		agent.testHistory.durationsFile = agent.shardDurationsExport
+	}
+	if agent.shardTotal > 1 {
+		if agent.shardIndex < 0 || agent.shardIndex >= agent.shardTotal {
+			agent.logger.Printf("invalid shard index %d for %d shards, sharding is disabled", agent.shardIndex, agent.shardTotal)
+			agent.shardTotal = 0
+		} else {
+			agent.metadata[tags.ShardIndex] = agent.shardIndex
+			agent.metadata[tags.ShardTotal] = agent.shardTotal
+		}
+	}
+
+	if seed, set := env.ScopeTestingShuffleSeed.Tuple(); set && agent.shuffleSeed == 0 {
+		// The seed env var is used to reproduce a previous order
+		agent.shuffle = true
+		agent.shuffleSeed = int64(seed)
+	}
+	agent.shuffle = agent.shuffle || env.ScopeTestingShuffle.Value
+	agent.shuffleSubTests = agent.shuffleSubTests || env.ScopeTestingShuffleSubTests.Value
+	if agent.shuffle {
+		if agent.shuffleSeed == 0 {
+			agent.shuffleSeed = time.Now().UnixNano()
+		}
+		agent.metadata[tags.ShuffleSeed] = agent.shuffleSeed
+		config.SetShuffle(agent.shuffleSeed, agent.shuffleSubTests)
+	}
+
+	recorders := append([]tracer.SpanRecorder{}, agent.optionalRecorders...)
+	if !agent.localReportMode {
+		agent.recorder = NewSpanRecorder(agent)
+		recorders = append(recorders, agent.recorder)
+	}
+	recorder := tracer.NewMultiRecorder(recorders...)
+	if len(recorders) == 1 {
+		recorder = recorders[0]
+	}
+
+	if agent.propagationFormats == nil && env.ScopeTracerPropagation.Value != nil {
+		formats, err := tracer.ParsePropagationFormats(env.ScopeTracerPropagation.Value)
+		if err != nil {
+			agent.logger.Printf("error parsing %s: %v", env.ScopeTracerPropagation.Key, err)
+		}
+		agent.propagationFormats = formats
 	}
 
 	agent.tracer = tracer.NewWithOptions(tracer.Options{
-		Recorder: recorder,
-		ShouldSample: func(traceID uuid.UUID) bool {
-			return true
-		},
+		Recorder:       recorder,
+		Sampler:        newAgentSampler(agent),
 		MaxLogsPerSpan: 10000,
 		// Log the error in the current span
 		OnSpanFinishPanic: scopeError.WriteExceptionEventInRawSpan,
+		Propagation:       agent.propagationFormats,
 	})
 	instrumentation.SetTracer(agent.tracer)
 	instrumentation.SetLogger(agent.logger)
 	instrumentation.SetSourceRoot(sourceRoot)
-	if enableRemoteConfig {
-		instrumentation.SetRemoteConfiguration(agent.loadRemoteConfiguration())
+	if enableRemoteConfig && !agent.isOffline() && !agent.localReportMode {
+		remoteConfig := agent.loadRemoteConfiguration()
+		instrumentation.SetRemoteConfiguration(remoteConfig)
+		if agent.samplerConfig.applyRemoteConfiguration(remoteConfig, agent.sampler != nil) {
+			agent.logger.Printf("sampling configuration loaded from the remote configuration: %v", remoteConfig[remoteConfigSampling])
+		}
 	}
+	agent.applyTestImpact()
+	agent.applyQuarantine()
+	agent.applyBenchmarkBaseline()
 	if agent.setGlobalTracer || env.ScopeTracerGlobal.Value {
 		opentracing.SetGlobalTracer(agent.Tracer())
 	}
@@ -410,6 +835,31 @@ func NewAgent(options ...Option) (*Agent, error) {
 	return agent, nil
 }
 
+// Loads the api key and api endpoint from the options, the environment variables or the native app configuration
+func (a *Agent) loadCredentials() error {
+	apiKey, apiEndpoint, err := ingest.LoadCredentials(a.apiKey, a.apiEndpoint, a.logger)
+	a.apiKey, a.apiEndpoint = apiKey, apiEndpoint
+	return err
+}
+
+// Sets a metadata value after the recorder has been started (the recorder keeps its own copy of the metadata)
+func (a *Agent) setMetadata(key string, value interface{}) {
+	a.metadata[key] = value
+	if a.recorder != nil {
+		a.recorder.setMetadata(key, value)
+	}
+}
+
+// Gets if the agent is writing the payloads to files instead of sending them to Scope
+func (a *Agent) isOffline() bool {
+	return a.offlineExportPath != ""
+}
+
+// Gets if a local test report (JUnit, HTML or console) is enabled
+func (a *Agent) hasLocalReports() bool {
+	return a.junitReportPath != "" || a.htmlReportPath != "" || a.consoleReport
+}
+
 func getGoModDir() string {
 	dir, err := os.Getwd()
 	if err != nil {
@@ -458,19 +908,41 @@ func (a *Agent) Logger() *log.Logger {
 // Runs the test suite
 func (a *Agent) Run(m *testing.M) int {
 	defer a.Stop()
-	return runner.Run(m, runner.Options{
+	options := runner.Options{
 		FailRetries: a.failRetriesCount,
 		PanicAsFail: a.panicAsFail,
 		Logger:      a.logger,
 		OnPanic: func(t *testing.T, err interface{}) {
 			if t != nil {
 				a.logger.Printf("test '%s' has panicked (%v), stopping agent", t.Name(), err)
+				// Finishes the span if the test is still running (ex: a hung test)
+				scopetesting.FinishRunningTest(t)
 			} else {
 				a.logger.Printf("panic: %v", err)
 			}
 			a.Stop()
 		},
-	})
+	}
+	a.setRetryPolicy(&options)
+	a.setTestTimeouts(&options)
+	options.Shuffle = a.shuffle
+	options.ShuffleSeed = a.shuffleSeed
+	if a.quarantineEnabled {
+		options.IsQuarantined = scopetesting.IsQuarantined
+	}
+	if a.shardTotal > 1 {
+		options.ShardIndex = a.shardIndex
+		options.ShardTotal = a.shardTotal
+		durations, err := a.getShardDurations()
+		if err != nil {
+			// The other shards could load the file, so a different split would skip or duplicate tests
+			fmt.Printf("[SCOPE SHARDING] error loading the shard durations: %v\n", err)
+			a.logger.Printf("error loading the shard durations: %v", err)
+			return 1
+		}
+		options.ShardDurations = durations
+	}
+	return a.checkBenchmarkRegressions(runner.Run(m, options))
 }
 
 // Stops the agent
@@ -479,6 +951,13 @@ func (a *Agent) Stop() {
 	if a.recorder != nil {
 		a.recorder.Stop()
 	}
+	for _, recorder := range a.optionalRecorders {
+		if stopper, ok := recorder.(interface{ Stop() error }); ok {
+			if err := stopper.Stop(); err != nil {
+				a.logger.Printf("error stopping recorder: %v", err)
+			}
+		}
+	}
 	a.PrintReport()
 }
 
@@ -490,6 +969,13 @@ func (a *Agent) Flush() {
 			a.logger.Println(err)
 		}
 	}
+	for _, recorder := range a.optionalRecorders {
+		if flusher, ok := recorder.(interface{ Flush() error }); ok {
+			if err := flusher.Flush(); err != nil {
+				a.logger.Println(err)
+			}
+		}
+	}
 }
 
 func generateAgentID() string {
@@ -539,17 +1025,9 @@ func getLogPath() (string, error) {
 	}
 }
 
-func parseDSN(dsnString string) (apiKey string, apiEndpoint string, err error) {
-	uri, err := url.Parse(dsnString)
-	if err != nil {
-		return "", "", err
-	}
-	if uri.User != nil {
-		apiKey = uri.User.Username()
-	}
-	uri.User = nil
-	apiEndpoint = uri.String()
-	return
+// Parses a Scope DSN returning the api key and the api endpoint
+func ParseDSN(dsnString string) (apiKey string, apiEndpoint string, err error) {
+	return ingest.ParseDSN(dsnString)
 }
 
 func (a *Agent) getUrl(pathValue string) string {
@@ -2,7 +2,11 @@ package agent
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -26,7 +30,7 @@ func TestDsnParser(t *testing.T) {
 	for i := 0; i < len(dsnValues); i++ {
 		dsnValue := dsnValues[i]
 		t.Run(dsnValue[0], func(st *testing.T) {
-			apiKey, apiEndpoint, err := parseDSN(dsnValue[0])
+			apiKey, apiEndpoint, err := ParseDSN(dsnValue[0])
 			if apiKey != dsnValue[1] || apiEndpoint != dsnValue[2] {
 				if err != nil {
 					st.Error(err)
@@ -139,3 +143,44 @@ func BenchmarkNewAgent(b *testing.B) {
 		a.Stop()
 	}
 }
+
+func TestLocalReportMode(t *testing.T) {
+	if GetConfigCurrentProfile() != nil {
+		t.Skip("the credentials are loaded from the native app configuration")
+	}
+	dsn, apiKey, offlineExportPath := env.ScopeDsn, env.ScopeApiKey, env.ScopeOfflineExportPath
+	env.ScopeDsn, env.ScopeApiKey, env.ScopeOfflineExportPath = env.StringEnvVar{}, env.StringEnvVar{}, env.StringEnvVar{}
+	defer func() {
+		env.ScopeDsn, env.ScopeApiKey, env.ScopeOfflineExportPath = dsn, apiKey, offlineExportPath
+	}()
+
+	folder, err := ioutil.TempDir("", "scope-local-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	path := filepath.Join(folder, "report.html")
+
+	agent, err := NewAgent(WithHTMLReport(path), WithTestingModeEnabled())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.recorder != nil {
+		t.Fatal("the results must not be sent to Scope without credentials")
+	}
+	span := agent.Tracer().StartSpan("TestLocalReportMode")
+	span.SetTag("span.kind", "test")
+	span.SetTag("test.name", "TestLocalReportMode")
+	span.SetTag("test.suite", "root")
+	span.SetTag("test.status", tags.TestStatus_PASS)
+	span.Finish()
+	agent.Stop()
+
+	report, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "TestLocalReportMode") {
+		t.Fatalf("the test is missing in the report:\n%s", report)
+	}
+}
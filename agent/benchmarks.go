@@ -0,0 +1,285 @@
+package agent
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Local store of the benchmark results by commit, used as baseline to detect the benchmark regressions
+	benchmarkBaseline struct {
+		logger     *log.Logger
+		folder     string
+		commit     string
+		dirty      bool
+		baseCommit string
+		baseline   map[string]*config.BenchmarkSamples
+
+		mu     sync.Mutex
+		suites map[string]*benchmarkSuite
+	}
+
+	// Benchmark results of a package (a test process) in a commit
+	benchmarkSuite struct {
+		Commit     string                              `json:"commit"`
+		Suite      string                              `json:"suite"`
+		Benchmarks map[string]*config.BenchmarkSamples `json:"benchmarks"`
+	}
+)
+
+const (
+	// Remote configuration key with the baseline samples by benchmark (`{package}.{benchmark name}`)
+	remoteConfigBenchmarks = "benchmarks"
+
+	benchmarkBaseCommitsLimit = 100
+	benchmarkMaxCommits       = 50
+	benchmarkMaxSamples       = 20
+)
+
+// Creates the benchmark baseline store for the repository in the current folder, the baseline is the data of
+// the nearest ancestor of the base ref (a branch or a commit) with data
+func newBenchmarkBaseline(baseRef string, logger *log.Logger) (*benchmarkBaseline, error) {
+	repoRoot, err := getGitOutput("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("the current folder is not a git repository: %v", err)
+	}
+	commit, err := getGitOutput(repoRoot, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	localFolder, err := getLocalFolder("benchmarks")
+	if err != nil {
+		return nil, err
+	}
+	bb := &benchmarkBaseline{
+		logger:   logger,
+		folder:   filepath.Join(localFolder, fmt.Sprintf("%x", sha1.Sum([]byte(repoRoot)))),
+		commit:   commit,
+		dirty:    isGitWorkingTreeDirty(repoRoot),
+		baseline: map[string]*config.BenchmarkSamples{},
+		suites:   map[string]*benchmarkSuite{},
+	}
+	if baseRef == "" {
+		baseRef = "HEAD"
+	}
+	revs, err := getGitOutput(repoRoot, "rev-list", fmt.Sprintf("--max-count=%d", benchmarkBaseCommitsLimit), baseRef)
+	if err != nil {
+		logger.Printf("benchmark baseline: error resolving the base ref '%s': %v", baseRef, err)
+		return bb, nil
+	}
+	bb.loadBase(strings.Split(revs, "\n"))
+	return bb, nil
+}
+
+// Loads the benchmark results of the first commit with data
+func (bb *benchmarkBaseline) loadBase(revs []string) {
+	for _, rev := range revs {
+		files, _ := filepath.Glob(filepath.Join(bb.folder, rev, "*.json"))
+		if len(files) == 0 {
+			continue
+		}
+		for _, file := range files {
+			suite, err := readBenchmarkSuite(file)
+			if err != nil {
+				bb.logger.Printf("benchmark baseline: error reading %s: %v", file, err)
+				continue
+			}
+			for fqn, samples := range suite.Benchmarks {
+				bb.baseline[fqn] = samples
+			}
+		}
+		bb.baseCommit = rev
+		bb.logger.Printf("benchmark baseline: using the results of commit %s", rev)
+		return
+	}
+	bb.logger.Println("benchmark baseline: there aren't previous benchmark results")
+}
+
+// Records the results of the benchmark spans
+func (bb *benchmarkBaseline) RecordSpan(span tracer.RawSpan) {
+	if span.Tags["span.kind"] != "test" || span.Tags["test.type"] != "benchmark" {
+		return
+	}
+	if span.Tags["test.status"] != tags.TestStatus_PASS {
+		return
+	}
+	suiteName, _ := span.Tags["test.suite"].(string)
+	name, _ := span.Tags["test.name"].(string)
+	duration, ok := toFloat(span.Tags["benchmark.duration.mean"])
+	if suiteName == "" || name == "" || !ok {
+		return
+	}
+	allocations, _ := toFloat(span.Tags["benchmark.memory.mean_allocations"])
+	bytes, _ := toFloat(span.Tags["benchmark.memory.mean_bytes_allocations"])
+	fqn := fmt.Sprintf("%s.%s", suiteName, name)
+
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	suite, ok := bb.suites[suiteName]
+	if !ok {
+		suite = &benchmarkSuite{Commit: bb.commit, Suite: suiteName, Benchmarks: map[string]*config.BenchmarkSamples{}}
+		bb.suites[suiteName] = suite
+	}
+	samples, ok := suite.Benchmarks[fqn]
+	if !ok {
+		samples = &config.BenchmarkSamples{}
+		suite.Benchmarks[fqn] = samples
+	}
+	samples.Durations = append(samples.Durations, duration)
+	samples.Allocations = append(samples.Allocations, allocations)
+	samples.Bytes = append(samples.Bytes, bytes)
+}
+
+// Stores the benchmark results of the current commit, appended to the results of previous runs of the same commit
+func (bb *benchmarkBaseline) Stop() error {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	if len(bb.suites) == 0 {
+		return nil
+	}
+	if bb.dirty {
+		bb.logger.Println("benchmark baseline: the working tree has changes, the benchmark results are not stored")
+		return nil
+	}
+	commitFolder := filepath.Join(bb.folder, bb.commit)
+	if err := os.MkdirAll(commitFolder, 0755); err != nil {
+		return err
+	}
+	for suiteName, suite := range bb.suites {
+		fileName := filepath.Join(commitFolder, fmt.Sprintf("%x.json", sha1.Sum([]byte(suiteName))))
+		if stored, err := readBenchmarkSuite(fileName); err == nil {
+			for fqn, samples := range suite.Benchmarks {
+				if storedSamples, ok := stored.Benchmarks[fqn]; ok {
+					samples.Durations = lastSamples(append(storedSamples.Durations, samples.Durations...))
+					samples.Allocations = lastSamples(append(storedSamples.Allocations, samples.Allocations...))
+					samples.Bytes = lastSamples(append(storedSamples.Bytes, samples.Bytes...))
+				}
+				stored.Benchmarks[fqn] = samples
+			}
+			suite = stored
+		}
+		data, err := json.Marshal(suite)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(fileName, data); err != nil {
+			return err
+		}
+	}
+	bb.suites = map[string]*benchmarkSuite{}
+	pruneCommitFolders(bb.folder, benchmarkMaxCommits)
+	return nil
+}
+
+func lastSamples(samples []float64) []float64 {
+	if len(samples) > benchmarkMaxSamples {
+		return samples[len(samples)-benchmarkMaxSamples:]
+	}
+	return samples
+}
+
+func readBenchmarkSuite(file string) (*benchmarkSuite, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	suite := &benchmarkSuite{}
+	if err := json.Unmarshal(data, suite); err != nil {
+		return nil, err
+	}
+	if suite.Benchmarks == nil {
+		suite.Benchmarks = map[string]*config.BenchmarkSamples{}
+	}
+	return suite, nil
+}
+
+// Gets the baseline samples of the remote configuration, each metric can be a number or a list of samples:
+// `{"benchmarks": {"pkg.BenchmarkName": {"durations": [1200, 1250], "allocations": 2, "bytes": 64}}}`
+func getRemoteBenchmarkBaseline(remoteConfig map[string]interface{}) map[string]*config.BenchmarkSamples {
+	remoteBenchmarks, _ := remoteConfig[remoteConfigBenchmarks].(map[string]interface{})
+	baseline := map[string]*config.BenchmarkSamples{}
+	for fqn, value := range remoteBenchmarks {
+		metrics, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		samples := &config.BenchmarkSamples{
+			Durations:   toFloatSamples(metrics["durations"]),
+			Allocations: toFloatSamples(metrics["allocations"]),
+			Bytes:       toFloatSamples(metrics["bytes"]),
+		}
+		if len(samples.Durations) > 0 || len(samples.Allocations) > 0 || len(samples.Bytes) > 0 {
+			baseline[fqn] = samples
+		}
+	}
+	return baseline
+}
+
+func toFloatSamples(value interface{}) []float64 {
+	if number, ok := toFloat(value); ok {
+		return []float64{number}
+	}
+	var samples []float64
+	if values, ok := value.([]interface{}); ok {
+		for _, item := range values {
+			if number, ok := toFloat(item); ok {
+				samples = append(samples, number)
+			}
+		}
+	}
+	return samples
+}
+
+// Enables the benchmark baseline, the recorder stores the benchmark results of this run
+func (a *Agent) setupBenchmarkBaseline() {
+	bb, err := newBenchmarkBaseline(a.benchmarkBaseRef, a.logger)
+	if err != nil {
+		a.logger.Printf("benchmark baseline: %v", err)
+		return
+	}
+	a.benchmarkBaseline = bb
+	a.optionalRecorders = append(a.optionalRecorders, bb)
+}
+
+// Sets the baseline of the benchmarks, the local results take precedence over the remote configuration
+// (must be called after loading the remote configuration)
+func (a *Agent) applyBenchmarkBaseline() {
+	if a.benchmarkBaseline == nil {
+		return
+	}
+	baseline := getRemoteBenchmarkBaseline(instrumentation.GetRemoteConfiguration())
+	for fqn, samples := range a.benchmarkBaseline.baseline {
+		baseline[fqn] = samples
+	}
+	config.SetBenchmarkBaselines(baseline, a.benchmarkThreshold)
+	a.setMetadata(tags.BenchmarkBaselineCommit, a.benchmarkBaseline.baseCommit)
+	a.logger.Printf("benchmark baseline: %d benchmarks with baseline, threshold: %.2f", len(baseline), a.benchmarkThreshold)
+}
+
+// Gets the exit code of the test run with the benchmark regressions, the run fails if there are regressions
+// and the fail option is enabled
+func (a *Agent) checkBenchmarkRegressions(exitCode int) int {
+	if a.benchmarkBaseline == nil {
+		return exitCode
+	}
+	regressions := config.GetBenchmarkRegressions()
+	for _, fqn := range regressions {
+		fmt.Printf("[SCOPE BENCHMARK] regression: %s\n", fqn)
+	}
+	if len(regressions) > 0 && a.benchmarkFailRun && exitCode == 0 {
+		return 1
+	}
+	return exitCode
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func TestBenchmarkBaseline(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-benchmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	newBaseline := func(commit string) *benchmarkBaseline {
+		return &benchmarkBaseline{
+			logger:   log.New(ioutil.Discard, "", 0),
+			folder:   folder,
+			commit:   commit,
+			baseline: map[string]*config.BenchmarkSamples{},
+			suites:   map[string]*benchmarkSuite{},
+		}
+	}
+	record := func(bb *benchmarkBaseline, name string, duration float64) {
+		bb.RecordSpan(tracer.RawSpan{Tags: opentracing.Tags{
+			"span.kind":                               "test",
+			"test.type":                               "benchmark",
+			"test.suite":                              "pkg",
+			"test.name":                               name,
+			"test.status":                             tags.TestStatus_PASS,
+			"benchmark.duration.mean":                 duration,
+			"benchmark.memory.mean_allocations":       int64(2),
+			"benchmark.memory.mean_bytes_allocations": int64(64),
+		}})
+	}
+
+	first := newBaseline("commit1")
+	record(first, "BenchmarkA", 100)
+	record(first, "BenchmarkA", 110)
+	if err := first.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	second := newBaseline("commit1")
+	record(second, "BenchmarkA", 120)
+	if err := second.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	current := newBaseline("commit2")
+	current.loadBase([]string{"commit2", "commit1", "commit0"})
+	if current.baseCommit != "commit1" {
+		t.Fatalf("unexpected base commit: %s", current.baseCommit)
+	}
+	samples, ok := current.baseline["pkg.BenchmarkA"]
+	if !ok {
+		t.Fatalf("the baseline of the benchmark is missing: %v", current.baseline)
+	}
+	if !reflect.DeepEqual(samples.Durations, []float64{100, 110, 120}) {
+		t.Fatalf("unexpected durations: %v", samples.Durations)
+	}
+	if !reflect.DeepEqual(samples.Allocations, []float64{2, 2, 2}) {
+		t.Fatalf("unexpected allocations: %v", samples.Allocations)
+	}
+}
+
+func TestRemoteBenchmarkBaseline(t *testing.T) {
+	baseline := getRemoteBenchmarkBaseline(map[string]interface{}{
+		remoteConfigBenchmarks: map[string]interface{}{
+			"pkg.BenchmarkA": map[string]interface{}{
+				"durations":   []interface{}{1200.0, 1250.0},
+				"allocations": 2.0,
+			},
+			"pkg.BenchmarkB": "invalid",
+		},
+	})
+	if len(baseline) != 1 {
+		t.Fatalf("unexpected baseline: %v", baseline)
+	}
+	samples := baseline["pkg.BenchmarkA"]
+	if !reflect.DeepEqual(samples.Durations, []float64{1200, 1250}) || !reflect.DeepEqual(samples.Allocations, []float64{2}) {
+		t.Fatalf("unexpected samples: %v", samples)
+	}
+}
@@ -9,11 +9,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sync"
 	"time"
-
-	"github.com/mitchellh/go-homedir"
 )
 
 const cacheTimeout = 1 * time.Minute
@@ -117,11 +114,6 @@ func (c *localCache) GetOrSet(key string, useTimeout bool, fn func(interface{},
 
 // Sets the local cache tenant
 func (c *localCache) SetTenant(tenant interface{}) {
-	homeDir, err := homedir.Dir()
-	if err != nil {
-		c.logger.Printf("local cache error: %v", err)
-		return
-	}
 	data, err := json.Marshal(tenant)
 	if err != nil {
 		c.logger.Printf("local cache error: %v", err)
@@ -129,25 +121,11 @@ func (c *localCache) SetTenant(tenant interface{}) {
 	}
 	hash := fmt.Sprintf("%x", sha1.Sum(data))
 
-	var folder string
-	if runtime.GOOS == "windows" {
-		folder = fmt.Sprintf("%s/AppData/Roaming/scope/cache", homeDir)
-	} else {
-		folder = fmt.Sprintf("%s/.scope/cache", homeDir)
-	}
-
-	if _, err := os.Stat(folder); err == nil {
-		c.tenant = tenant
-		c.basePath = filepath.Join(folder, hash)
-	} else if os.IsNotExist(err) {
-		err = os.MkdirAll(folder, 0755)
-		if err != nil {
-			c.logger.Printf("local cache error: %v", err)
-			return
-		}
-		c.tenant = tenant
-		c.basePath = filepath.Join(folder, hash)
-	} else {
+	folder, err := getLocalFolder("cache")
+	if err != nil {
 		c.logger.Printf("local cache error: %v", err)
+		return
 	}
+	c.tenant = tenant
+	c.basePath = filepath.Join(folder, hash)
 }
@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation/coverage"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Exports the coverage of the test run (and optionally of each test) in the Go cover, LCOV and Cobertura
+	// formats. The coverage of the test processes started by the same `go test` command is merged
+	coverageExport struct {
+		logger     *log.Logger
+		folder     string
+		sourceRoot string
+		perTest    bool
+
+		mu    sync.Mutex
+		tests map[string]*coverage.Profile
+	}
+)
+
+const (
+	coverageProfileFile   = "coverage.out"
+	coverageLCOVFile      = "lcov.info"
+	coverageCoberturaFile = "cobertura.xml"
+	coverageRunFile       = "coverage.run"
+	coverageLockFile      = "coverage.lock"
+	coverageTestsFolder   = "tests"
+
+	coverageLockTimeout = time.Minute
+)
+
+var coverageFileNameRegex = regexp.MustCompile(`[^\w.-]+`)
+
+// Creates the coverage export to the folder
+func newCoverageExport(folder string, sourceRoot string, perTest bool, logger *log.Logger) *coverageExport {
+	return &coverageExport{
+		logger:     logger,
+		folder:     folder,
+		sourceRoot: sourceRoot,
+		perTest:    perTest,
+		tests:      map[string]*coverage.Profile{},
+	}
+}
+
+// Records the coverage of the test spans (only with the per test export)
+func (c *coverageExport) RecordSpan(span tracer.RawSpan) {
+	if !c.perTest || span.Tags["span.kind"] != "test" {
+		return
+	}
+	cov, ok := span.Tags[tags.Coverage].(interface{ Profile() *coverage.Profile })
+	if !ok || cov.Profile() == nil {
+		return
+	}
+	suite, _ := span.Tags["test.suite"].(string)
+	name, _ := span.Tags["test.name"].(string)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The profile of the last attempt is exported
+	c.tests[fmt.Sprintf("%s.%s", suite, name)] = cov.Profile()
+}
+
+// Writes the coverage files
+func (c *coverageExport) Stop() error {
+	profile := coverage.GetRunProfile()
+	if profile == nil {
+		c.logger.Println("coverage export: the coverage is not enabled (-cover flag)")
+		return nil
+	}
+	if err := os.MkdirAll(c.folder, 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(filepath.Join(c.folder, coverageLockFile), coverageLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if c.isSameRun() {
+		if previous, err := readCoverageProfile(filepath.Join(c.folder, coverageProfileFile)); err == nil {
+			if previous.Mode == profile.Mode {
+				profile.Merge(previous)
+			} else {
+				c.logger.Printf("coverage export: the previous profile mode (%s) is not %s, the profile is replaced",
+					previous.Mode, profile.Mode)
+			}
+		}
+	}
+	paths := profile.GetFilePaths()
+	if err := writeCoverageFile(filepath.Join(c.folder, coverageProfileFile), profile.WriteGo); err != nil {
+		return err
+	}
+	if err := writeCoverageFile(filepath.Join(c.folder, coverageLCOVFile), func(w io.Writer) error {
+		return profile.WriteLCOV(w, paths)
+	}); err != nil {
+		return err
+	}
+	if err := writeCoverageFile(filepath.Join(c.folder, coverageCoberturaFile), func(w io.Writer) error {
+		return profile.WriteCobertura(w, paths, c.sourceRoot)
+	}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.tests) == 0 {
+		return nil
+	}
+	testsFolder := filepath.Join(c.folder, coverageTestsFolder)
+	if err := os.MkdirAll(testsFolder, 0755); err != nil {
+		return err
+	}
+	for fqn, testProfile := range c.tests {
+		fileName := coverageFileNameRegex.ReplaceAllString(fqn, "_") + ".out"
+		if err := writeCoverageFile(filepath.Join(testsFolder, fileName), testProfile.WriteGo); err != nil {
+			return err
+		}
+	}
+	c.logger.Printf("coverage export: %d test profiles written to %s", len(c.tests), testsFolder)
+	return nil
+}
+
+// Gets if the current coverage files were written by the same `go test` command (the parent process),
+// if not the run file is updated so the files are replaced
+func (c *coverageExport) isSameRun() bool {
+	runFile := filepath.Join(c.folder, coverageRunFile)
+	run := strconv.Itoa(os.Getppid())
+	if data, err := ioutil.ReadFile(runFile); err == nil && strings.TrimSpace(string(data)) == run {
+		return true
+	}
+	if err := ioutil.WriteFile(runFile, []byte(run), 0644); err != nil {
+		c.logger.Printf("coverage export: %v", err)
+	}
+	return false
+}
+
+func readCoverageProfile(path string) (*coverage.Profile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return coverage.ParseProfile(file)
+}
+
+func writeCoverageFile(path string, write func(w io.Writer) error) error {
+	var buffer bytes.Buffer
+	if err := write(&buffer); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buffer.Bytes())
+}
+
+// Creates a lock file shared by the test processes, the lock files older than the timeout are removed
+func lockFile(path string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_ = file.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > timeout {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for the lock file %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Gets the absolute path of the coverage export folder, `go test` runs each package in its folder so a relative
+// path is resolved against the source root to get the same folder (and merge the coverage) for all the packages
+func getCoverageExportFolder(folder string, sourceRoot string) string {
+	if filepath.IsAbs(folder) {
+		return folder
+	}
+	if sourceRoot != "" {
+		return filepath.Join(sourceRoot, folder)
+	}
+	if absFolder, err := filepath.Abs(folder); err == nil {
+		return absFolder
+	}
+	return folder
+}
+
+// Enables the coverage export
+func (a *Agent) setupCoverageExport(sourceRoot string) {
+	a.coverageExportPath = getCoverageExportFolder(a.coverageExportPath, sourceRoot)
+	a.optionalRecorders = append(a.optionalRecorders,
+		newCoverageExport(a.coverageExportPath, sourceRoot, a.coverageExportPerTest, a.logger))
+}
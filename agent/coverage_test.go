@@ -0,0 +1,21 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetCoverageExportFolder(t *testing.T) {
+	sourceRoot := filepath.Join(os.TempDir(), "module")
+	if folder := getCoverageExportFolder("coverage", sourceRoot); folder != filepath.Join(sourceRoot, "coverage") {
+		t.Fatalf("the relative folder must be resolved against the source root: %s", folder)
+	}
+	absFolder := filepath.Join(os.TempDir(), "coverage")
+	if folder := getCoverageExportFolder(absFolder, sourceRoot); folder != absFolder {
+		t.Fatalf("the absolute folder must not change: %s", folder)
+	}
+	if folder := getCoverageExportFolder("coverage", ""); !filepath.IsAbs(folder) {
+		t.Fatalf("the folder must be absolute without source root: %s", folder)
+	}
+}
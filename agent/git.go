@@ -37,6 +37,20 @@ type (
 		Status       string  `json:"status" msgpack:"status"`
 		PreviousPath *string `json:"previousPath" msgpack:"previousPath"`
 	}
+
+	// Changed lines of a file against a base commit
+	gitFileChanges struct {
+		Path    string
+		Added   bool
+		Deleted bool
+		Hunks   []gitDiffHunk
+	}
+	gitDiffHunk struct {
+		OldStart int
+		OldCount int
+		NewStart int
+		NewCount int
+	}
 )
 
 var (
@@ -45,6 +59,7 @@ var (
 	branchRegex = regexp.MustCompile(`(?m)^\[branch[ ]*\"(.*)\"[ ]*\]$`)
 	urlRegex    = regexp.MustCompile(`(?m)url[ ]*=[ ]*(.*)$`)
 	mergeRegex  = regexp.MustCompile(`(?m)merge[ ]*=[ ]*(.*)$`)
+	hunkRegex   = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
 )
 
 // Gets the current git data
@@ -201,6 +216,135 @@ func getGitDiff() *GitDiff {
 	return &gitDiff
 }
 
+// Gets the changed lines of the working tree against a base commit, by file path (relative to the repository root)
+func getGitChangedLines(dir string, baseCommit string) (map[string]*gitFileChanges, error) {
+	cmd := exec.Command("git", "diff", "--no-color", "--no-ext-diff", "--no-renames", "-U0",
+		"--src-prefix=a/", "--dst-prefix=b/", baseCommit)
+	cmd.Dir = dir
+	diffBytes, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	changes := parseGitChangedLines(string(diffBytes))
+
+	// New files not added to the index
+	cmd = exec.Command("git", "ls-files", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	if untrackedBytes, err := cmd.Output(); err == nil {
+		for _, path := range strings.Split(string(untrackedBytes), "\n") {
+			if path = strings.TrimSpace(path); path != "" {
+				changes[path] = &gitFileChanges{Path: path, Added: true}
+			}
+		}
+	}
+	return changes, nil
+}
+
+// Parses the output of `git diff -U0`
+func parseGitChangedLines(diff string) map[string]*gitFileChanges {
+	changes := map[string]*gitFileChanges{}
+	var current *gitFileChanges
+	var oldPath string
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = nil
+			oldPath = ""
+		case strings.HasPrefix(line, "--- "):
+			oldPath = strings.TrimPrefix(strings.TrimPrefix(line, "--- "), "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath := strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			current = &gitFileChanges{}
+			if oldPath == "/dev/null" {
+				current.Path = newPath
+				current.Added = true
+			} else {
+				current.Path = oldPath
+				current.Deleted = newPath == "/dev/null"
+			}
+			changes[current.Path] = current
+		case strings.HasPrefix(line, "Binary files "):
+			// Binary files don't have hunks, we mark them as fully changed
+			parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(line, "Binary files "), " differ"), " and ", 2)
+			if len(parts) == 2 {
+				path := strings.TrimPrefix(parts[0], "a/")
+				if path == "/dev/null" {
+					path = strings.TrimPrefix(parts[1], "b/")
+				}
+				changes[path] = &gitFileChanges{Path: path, Deleted: true}
+			}
+		case strings.HasPrefix(line, "@@ ") && current != nil:
+			match := hunkRegex.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			hunk := gitDiffHunk{OldCount: 1, NewCount: 1}
+			hunk.OldStart, _ = strconv.Atoi(match[1])
+			if match[2] != "" {
+				hunk.OldCount, _ = strconv.Atoi(match[2])
+			}
+			hunk.NewStart, _ = strconv.Atoi(match[3])
+			if match[4] != "" {
+				hunk.NewCount, _ = strconv.Atoi(match[4])
+			}
+			current.Hunks = append(current.Hunks, hunk)
+		}
+	}
+	return changes
+}
+
+// Gets if the lines range of the base commit has changed
+func (c *gitFileChanges) overlaps(startLine int, endLine int) bool {
+	if c.Added || c.Deleted {
+		return true
+	}
+	for _, hunk := range c.Hunks {
+		if hunk.OldCount == 0 {
+			// Lines inserted after OldStart
+			if startLine <= hunk.OldStart && endLine > hunk.OldStart {
+				return true
+			}
+		} else if startLine <= hunk.OldStart+hunk.OldCount-1 && endLine >= hunk.OldStart {
+			return true
+		}
+	}
+	return false
+}
+
+// Maps a line of the base commit (not changed) to the same line in the working tree
+func (c *gitFileChanges) mapLine(line int) int {
+	if c == nil {
+		return line
+	}
+	offset := 0
+	for _, hunk := range c.Hunks {
+		oldEnd := hunk.OldStart
+		if hunk.OldCount > 0 {
+			oldEnd = hunk.OldStart + hunk.OldCount - 1
+		}
+		if line > oldEnd {
+			offset += hunk.NewCount - hunk.OldCount
+		}
+	}
+	return line + offset
+}
+
+// Gets if the working tree has changes
+func isGitWorkingTreeDirty(dir string) bool {
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	statusBytes, err := cmd.Output()
+	return err != nil || len(strings.TrimSpace(string(statusBytes))) > 0
+}
+
+// Gets the git command output in the folder
+func getGitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
 func getGitInfoFromGitFolder() map[string]interface{} {
 	gitData := getGitData()
 
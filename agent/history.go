@@ -0,0 +1,338 @@
+package agent
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Persistent history of the test outcomes, used to detect the flaky tests across runs
+	testHistory struct {
+		logger        *log.Logger
+		folder        string
+		durationsFile string // Test durations exported to balance the shards
+
+		mu       sync.Mutex
+		attempts map[string][]*historyAttempts
+	}
+
+	// Test outcomes of a suite (a test process)
+	historySuite struct {
+		Suite string                  `json:"suite"`
+		Tests map[string][]historyRun `json:"tests"`
+	}
+
+	historyRun struct {
+		Time     time.Time     `json:"time"`
+		Status   string        `json:"status"`
+		Duration time.Duration `json:"duration,omitempty"`
+	}
+
+	// Statuses of the attempts (runner retries) of a run of a test, a process can run a test several times
+	// (`go test -count=N`)
+	historyAttempts struct {
+		suite    string
+		statuses []string
+		duration time.Duration
+	}
+)
+
+const (
+	// The test failed and passed in the same run
+	historyStatusFlaky = "FLAKY"
+
+	historyMaxRuns = 50
+	historyMinRuns = 5
+)
+
+// Creates the test history of the source root
+func newTestHistory(sourceRoot string, logger *log.Logger) (*testHistory, error) {
+	localFolder, err := getLocalFolder("history")
+	if err != nil {
+		return nil, err
+	}
+	return &testHistory{
+		logger:   logger,
+		folder:   filepath.Join(localFolder, fmt.Sprintf("%x", sha1.Sum([]byte(sourceRoot)))),
+		attempts: map[string][]*historyAttempts{},
+	}, nil
+}
+
+// Records the status of the test spans
+func (h *testHistory) RecordSpan(span tracer.RawSpan) {
+	if span.Tags["span.kind"] != "test" || span.Tags["test.type"] == "benchmark" {
+		return
+	}
+	suite, _ := span.Tags["test.suite"].(string)
+	name, _ := span.Tags["test.name"].(string)
+	status, _ := span.Tags["test.status"].(string)
+	if suite == "" || name == "" || strings.Contains(name, "/") {
+		return
+	}
+	if status != tags.TestStatus_PASS && status != tags.TestStatus_FAIL {
+		return
+	}
+	fqn := fmt.Sprintf("%s.%s", suite, name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	runs := h.attempts[fqn]
+	// The first attempt starts a new run of the test, the retries are added to the last one
+	if len(runs) == 0 || getTestAttempt(span.Tags) <= 1 {
+		runs = append(runs, &historyAttempts{suite: suite})
+		h.attempts[fqn] = runs
+	}
+	attempts := runs[len(runs)-1]
+	attempts.statuses = append(attempts.statuses, status)
+	attempts.duration = span.Duration
+}
+
+// Appends the outcomes of the current run to the history
+func (h *testHistory) Stop() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.attempts) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(h.folder, 0755); err != nil {
+		return err
+	}
+	now := time.Now()
+	suites := map[string]*historySuite{}
+	for fqn, testRuns := range h.attempts {
+		suiteName := testRuns[0].suite
+		suite, ok := suites[suiteName]
+		if !ok {
+			suite = h.readSuite(suiteName)
+			suites[suiteName] = suite
+		}
+		runs := suite.Tests[fqn]
+		for _, attempts := range testRuns {
+			runs = append(runs, historyRun{Time: now, Status: attempts.outcome(), Duration: attempts.duration})
+		}
+		if len(runs) > historyMaxRuns {
+			runs = runs[len(runs)-historyMaxRuns:]
+		}
+		suite.Tests[fqn] = runs
+	}
+	for _, suite := range suites {
+		data, err := json.Marshal(suite)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(h.suiteFile(suite.Suite), data); err != nil {
+			return err
+		}
+	}
+	h.attempts = map[string][]*historyAttempts{}
+	if h.durationsFile != "" {
+		return h.exportDurations(h.durationsFile)
+	}
+	return nil
+}
+
+// Writes the mean durations of the tests in the history to the durations file used to balance the shards,
+// the durations of the tests not in the history are kept
+func (h *testHistory) exportDurations(path string) error {
+	durations, err := loadShardDurations(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			h.logger.Printf("test history: the durations file %s is overwritten: %v", path, err)
+		}
+		durations = map[string]time.Duration{}
+	}
+	files, _ := filepath.Glob(filepath.Join(h.folder, "*.json"))
+	for _, file := range files {
+		suite, err := readHistorySuite(file)
+		if err != nil {
+			h.logger.Printf("test history: error reading %s: %v", file, err)
+			continue
+		}
+		for fqn, runs := range suite.Tests {
+			var total time.Duration
+			count := 0
+			for _, run := range runs {
+				if run.Duration > 0 {
+					total += run.Duration
+					count++
+				}
+			}
+			if count > 0 {
+				durations[fqn] = total / time.Duration(count)
+			}
+		}
+	}
+	values := make(map[string]string, len(durations))
+	for fqn, duration := range durations {
+		values[fqn] = duration.String()
+	}
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Gets the tests with a flakiness score greater or equal than the threshold
+func (h *testHistory) getFlakyTests(threshold float64) []string {
+	files, _ := filepath.Glob(filepath.Join(h.folder, "*.json"))
+	var fqns []string
+	for _, file := range files {
+		suite, err := readHistorySuite(file)
+		if err != nil {
+			h.logger.Printf("test history: error reading %s: %v", file, err)
+			continue
+		}
+		for fqn, runs := range suite.Tests {
+			if len(runs) < historyMinRuns {
+				continue
+			}
+			if score := flakinessScore(runs); score >= threshold {
+				h.logger.Printf("test history: '%s' is flaky with a score of %.2f", fqn, score)
+				fqns = append(fqns, fqn)
+			}
+		}
+	}
+	sort.Strings(fqns)
+	return fqns
+}
+
+func (h *testHistory) suiteFile(suite string) string {
+	return filepath.Join(h.folder, fmt.Sprintf("%x.json", sha1.Sum([]byte(suite))))
+}
+
+func (h *testHistory) readSuite(suite string) *historySuite {
+	if hSuite, err := readHistorySuite(h.suiteFile(suite)); err == nil {
+		return hSuite
+	}
+	return &historySuite{Suite: suite, Tests: map[string][]historyRun{}}
+}
+
+func readHistorySuite(file string) (*historySuite, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	suite := &historySuite{}
+	if err := json.Unmarshal(data, suite); err != nil {
+		return nil, err
+	}
+	if suite.Tests == nil {
+		suite.Tests = map[string][]historyRun{}
+	}
+	return suite, nil
+}
+
+// Gets the outcome of the test in the current run
+func (a *historyAttempts) outcome() string {
+	last := a.statuses[len(a.statuses)-1]
+	if last == tags.TestStatus_PASS {
+		for _, status := range a.statuses {
+			if status == tags.TestStatus_FAIL {
+				return historyStatusFlaky
+			}
+		}
+	}
+	return last
+}
+
+// Gets the attempt of a test span (runner retries), 0 if the test is not retried
+func getTestAttempt(spanTags map[string]interface{}) int {
+	switch value := spanTags[tags.TestAttempt].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	}
+	return 0
+}
+
+// Gets the flakiness score of a test (between 0 and 1): the ratio of flaky runs and outcome changes between runs
+func flakinessScore(runs []historyRun) float64 {
+	if len(runs) == 0 {
+		return 0
+	}
+	changes := 0
+	for i, run := range runs {
+		if run.Status == historyStatusFlaky {
+			changes++
+		} else if i > 0 && runs[i-1].Status != historyStatusFlaky && runs[i-1].Status != run.Status {
+			changes++
+		}
+	}
+	score := float64(changes) / float64(len(runs))
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
+
+// Loads a quarantine file: a test (`{package}.{test name}`) per line, the lines starting with `#` are comments
+func loadQuarantineFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var fqns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fqns = append(fqns, line)
+	}
+	return fqns, scanner.Err()
+}
+
+// Enables the test history recorder
+func (a *Agent) setupTestHistory(sourceRoot string) {
+	history, err := newTestHistory(sourceRoot, a.logger)
+	if err != nil {
+		a.logger.Printf("test history: %v", err)
+		return
+	}
+	a.testHistory = history
+	a.optionalRecorders = append(a.optionalRecorders, history)
+}
+
+// Adds the tests of the remote configuration, the quarantine file and the flaky tests of the history to the
+// quarantined tests (must be called after loading the remote configuration)
+func (a *Agent) applyQuarantine() {
+	if !a.quarantineEnabled {
+		return
+	}
+	quarantined := config.GetRemoteQuarantinedTests()
+	if a.quarantineFile != "" {
+		fqns, err := loadQuarantineFile(a.quarantineFile)
+		if err != nil {
+			a.logger.Printf("error loading the quarantine file: %v", err)
+		}
+		quarantined = append(quarantined, fqns...)
+	}
+	if a.testHistory != nil {
+		quarantined = append(quarantined, a.testHistory.getFlakyTests(a.flakyThreshold)...)
+	}
+	if len(quarantined) > 0 {
+		config.AddQuarantinedTests(quarantined...)
+	}
+	a.logger.Printf("quarantine: %d tests are quarantined", len(config.GetQuarantinedTestsMap()))
+}
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func TestFlakinessScore(t *testing.T) {
+	runs := func(statuses ...string) []historyRun {
+		var runs []historyRun
+		for _, status := range statuses {
+			runs = append(runs, historyRun{Status: status})
+		}
+		return runs
+	}
+	cases := []struct {
+		runs  []historyRun
+		score float64
+	}{
+		{runs(tags.TestStatus_PASS, tags.TestStatus_PASS, tags.TestStatus_PASS, tags.TestStatus_PASS), 0},
+		{runs(tags.TestStatus_FAIL, tags.TestStatus_FAIL, tags.TestStatus_FAIL, tags.TestStatus_FAIL), 0},
+		{runs(tags.TestStatus_PASS, tags.TestStatus_FAIL, tags.TestStatus_PASS, tags.TestStatus_PASS), 0.5},
+		{runs(tags.TestStatus_PASS, historyStatusFlaky, tags.TestStatus_PASS, tags.TestStatus_PASS), 0.25},
+	}
+	for _, c := range cases {
+		if score := flakinessScore(c.runs); score != c.score {
+			t.Errorf("unexpected score %v for %v, expected %v", score, c.runs, c.score)
+		}
+	}
+}
+
+func TestTestHistory(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	h := &testHistory{
+		logger:   log.New(ioutil.Discard, "", 0),
+		folder:   filepath.Join(folder, "history"),
+		attempts: map[string][]*historyAttempts{},
+	}
+	// Records the attempts (runner retries) of a test run
+	record := func(name string, statuses ...string) {
+		for idx, status := range statuses {
+			spanTags := opentracing.Tags{
+				"span.kind":   "test",
+				"test.suite":  "pkg",
+				"test.name":   name,
+				"test.status": status,
+			}
+			if len(statuses) > 1 {
+				spanTags[tags.TestAttempt] = idx + 1
+			}
+			h.RecordSpan(tracer.RawSpan{Tags: spanTags})
+		}
+	}
+	for i := 0; i < historyMinRuns; i++ {
+		record("TestStable", tags.TestStatus_PASS)
+		record("TestStable/Sub", tags.TestStatus_FAIL)
+		if i%2 == 0 {
+			record("TestFlaky", tags.TestStatus_FAIL, tags.TestStatus_PASS)
+		} else {
+			record("TestFlaky", tags.TestStatus_PASS)
+		}
+		if err := h.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if flaky := h.getFlakyTests(0.5); !reflect.DeepEqual(flaky, []string{"pkg.TestFlaky"}) {
+		t.Fatalf("unexpected flaky tests: %v", flaky)
+	}
+}
+
+func TestTestHistoryCount(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	h := &testHistory{
+		logger:   log.New(ioutil.Discard, "", 0),
+		folder:   filepath.Join(folder, "history"),
+		attempts: map[string][]*historyAttempts{},
+	}
+	record := func(name string, attempt int, status string) {
+		spanTags := opentracing.Tags{
+			"span.kind":   "test",
+			"test.suite":  "pkg",
+			"test.name":   name,
+			"test.status": status,
+		}
+		if attempt > 0 {
+			spanTags[tags.TestAttempt] = attempt
+		}
+		h.RecordSpan(tracer.RawSpan{Tags: spanTags})
+	}
+
+	// `go test -count=3` without retries, and with a retry in the second run
+	for i := 0; i < 3; i++ {
+		record("TestCount", 0, tags.TestStatus_PASS)
+	}
+	record("TestCountRetried", 1, tags.TestStatus_PASS)
+	record("TestCountRetried", 1, tags.TestStatus_FAIL)
+	record("TestCountRetried", 2, tags.TestStatus_PASS)
+	record("TestCountRetried", 1, tags.TestStatus_FAIL)
+	if err := h.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	suite := h.readSuite("pkg")
+	statuses := func(fqn string) []string {
+		var statuses []string
+		for _, run := range suite.Tests[fqn] {
+			statuses = append(statuses, run.Status)
+		}
+		return statuses
+	}
+	if runs := statuses("pkg.TestCount"); !reflect.DeepEqual(runs, []string{tags.TestStatus_PASS, tags.TestStatus_PASS, tags.TestStatus_PASS}) {
+		t.Fatalf("unexpected runs of TestCount: %v", runs)
+	}
+	if runs := statuses("pkg.TestCountRetried"); !reflect.DeepEqual(runs, []string{tags.TestStatus_PASS, historyStatusFlaky, tags.TestStatus_FAIL}) {
+		t.Fatalf("unexpected runs of TestCountRetried: %v", runs)
+	}
+}
+
+func TestLoadQuarantineFile(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-quarantine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	path := filepath.Join(folder, "quarantine.txt")
+	content := "# flaky tests\npkg.TestA\n\n  pkg/sub.TestB  \n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fqns, err := loadQuarantineFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fqns, []string{"pkg.TestA", "pkg/sub.TestB"}) {
+		t.Fatalf("unexpected tests: %v", fqns)
+	}
+	if _, err := loadQuarantineFile(filepath.Join(folder, "not-exists.txt")); err == nil {
+		t.Fatal("an error was expected for a missing file")
+	}
+}
+
+func TestApplyRemoteQuarantine(t *testing.T) {
+	remoteConfig := instrumentation.GetRemoteConfiguration()
+	defer instrumentation.SetRemoteConfiguration(remoteConfig)
+	instrumentation.SetRemoteConfiguration(map[string]interface{}{
+		"quarantined": []interface{}{
+			"pkg.TestRemoteA",
+			map[string]interface{}{"test_suite": "pkg", "test_name": "TestRemoteB"},
+		},
+	})
+
+	agent := &Agent{logger: log.New(ioutil.Discard, "", 0)}
+	agent.applyQuarantine()
+	if config.IsTestQuarantined("pkg.TestRemoteA") {
+		t.Fatal("the remote configuration must not quarantine tests if the quarantine mode is not enabled")
+	}
+
+	agent.quarantineEnabled = true
+	agent.applyQuarantine()
+	if !config.IsTestQuarantined("pkg.TestRemoteA") || !config.IsTestQuarantined("pkg.TestRemoteB") {
+		t.Fatalf("the remote quarantined tests are missing: %v", config.GetQuarantinedTestsMap())
+	}
+}
@@ -0,0 +1,379 @@
+package agent
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation/coverage"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/reporters"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Local test impact analysis: stores the coverage of each test by commit, and skips the tests
+	// whose covered code has not changed against the base commit
+	testImpact struct {
+		logger     *log.Logger
+		folder     string
+		repoRoot   string
+		commit     string
+		dirty      bool
+		baseCommit string
+		baseSuites map[string]*impactSuite
+		changes    map[string]*gitFileChanges
+		skipped    map[string]bool
+
+		mu       sync.Mutex
+		suites   map[string]bool
+		passed   map[string]*impactTest
+		notValid map[string]bool
+	}
+
+	// Coverage data of the tests of a package (a test process) in a commit
+	impactSuite struct {
+		Commit string                 `json:"commit"`
+		Suite  string                 `json:"suite"`
+		Files  []string               `json:"files"`
+		Tests  map[string]*impactTest `json:"tests"`
+	}
+
+	impactTest struct {
+		Suite     string              `json:"suite"`
+		Name      string              `json:"name"`
+		CodeFile  string              `json:"code_file,omitempty"`
+		CodeLines [2]int              `json:"code_lines,omitempty"`
+		Coverage  map[string][][2]int `json:"coverage"`
+	}
+)
+
+const (
+	impactBaseCommitsLimit = 100
+	impactMaxCommits       = 50
+)
+
+// Creates the test impact analysis for the repository in the current folder, the base ref is used to find
+// the previous coverage data (the nearest ancestor commit with data)
+func newTestImpact(baseRef string, logger *log.Logger) (*testImpact, error) {
+	repoRoot, err := getGitOutput("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("the current folder is not a git repository: %v", err)
+	}
+	commit, err := getGitOutput(repoRoot, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	localFolder, err := getLocalFolder("impact")
+	if err != nil {
+		return nil, err
+	}
+	folder := filepath.Join(localFolder, fmt.Sprintf("%x", sha1.Sum([]byte(repoRoot))))
+	ti := &testImpact{
+		logger:     logger,
+		folder:     folder,
+		repoRoot:   repoRoot,
+		commit:     commit,
+		dirty:      isGitWorkingTreeDirty(repoRoot),
+		baseSuites: map[string]*impactSuite{},
+		skipped:    map[string]bool{},
+		suites:     map[string]bool{},
+		passed:     map[string]*impactTest{},
+		notValid:   map[string]bool{},
+	}
+	if baseRef == "" {
+		baseRef = "HEAD"
+	}
+	ti.loadBase(baseRef)
+	return ti, nil
+}
+
+// Loads the coverage data of the nearest ancestor of the base ref with data
+func (ti *testImpact) loadBase(baseRef string) {
+	revs, err := getGitOutput(ti.repoRoot, "rev-list", fmt.Sprintf("--max-count=%d", impactBaseCommitsLimit), baseRef)
+	if err != nil {
+		ti.logger.Printf("test impact: error resolving the base ref '%s': %v", baseRef, err)
+		return
+	}
+	for _, rev := range strings.Split(revs, "\n") {
+		files, _ := filepath.Glob(filepath.Join(ti.folder, rev, "*.json"))
+		if len(files) == 0 {
+			continue
+		}
+		changes, err := getGitChangedLines(ti.repoRoot, rev)
+		if err != nil {
+			ti.logger.Printf("test impact: error getting the changes against %s: %v", rev, err)
+			return
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			suite := &impactSuite{}
+			if err := json.Unmarshal(data, suite); err != nil {
+				ti.logger.Printf("test impact: error reading %s: %v", file, err)
+				continue
+			}
+			ti.baseSuites[suite.Suite] = suite
+		}
+		ti.baseCommit = rev
+		ti.changes = changes
+		ti.logger.Printf("test impact: using the coverage data of commit %s with %d changed files", rev, len(changes))
+		return
+	}
+	ti.logger.Printf("test impact: there isn't coverage data for '%s', all tests will run", baseRef)
+}
+
+// Gets the tests that can be skipped because their covered code has not changed
+func (ti *testImpact) getSkippableTests() []string {
+	var fqns []string
+	for _, suite := range ti.baseSuites {
+		for fqn, test := range suite.Tests {
+			if !ti.isAffected(suite, test) {
+				ti.skipped[fqn] = true
+				fqns = append(fqns, fqn)
+			}
+		}
+	}
+	sort.Strings(fqns)
+	return fqns
+}
+
+// Gets if a test is affected by the changes against the base commit
+func (ti *testImpact) isAffected(suite *impactSuite, test *impactTest) bool {
+	instrumented := map[string]bool{}
+	for _, file := range suite.Files {
+		instrumented[file] = true
+	}
+	testDir := path.Dir(test.CodeFile)
+	for filePath, change := range ti.changes {
+		base := path.Base(filePath)
+		if base == "go.mod" || base == "go.sum" {
+			return true
+		}
+		if filePath == test.CodeFile {
+			if change.overlaps(test.CodeLines[0], test.CodeLines[1]) {
+				return true
+			}
+			continue
+		}
+		if !strings.HasSuffix(filePath, ".go") {
+			// Non go files in the test package can be test data
+			if test.CodeFile == "" || strings.HasPrefix(filePath, testDir+"/") {
+				return true
+			}
+			continue
+		}
+		if strings.HasSuffix(filePath, "_test.go") {
+			// Test files are not instrumented, changes in the same package can affect the test (ex: helpers)
+			if test.CodeFile == "" || path.Dir(filePath) == testDir {
+				return true
+			}
+			continue
+		}
+		if !instrumented[filePath] {
+			// We don't have coverage data of this file, so we can't know if the test uses it
+			return true
+		}
+		for _, lines := range test.Coverage[filePath] {
+			if change.overlaps(lines[0], lines[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Records the coverage of the test spans
+func (ti *testImpact) RecordSpan(span tracer.RawSpan) {
+	if span.Tags["span.kind"] != "test" || span.Tags["test.type"] == "benchmark" {
+		return
+	}
+	suite, _ := span.Tags["test.suite"].(string)
+	name, _ := span.Tags["test.name"].(string)
+	if suite == "" || name == "" {
+		return
+	}
+	// Sub tests are stored in the parent test
+	name = strings.SplitN(name, "/", 2)[0]
+	fqn := fmt.Sprintf("%s.%s", suite, name)
+
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	ti.suites[suite] = true
+	switch span.Tags["test.status"] {
+	case tags.TestStatus_PASS:
+	case tags.TestStatus_CACHE, tags.TestStatus_SKIP:
+		return
+	default:
+		ti.notValid[fqn] = true
+		return
+	}
+	cov, ok := span.Tags[tags.Coverage].(interface{ CoveredLines() map[string][][2]int })
+	if !ok {
+		// Without coverage (ex: parallel tests) we can't know the code used by the test
+		ti.notValid[fqn] = true
+		return
+	}
+	test, ok := ti.passed[fqn]
+	if !ok {
+		test = &impactTest{Suite: suite, Name: name, Coverage: map[string][][2]int{}}
+		ti.passed[fqn] = test
+	}
+	if code, ok := span.Tags["test.code"].(string); ok && test.CodeFile == "" {
+		if file, start, end, ok := reporters.ParseCodeBoundaries(code); ok {
+			test.CodeFile = ti.relativePath(file)
+			test.CodeLines = [2]int{start, end}
+		}
+	}
+	for file, lines := range cov.CoveredLines() {
+		relFile := ti.relativePath(file)
+		test.Coverage[relFile] = append(test.Coverage[relFile], lines...)
+	}
+}
+
+// Stores the coverage data of the current commit
+func (ti *testImpact) Stop() error {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	if ti.dirty {
+		ti.logger.Println("test impact: the working tree has changes, the coverage data is not stored")
+		return nil
+	}
+	instrumentedFiles := coverage.GetInstrumentedFiles()
+	if len(instrumentedFiles) == 0 {
+		ti.logger.Println("test impact: the coverage is not enabled, the coverage data is not stored")
+		return nil
+	}
+	files := make([]string, 0, len(instrumentedFiles))
+	for _, file := range instrumentedFiles {
+		files = append(files, ti.relativePath(file))
+	}
+
+	commitFolder := filepath.Join(ti.folder, ti.commit)
+	if err := os.MkdirAll(commitFolder, 0755); err != nil {
+		return err
+	}
+	for suiteName := range ti.suites {
+		suite := &impactSuite{
+			Commit: ti.commit,
+			Suite:  suiteName,
+			Files:  files,
+			Tests:  map[string]*impactTest{},
+		}
+		for fqn, test := range ti.passed {
+			if test.Suite == suiteName && !ti.notValid[fqn] {
+				suite.Tests[fqn] = test
+			}
+		}
+		// Tests not executed (skipped by this analysis or filtered) keep the base coverage data
+		if baseSuite, ok := ti.baseSuites[suiteName]; ok {
+			for fqn, test := range baseSuite.Tests {
+				if _, ok := suite.Tests[fqn]; ok || ti.notValid[fqn] || ti.isAffected(baseSuite, test) {
+					continue
+				}
+				suite.Tests[fqn] = ti.mapTest(test)
+			}
+		}
+		data, err := json.Marshal(suite)
+		if err != nil {
+			return err
+		}
+		fileName := filepath.Join(commitFolder, fmt.Sprintf("%x.json", sha1.Sum([]byte(suiteName))))
+		if err := writeFileAtomic(fileName, data); err != nil {
+			return err
+		}
+	}
+	ti.pruneCommits()
+	return nil
+}
+
+// Maps the lines of a not affected test from the base commit to the current commit
+func (ti *testImpact) mapTest(test *impactTest) *impactTest {
+	mapped := &impactTest{
+		Suite:    test.Suite,
+		Name:     test.Name,
+		CodeFile: test.CodeFile,
+		Coverage: map[string][][2]int{},
+	}
+	codeChanges := ti.changes[test.CodeFile]
+	mapped.CodeLines = [2]int{codeChanges.mapLine(test.CodeLines[0]), codeChanges.mapLine(test.CodeLines[1])}
+	for file, ranges := range test.Coverage {
+		changes := ti.changes[file]
+		for _, lines := range ranges {
+			mapped.Coverage[file] = append(mapped.Coverage[file], [2]int{changes.mapLine(lines[0]), changes.mapLine(lines[1])})
+		}
+	}
+	return mapped
+}
+
+// Removes the data of the oldest commits
+func (ti *testImpact) pruneCommits() {
+	pruneCommitFolders(ti.folder, impactMaxCommits)
+}
+
+// Gets the path relative to the repository root
+func (ti *testImpact) relativePath(file string) string {
+	if rel, err := filepath.Rel(ti.repoRoot, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(file)
+}
+
+// Removes the oldest commit folders, keeping the newest max folders
+func pruneCommitFolders(folder string, max int) {
+	entries, err := ioutil.ReadDir(folder)
+	if err != nil || len(entries) <= max {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().After(entries[j].ModTime())
+	})
+	for _, entry := range entries[max:] {
+		_ = os.RemoveAll(filepath.Join(folder, entry.Name()))
+	}
+}
+
+// Writes a file using a temp file and a rename, so readers never get a partial file
+func writeFileAtomic(fileName string, data []byte) error {
+	tmpFile := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, fileName)
+}
+
+// Enables the test impact analysis, the recorder stores the coverage of the tests of this run
+func (a *Agent) setupTestImpact() {
+	ti, err := newTestImpact(a.testImpactBaseRef, a.logger)
+	if err != nil {
+		a.logger.Printf("test impact: %v", err)
+		return
+	}
+	a.testImpact = ti
+	a.optionalRecorders = append(a.optionalRecorders, ti)
+}
+
+// Adds the tests not affected by the changes to the cached tests (must be called after loading the remote configuration)
+func (a *Agent) applyTestImpact() {
+	if a.testImpact == nil {
+		return
+	}
+	skippable := a.testImpact.getSkippableTests()
+	if len(skippable) > 0 {
+		config.AddCachedTests(skippable...)
+	}
+	a.setMetadata(tags.TestImpactBaseCommit, a.testImpact.baseCommit)
+	a.setMetadata(tags.TestImpactSkippedTests, len(skippable))
+	a.logger.Printf("test impact: %d tests are not affected by the changes and will be skipped", len(skippable))
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"testing"
+)
+
+const impactDiff = `diff --git a/pkg/math.go b/pkg/math.go
+index 1111111..2222222 100644
+--- a/pkg/math.go
++++ b/pkg/math.go
+@@ -10,2 +10,3 @@ func Add(a, b int) int {
+-	return a + b
+-}
++	c := a + b
++	return c
++}
+@@ -30,0 +32,2 @@ func Sub(a, b int) int {
++// Mul multiplies two numbers
++func Mul(a, b int) int { return a * b }
+diff --git a/pkg/data.txt b/pkg/data.txt
+deleted file mode 100644
+index 3333333..0000000
+--- a/pkg/data.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-data
+`
+
+func TestParseGitChangedLines(t *testing.T) {
+	changes := parseGitChangedLines(impactDiff)
+	math, ok := changes["pkg/math.go"]
+	if !ok || len(math.Hunks) != 2 || math.Added || math.Deleted {
+		t.Fatalf("unexpected changes: %+v", math)
+	}
+	if data, ok := changes["pkg/data.txt"]; !ok || !data.Deleted {
+		t.Fatalf("the deleted file has not been detected: %+v", data)
+	}
+
+	cases := []struct {
+		start, end int
+		overlaps   bool
+	}{
+		{1, 9, false},
+		{5, 10, true},
+		{11, 20, true},
+		{12, 29, false},
+		{25, 31, true},
+		{31, 40, false},
+	}
+	for _, c := range cases {
+		if math.overlaps(c.start, c.end) != c.overlaps {
+			t.Errorf("overlaps(%d, %d) must be %v", c.start, c.end, c.overlaps)
+		}
+	}
+
+	for line, expected := range map[int]int{5: 5, 20: 21, 30: 31, 31: 34} {
+		if mapped := math.mapLine(line); mapped != expected {
+			t.Errorf("mapLine(%d) = %d, expected %d", line, mapped, expected)
+		}
+	}
+	var notChanged *gitFileChanges
+	if notChanged.mapLine(42) != 42 {
+		t.Error("the lines of a file without changes must not be mapped")
+	}
+}
+
+func TestTestImpactIsAffected(t *testing.T) {
+	ti := &testImpact{changes: parseGitChangedLines(impactDiff)}
+	suite := &impactSuite{Files: []string{"pkg/math.go", "other/other.go"}}
+	affected := &impactTest{
+		CodeFile:  "pkg/math_test.go",
+		CodeLines: [2]int{5, 10},
+		Coverage:  map[string][][2]int{"pkg/math.go": {{9, 12}}},
+	}
+	if !ti.isAffected(suite, affected) {
+		t.Error("the test covering a changed line must be affected")
+	}
+	notAffected := &impactTest{
+		CodeFile:  "other/other_test.go",
+		CodeLines: [2]int{5, 10},
+		Coverage:  map[string][][2]int{"pkg/math.go": {{1, 5}}, "other/other.go": {{1, 100}}},
+	}
+	if ti.isAffected(suite, notAffected) {
+		t.Error("the test not covering the changed lines must not be affected")
+	}
+	dataTest := &impactTest{CodeFile: "pkg/data_test.go", CodeLines: [2]int{1, 5}}
+	if !ti.isAffected(suite, dataTest) {
+		t.Error("the test in a folder with a changed data file must be affected")
+	}
+}
@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const retryBackoff = 1 * time.Second
+const numOfRetries = 3
+
+type (
+	// Client of the Scope ingest endpoint
+	Client struct {
+		Url        string
+		ApiKey     string
+		UserAgent  string
+		DebugMode  bool
+		Logger     *log.Logger
+		HttpClient *http.Client
+		OnRetry    func() // Called before each retry of a payload
+	}
+)
+
+// Sends the encoded `payload` to the Scope ingest endpoint
+func (c *Client) Send(payload *bytes.Buffer, agentId string, batchId string) (statusCode int, err error) {
+	payloadBytes := payload.Bytes()
+	var lastError error
+	for i := 0; i <= numOfRetries; i++ {
+		req, err := http.NewRequest("POST", c.Url, bytes.NewBuffer(payloadBytes))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("User-Agent", c.UserAgent)
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("X-Scope-ApiKey", c.ApiKey)
+		req.Header.Set("X-Scope-Agent-Id", agentId)
+		req.Header.Set("X-Scope-Batch-Id", batchId)
+
+		if c.DebugMode {
+			if i == 0 {
+				c.Logger.Println("sending payload")
+			} else {
+				c.Logger.Printf("sending payload [retry %d]", i)
+			}
+		}
+
+		resp, err := c.HttpClient.Do(req)
+		if err != nil {
+			if v, ok := err.(*url.Error); ok {
+				// Don't retry if the error was due to TLS cert verification failure.
+				if _, ok := v.Err.(x509.UnknownAuthorityError); ok {
+					return 0, errors.New(fmt.Sprintf("error: http client returns: %s", err.Error()))
+				}
+			}
+
+			lastError = err
+			c.Logger.Printf("client error '%s', retrying in %d seconds", err.Error(), retryBackoff/time.Second)
+			c.retry()
+			continue
+		}
+
+		var (
+			bodyData []byte
+			status   string
+		)
+		statusCode = resp.StatusCode
+		status = resp.Status
+		if resp.Body != nil && resp.Body != http.NoBody {
+			body, err := ioutil.ReadAll(resp.Body)
+			if err == nil {
+				bodyData = body
+			}
+		}
+		if err := resp.Body.Close(); err != nil { // We can't defer inside a for loop
+			c.Logger.Printf("error: closing the response body. %s", err.Error())
+		}
+
+		if statusCode == 0 || statusCode >= 400 {
+			lastError = errors.New(fmt.Sprintf("error from API [status: %s]: %s", status, string(bodyData)))
+		}
+
+		// Check the response code. We retry on 500-range responses to allow
+		// the server time to recover, as 500's are typically not permanent
+		// errors and may relate to outages on the server side. This will catch
+		// invalid response codes as well, like 0 and 999.
+		if statusCode == 0 || (statusCode >= 500 && statusCode != 501) {
+			c.Logger.Printf("error: [status code: %d], retrying in %d seconds", statusCode, retryBackoff/time.Second)
+			c.retry()
+			continue
+		}
+
+		if i > 0 {
+			c.Logger.Printf("payload was sent successfully after retry.")
+		}
+		break
+	}
+
+	if statusCode != 0 && statusCode < 400 {
+		return statusCode, nil
+	}
+	return statusCode, lastError
+}
+
+// Waits before retrying a payload
+func (c *Client) retry() {
+	time.Sleep(retryBackoff)
+	if c.OnRetry != nil {
+		c.OnRetry()
+	}
+}
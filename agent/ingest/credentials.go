@@ -0,0 +1,78 @@
+package ingest
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"path"
+
+	"go.undefinedlabs.com/scopeagent/env"
+)
+
+// Parses a Scope DSN returning the api key and the api endpoint
+func ParseDSN(dsnString string) (apiKey string, apiEndpoint string, err error) {
+	uri, err := url.Parse(dsnString)
+	if err != nil {
+		return "", "", err
+	}
+	if uri.User != nil {
+		apiKey = uri.User.Username()
+	}
+	uri.User = nil
+	apiEndpoint = uri.String()
+	return
+}
+
+// Loads the api key and api endpoint not set from the environment variables or the native app configuration
+func LoadCredentials(apiKey string, apiEndpoint string, logger *log.Logger) (string, string, error) {
+	configProfile := GetConfigCurrentProfile()
+
+	if apiKey == "" || apiEndpoint == "" {
+		if dsn, set := env.ScopeDsn.Tuple(); set && dsn != "" {
+			dsnApiKey, dsnApiEndpoint, dsnErr := ParseDSN(dsn)
+			if dsnErr != nil {
+				logger.Printf("Error parsing dsn value: %v\n", dsnErr)
+			} else {
+				apiKey = dsnApiKey
+				apiEndpoint = dsnApiEndpoint
+			}
+		} else {
+			logger.Println("environment variable $SCOPE_DSN not found")
+		}
+	}
+
+	if apiEndpoint == "" {
+		if endpoint, set := env.ScopeApiEndpoint.Tuple(); set && endpoint != "" {
+			apiEndpoint = endpoint
+		} else if configProfile != nil {
+			logger.Println("API endpoint found in the native app configuration")
+			apiEndpoint = configProfile.ApiEndpoint
+		} else {
+			logger.Printf("using default endpoint: %v\n", endpoint)
+			apiEndpoint = endpoint
+		}
+	}
+
+	if apiKey == "" {
+		if envApiKey, set := env.ScopeApiKey.Tuple(); set && envApiKey != "" {
+			apiKey = envApiKey
+		} else if configProfile != nil {
+			logger.Println("API key found in the native app configuration")
+			apiKey = configProfile.ApiKey
+		} else {
+			logger.Println("API key not found, agent can't be started")
+			return apiKey, apiEndpoint, errors.New("Scope DSN not found. Tests will run but no results will be reported to Scope. More info at https://docs.scope.dev/")
+		}
+	}
+	return apiKey, apiEndpoint, nil
+}
+
+// Gets the url of a path of the api endpoint
+func GetUrl(apiEndpoint string, pathValue string) (string, error) {
+	uri, err := url.Parse(apiEndpoint)
+	if err != nil {
+		return "", err
+	}
+	uri.Path = path.Join(uri.Path, pathValue)
+	return uri.String(), nil
+}
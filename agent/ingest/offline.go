@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/env"
+)
+
+type (
+	// Options of the upload of the payloads exported in offline mode
+	UploadOptions struct {
+		ApiKey      string
+		ApiEndpoint string
+		UserAgent   string
+		DebugMode   bool
+		Logger      *log.Logger
+	}
+
+	// Result of uploading the payloads exported in offline mode
+	OfflineUploadResult struct {
+		Sent       int
+		Rejected   int
+		Pending    int
+		ResultUrls []string
+	}
+)
+
+// Uploads the payloads exported in offline mode to the Scope ingest endpoint.
+// The api key and endpoint not set in the options are loaded from the environment variables or the native app configuration.
+func UploadOfflineExport(path string, options UploadOptions) (*OfflineUploadResult, error) {
+	if path == "" {
+		return nil, errors.New("the offline export path is empty")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	logger := options.Logger
+	if logger == nil {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	userAgent := options.UserAgent
+	if userAgent == "" {
+		userAgent = "scope-upload"
+	}
+	debugMode := options.DebugMode || env.ScopeDebug.Value
+	apiKey, apiEndpoint, err := LoadCredentials(options.ApiKey, options.ApiEndpoint, logger)
+	if err != nil {
+		return nil, err
+	}
+	ingestUrl, err := GetUrl(apiEndpoint, "api/agent/ingest")
+	if err != nil {
+		return nil, err
+	}
+
+	exported, err := OpenSpool(path, debugMode, logger)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		Url:        ingestUrl,
+		ApiKey:     apiKey,
+		UserAgent:  userAgent,
+		DebugMode:  debugMode,
+		Logger:     logger,
+		HttpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+
+	result := &OfflineUploadResult{}
+	agentIds := map[string]struct{}{}
+	items := exported.ClaimPending()
+	var lastError error
+	for idx, item := range items {
+		payload, err := exported.Read(item)
+		if err != nil {
+			logger.Printf("offline upload: error reading batch %s: %v", item.Name(), err)
+			exported.Release(item)
+			result.Pending++
+			continue
+		}
+		logger.Printf("offline upload: sending batch %s", item.Name())
+		statusCode, err := client.Send(payload, item.AgentId, item.BatchId)
+		if exported.Settle(item, statusCode, err) {
+			// The backend is unreachable, the remaining batches are kept for a later upload
+			for _, remain := range items[idx+1:] {
+				exported.Release(remain)
+			}
+			result.Pending += len(items) - idx
+			lastError = err
+			break
+		}
+		if err != nil {
+			result.Rejected++
+			lastError = err
+		} else {
+			result.Sent++
+			agentIds[item.AgentId] = struct{}{}
+		}
+	}
+
+	for agentId := range agentIds {
+		if resultUrl, err := GetUrl(apiEndpoint, fmt.Sprintf("external/v1/results/%s", agentId)); err == nil {
+			result.ResultUrls = append(result.ResultUrls, resultUrl)
+		}
+	}
+	sort.Strings(result.ResultUrls)
+	return result, lastError
+}
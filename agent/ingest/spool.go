@@ -0,0 +1,273 @@
+package ingest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Extension of the batches pending to be sent
+	SpoolBatchExt = ".batch"
+
+	spoolSendingExt = ".sending"
+	spoolTmpExt     = ".tmp"
+	spoolSentFolder = "sent"
+
+	// Claimed batches older than this value are considered abandoned (the process crashed while sending)
+	spoolClaimTimeout = 10 * time.Minute
+	// Batches and sent marks older than this value are removed from the spool
+	spoolMaxAge = 7 * 24 * time.Hour
+)
+
+type (
+	// Durable on-disk storage of the ingest batches pending to be sent
+	Spool struct {
+		path      string
+		debugMode bool
+		logger    *log.Logger
+	}
+	SpoolItem struct {
+		AgentId string
+		BatchId string
+		path    string
+	}
+)
+
+// Creates a new spool for the tenant (api endpoint and api key) in the given folder
+func NewSpool(folder string, apiEndpoint string, apiKey string, debugMode bool, logger *log.Logger) (*Spool, error) {
+	// Batches can only be replayed with the same credentials used to create them
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(apiEndpoint+"|"+apiKey)))
+	return OpenSpool(filepath.Join(folder, hash), debugMode, logger)
+}
+
+// Opens a spool in the given path
+func OpenSpool(path string, debugMode bool, logger *log.Logger) (*Spool, error) {
+	if err := os.MkdirAll(filepath.Join(path, spoolSentFolder), 0755); err != nil {
+		return nil, err
+	}
+	s := &Spool{
+		path:      path,
+		debugMode: debugMode,
+		logger:    logger,
+	}
+	s.removeStaleTmpFiles()
+	return s, nil
+}
+
+// Writes a batch to the spool already claimed by the current process
+func (s *Spool) Write(agentId string, batchId string, payload *bytes.Buffer) (*SpoolItem, error) {
+	item := &SpoolItem{
+		AgentId: agentId,
+		BatchId: batchId,
+	}
+	item.path = filepath.Join(s.path, item.Name()+SpoolBatchExt+spoolSendingExt)
+	tmpPath := item.path + spoolTmpExt
+	if err := ioutil.WriteFile(tmpPath, payload.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, item.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	if s.debugMode {
+		s.logger.Printf("spool: batch %s written (%d bytes)", item.Name(), payload.Len())
+	}
+	return item, nil
+}
+
+// Stores a batch in the spool, pending to be sent
+func (s *Spool) Store(agentId string, batchId string, payload *bytes.Buffer) error {
+	item, err := s.Write(agentId, batchId, payload)
+	if err != nil {
+		return err
+	}
+	s.Release(item)
+	return nil
+}
+
+// Releases a claimed batch so it can be sent later
+func (s *Spool) Release(item *SpoolItem) {
+	pendingPath := filepath.Join(s.path, item.Name()+SpoolBatchExt)
+	if err := os.Rename(item.path, pendingPath); err != nil {
+		s.logger.Printf("spool: error releasing batch %s: %v", item.Name(), err)
+		return
+	}
+	item.path = pendingPath
+}
+
+// Marks a batch as sent and removes it from the spool
+func (s *Spool) MarkSent(item *SpoolItem) {
+	markPath := filepath.Join(s.path, spoolSentFolder, item.Name())
+	if err := ioutil.WriteFile(markPath, nil, 0644); err != nil {
+		s.logger.Printf("spool: error marking batch %s as sent: %v", item.Name(), err)
+	}
+	s.Remove(item)
+}
+
+// Removes a batch from the spool
+func (s *Spool) Remove(item *SpoolItem) {
+	if err := os.Remove(item.path); err != nil && !os.IsNotExist(err) {
+		s.logger.Printf("spool: error removing batch %s: %v", item.Name(), err)
+	}
+}
+
+// Claims all the pending batches (including abandoned ones), in creation order.
+// Batches already sent are removed without being returned.
+func (s *Spool) ClaimPending() []*SpoolItem {
+	files, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		s.logger.Printf("spool: error reading folder: %v", err)
+		return nil
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	now := time.Now()
+	var items []*SpoolItem
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		name := file.Name()
+		filePath := filepath.Join(s.path, name)
+		if now.Sub(file.ModTime()) > spoolMaxAge {
+			s.logger.Printf("spool: discarding expired batch %s", name)
+			_ = os.Remove(filePath)
+			continue
+		}
+		if strings.HasSuffix(name, spoolSendingExt) {
+			// Claimed by another process, we only take it if the claim has been abandoned
+			if now.Sub(file.ModTime()) < spoolClaimTimeout {
+				continue
+			}
+			name = strings.TrimSuffix(name, spoolSendingExt)
+		}
+		if !strings.HasSuffix(name, SpoolBatchExt) {
+			continue
+		}
+		item := parseSpoolItemName(strings.TrimSuffix(name, SpoolBatchExt))
+		if item == nil {
+			continue
+		}
+		item.path = filepath.Join(s.path, item.Name()+SpoolBatchExt+spoolSendingExt)
+		// The rename is atomic, if it fails another process has claimed the batch first
+		if err := os.Rename(filePath, item.path); err != nil {
+			continue
+		}
+		_ = os.Chtimes(item.path, now, now)
+		if s.isSent(item) {
+			if s.debugMode {
+				s.logger.Printf("spool: batch %s was already sent, removing it", item.Name())
+			}
+			s.Remove(item)
+			continue
+		}
+		items = append(items, item)
+	}
+	s.pruneSentMarks()
+	return items
+}
+
+// Updates a claimed batch after sending it, returns true if the batch is kept for a later retry
+func (s *Spool) Settle(item *SpoolItem, statusCode int, err error) bool {
+	if err == nil {
+		s.MarkSent(item)
+		return false
+	}
+	if statusCode >= 400 && statusCode < 500 && statusCode != 401 && statusCode != 408 && statusCode != 429 {
+		// The batch has been rejected by the backend, retrying it will not change the result
+		s.logger.Printf("spool: batch %s rejected [status code: %d], removing it", item.Name(), statusCode)
+		s.Remove(item)
+		return false
+	}
+	s.Release(item)
+	return true
+}
+
+// Reads the payload of a claimed batch
+func (s *Spool) Read(item *SpoolItem) (*bytes.Buffer, error) {
+	data, err := ioutil.ReadFile(item.path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(data), nil
+}
+
+// Gets if there are batches waiting in the spool
+func (s *Spool) HasPending() bool {
+	files, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), SpoolBatchExt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets if a batch has been already sent
+func (s *Spool) isSent(item *SpoolItem) bool {
+	_, err := os.Stat(filepath.Join(s.path, spoolSentFolder, item.Name()))
+	return err == nil
+}
+
+// Removes the old sent marks
+func (s *Spool) pruneSentMarks() {
+	sentPath := filepath.Join(s.path, spoolSentFolder)
+	files, err := ioutil.ReadDir(sentPath)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, file := range files {
+		if now.Sub(file.ModTime()) > spoolMaxAge {
+			_ = os.Remove(filepath.Join(sentPath, file.Name()))
+		}
+	}
+}
+
+// Removes the temporal files left by a process that crashed between writing a batch and renaming it,
+// the recent ones are kept because they can be being written by other process
+func (s *Spool) removeStaleTmpFiles() {
+	files, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), spoolTmpExt) || now.Sub(file.ModTime()) < spoolClaimTimeout {
+			continue
+		}
+		if s.debugMode {
+			s.logger.Printf("spool: removing stale temporal file %s", file.Name())
+		}
+		_ = os.Remove(filepath.Join(s.path, file.Name()))
+	}
+}
+
+// Gets the batch file name
+func (i *SpoolItem) Name() string {
+	return i.AgentId + "_" + i.BatchId
+}
+
+// Parses a batch file name
+func parseSpoolItemName(name string) *SpoolItem {
+	parts := strings.SplitN(name, "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil
+	}
+	return &SpoolItem{
+		AgentId: parts[0],
+		BatchId: parts[1],
+	}
+}
@@ -0,0 +1,110 @@
+package ingest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpool(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	sp, err := NewSpool(folder, "https://app.scope.dev", "123", true, log.New(os.Stdout, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := sp.Write("agent01", "batch01", bytes.NewBufferString("payload01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := sp.ClaimPending(); len(items) != 0 {
+		t.Fatalf("a batch claimed by the current process has been claimed again: %v", items)
+	}
+
+	sp.Release(item)
+	if !sp.HasPending() {
+		t.Fatal("the released batch is not pending")
+	}
+	items := sp.ClaimPending()
+	if len(items) != 1 || items[0].AgentId != "agent01" || items[0].BatchId != "batch01" {
+		t.Fatalf("unexpected pending batches: %v", items)
+	}
+	payload, err := sp.Read(items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.String() != "payload01" {
+		t.Fatalf("payload was different than expected: %s", payload.String())
+	}
+
+	sp.MarkSent(items[0])
+	if sp.HasPending() {
+		t.Fatal("the sent batch is still pending")
+	}
+
+	// A batch already sent is deduplicated by agent id and batch id
+	dup, err := sp.Write("agent01", "batch01", bytes.NewBufferString("payload01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.Release(dup)
+	if items := sp.ClaimPending(); len(items) != 0 {
+		t.Fatalf("a sent batch has been claimed again: %v", items)
+	}
+	if sp.HasPending() {
+		t.Fatal("the duplicated batch has not been removed")
+	}
+
+	// Batches are isolated by tenant
+	other, err := NewSpool(folder, "https://app.scope.dev", "456", true, log.New(os.Stdout, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err = sp.Write("agent02", "batch02", bytes.NewBufferString("payload02"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.Release(item)
+	if other.HasPending() {
+		t.Fatal("a batch from another tenant is pending")
+	}
+}
+
+func TestSpoolStaleTmpFiles(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	stalePath := filepath.Join(folder, "agent01_batch01"+SpoolBatchExt+spoolSendingExt+spoolTmpExt)
+	recentPath := filepath.Join(folder, "agent01_batch02"+SpoolBatchExt+spoolSendingExt+spoolTmpExt)
+	for _, path := range []string{stalePath, recentPath} {
+		if err := ioutil.WriteFile(path, []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * spoolClaimTimeout)
+	if err := os.Chtimes(stalePath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenSpool(folder, true, log.New(os.Stdout, "", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
+		t.Fatal("the stale temporal file has not been removed")
+	}
+	if _, err := os.Stat(recentPath); err != nil {
+		t.Fatal("a temporal file being written by other process has been removed")
+	}
+}
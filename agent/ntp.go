@@ -34,6 +34,10 @@ func getNTPOffset() (time.Duration, error) {
 
 // Applies the NTP offset to the given time
 func (r *SpanRecorder) applyNTPOffset(t time.Time) time.Time {
+	if r.exporter != nil {
+		// In offline mode the ntp server is not reachable
+		return t
+	}
 	once.Do(func() {
 		if r.debugMode {
 			r.logger.Println("calculating ntp offset.")
@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"go.undefinedlabs.com/scopeagent/agent/ingest"
+	"go.undefinedlabs.com/scopeagent/env"
+)
+
+type (
+	// Result of uploading the payloads exported in offline mode
+	OfflineUploadResult = ingest.OfflineUploadResult
+)
+
+// Uploads the payloads exported in offline mode (see WithOfflineExport) to the Scope ingest endpoint.
+// The api key and endpoint are loaded from the options, the environment variables or the native app configuration.
+func UploadOfflineExport(path string, options ...Option) (*OfflineUploadResult, error) {
+	agent := new(Agent)
+	agent.metadata = make(map[string]interface{})
+	agent.version = version
+	agent.agentId = generateAgentID()
+	agent.userAgent = fmt.Sprintf("scope-agent-go/%s", agent.version)
+	for _, opt := range options {
+		opt(agent)
+	}
+	if err := agent.setupLogging(); err != nil {
+		agent.logger = log.New(ioutil.Discard, "", 0)
+	}
+	return ingest.UploadOfflineExport(path, ingest.UploadOptions{
+		ApiKey:      agent.apiKey,
+		ApiEndpoint: agent.apiEndpoint,
+		UserAgent:   agent.userAgent,
+		DebugMode:   agent.debugMode || env.ScopeDebug.Value,
+		Logger:      agent.logger,
+	})
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.undefinedlabs.com/scopeagent/agent/ingest"
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+func TestOfflineExport(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	agent, err := NewAgent(WithOfflineExport(folder), WithTestingModeEnabled())
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := agent.Tracer().StartSpan("Test")
+	span.SetTag("span.kind", "test")
+	span.SetTag("test.name", "TestOfflineExport")
+	span.SetTag("test.suite", "root")
+	span.SetTag("test.status", tags.TestStatus_PASS)
+	span.Finish()
+	agent.Stop()
+
+	batches, _ := filepath.Glob(filepath.Join(folder, "*"+ingest.SpoolBatchExt))
+	if len(batches) == 0 {
+		t.Fatal("no batches have been exported")
+	}
+	if !strings.HasPrefix(filepath.Base(batches[0]), agent.agentId+"_") {
+		t.Fatalf("the exported batch doesn't belong to the agent: %s", batches[0])
+	}
+
+	var mu sync.Mutex
+	var batchIds []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if req.Header.Get("X-Scope-Agent-Id") != agent.agentId {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batchIds = append(batchIds, req.Header.Get("X-Scope-Batch-Id"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	result, err := UploadOfflineExport(folder, WithApiKey("123"), WithApiEndpoint(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != len(batches) || len(batchIds) != len(batches) {
+		t.Fatalf("expected %d batches sent, got %d", len(batches), result.Sent)
+	}
+	if len(result.ResultUrls) != 1 || !strings.HasSuffix(result.ResultUrls[0], agent.agentId) {
+		t.Fatalf("unexpected result urls: %v", result.ResultUrls)
+	}
+	if remain, _ := filepath.Glob(filepath.Join(folder, "*"+ingest.SpoolBatchExt)); len(remain) > 0 {
+		t.Fatalf("the uploaded batches are still in the export folder: %v", remain)
+	}
+}
@@ -1,14 +1,9 @@
 package agent
 
 import (
-	"bytes"
-	"crypto/x509"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,13 +12,11 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"gopkg.in/tomb.v2"
 
+	"go.undefinedlabs.com/scopeagent/agent/ingest"
 	"go.undefinedlabs.com/scopeagent/tags"
 	"go.undefinedlabs.com/scopeagent/tracer"
 )
 
-const retryBackoff = 1 * time.Second
-const numOfRetries = 3
-
 type (
 	SpanRecorder struct {
 		sync.RWMutex
@@ -35,19 +28,26 @@ type (
 		version     string
 		userAgent   string
 		debugMode   bool
-		metadata    map[string]interface{}
+
+		metadataMutex       sync.Mutex
+		metadata            map[string]interface{}
+		metadataVersion     int
+		metadataSentVersion int
 
 		payloadSpans  []PayloadSpan
 		payloadEvents []PayloadEvent
 
 		flushFrequency time.Duration
-		url            string
-		client         *http.Client
+		client         *ingest.Client
 
 		logger    *log.Logger
 		stats     *RecorderStats
 		statsOnce sync.Once
 		cache     *localCache
+
+		spool        *ingest.Spool
+		spoolPending int32
+		exporter     *ingest.Spool
 	}
 	RecorderStats struct {
 		totalSpans        int64
@@ -58,10 +58,14 @@ type (
 		spansSent         int64
 		spansNotSent      int64
 		spansRejected     int64
+		spansNotSampled   int64
 		totalTestSpans    int64
 		testSpansSent     int64
 		testSpansNotSent  int64
 		testSpansRejected int64
+		batchesSpooled    int64
+		spoolReplayOk     int64
+		spoolReplayKo     int64
 	}
 
 	PayloadSpan  map[string]interface{}
@@ -76,19 +80,84 @@ func NewSpanRecorder(agent *Agent) *SpanRecorder {
 	r.version = agent.version
 	r.userAgent = agent.userAgent
 	r.debugMode = agent.debugMode
-	r.metadata = agent.metadata
+	// The recorder keeps its own copy, the metadata set after the recorder is started is sent with setMetadata
+	r.metadata = make(map[string]interface{}, len(agent.metadata))
+	for k, v := range agent.metadata {
+		r.metadata[k] = v
+	}
+	r.metadataVersion = 1
 	r.logger = agent.logger
 	r.cache = agent.cache
 	r.flushFrequency = agent.flushFrequency
-	r.url = agent.getUrl("api/agent/ingest")
-	r.client = &http.Client{Timeout: 60 * time.Second}
 	r.stats = &RecorderStats{}
+	r.client = &ingest.Client{
+		Url:        agent.getUrl("api/agent/ingest"),
+		ApiKey:     agent.apiKey,
+		UserAgent:  agent.userAgent,
+		DebugMode:  agent.debugMode,
+		Logger:     agent.logger,
+		HttpClient: &http.Client{Timeout: 60 * time.Second},
+		OnRetry: func() {
+			atomic.AddInt64(&r.stats.sendSpansRetries, 1)
+		},
+	}
+	if agent.isOffline() {
+		exporter, err := ingest.OpenSpool(agent.offlineExportPath, agent.debugMode, agent.logger)
+		if err != nil {
+			r.logger.Printf("error opening the offline export path: %v", err)
+		} else {
+			r.exporter = exporter
+		}
+	} else if agent.spoolEnabled {
+		sp, err := r.newSpool(agent)
+		if err != nil {
+			r.logger.Printf("error creating the spool, batches will not be persisted: %v", err)
+		} else {
+			r.spool = sp
+			// We try to send the batches from previous runs
+			atomic.StoreInt32(&r.spoolPending, 1)
+		}
+	}
 	r.t.Go(r.loop)
 	return r
 }
 
+// Sets a metadata value after the recorder has been started, the metadata is sent again in the next payload
+func (r *SpanRecorder) setMetadata(key string, value interface{}) {
+	r.metadataMutex.Lock()
+	defer r.metadataMutex.Unlock()
+	r.metadata[key] = value
+	r.metadataVersion++
+}
+
+// Gets a copy of the metadata if it has changed since the last payload sent with it
+func (r *SpanRecorder) getPendingMetadata() (map[string]interface{}, int) {
+	r.metadataMutex.Lock()
+	defer r.metadataMutex.Unlock()
+	if r.metadataSentVersion >= r.metadataVersion {
+		return nil, 0
+	}
+	metadata := make(map[string]interface{}, len(r.metadata))
+	for k, v := range r.metadata {
+		metadata[k] = v
+	}
+	return metadata, r.metadataVersion
+}
+
+func (r *SpanRecorder) setMetadataSent(version int) {
+	r.metadataMutex.Lock()
+	defer r.metadataMutex.Unlock()
+	if version > r.metadataSentVersion {
+		r.metadataSentVersion = version
+	}
+}
+
 // Appends a span to the in-memory buffer for async processing
 func (r *SpanRecorder) RecordSpan(span tracer.RawSpan) {
+	if !span.Context.Sampled {
+		atomic.AddInt64(&r.stats.spansNotSampled, 1)
+		return
+	}
 	if !r.t.Alive() {
 		atomic.AddInt64(&r.stats.totalSpans, 1)
 		atomic.AddInt64(&r.stats.spansRejected, 1)
@@ -127,12 +196,16 @@ func (r *SpanRecorder) loop() error {
 					return err // Return so we don't try again in the Dying channel
 				} else if err != nil {
 					r.logger.Printf("error sending spans: %v\n", err)
+				} else {
+					r.replaySpool()
 				}
 			}
 		case <-r.t.Dying():
 			err, _ := r.sendSpans()
 			if err != nil {
 				r.logger.Printf("error sending spans: %v\n", err)
+			} else {
+				r.replaySpool()
 			}
 			ticker.Stop()
 			return nil
@@ -149,15 +222,20 @@ func (r *SpanRecorder) sendSpans() (error, bool) {
 		spans, spMore, spTotal := r.popPayloadSpan(batchSize)
 		events, evMore, evTotal := r.popPayloadEvents(batchSize)
 
+		batchId := uuid.New().String()
 		payload := map[string]interface{}{
 			"spans":      spans,
 			"events":     events,
 			tags.AgentID: r.agentId,
+			tags.BatchID: batchId,
 		}
 
-		if atomic.LoadInt64(&r.stats.sendSpansOk) == 0 {
+		hasData := len(spans) > 0 || len(events) > 0
+		metadata, metadataVersion := r.getPendingMetadata()
+		if metadata != nil {
 			r.logger.Println("adding payload metadata")
-			payload["metadata"] = r.metadata
+			payload["metadata"] = metadata
+			hasData = true
 		}
 
 		buf, err := msgPackEncodePayload(payload)
@@ -176,14 +254,39 @@ func (r *SpanRecorder) sendSpans() (error, bool) {
 			}
 		}
 
-		r.logger.Printf("sending %d/%d spans with %d/%d events", len(spans), spTotal, len(events), evTotal)
-		statusCode, err := r.callIngest(buf)
+		var statusCode int
+		if r.exporter != nil {
+			if !hasData {
+				// There is nothing new to export
+				break
+			}
+			r.logger.Printf("exporting %d/%d spans with %d/%d events", len(spans), spTotal, len(events), evTotal)
+			err = r.exporter.Store(r.agentId, batchId, buf)
+		} else {
+			var item *ingest.SpoolItem
+			if r.spool != nil && hasData {
+				// The batch is persisted before sending it, so a crash in the middle doesn't lose it
+				item, err = r.spool.Write(r.agentId, batchId, buf)
+				if err != nil {
+					r.logger.Printf("error writing batch to the spool: %v", err)
+				}
+			}
+
+			r.logger.Printf("sending %d/%d spans with %d/%d events", len(spans), spTotal, len(events), evTotal)
+			statusCode, err = r.client.Send(buf, r.agentId, batchId)
+			if item != nil && r.settleSpoolItem(item, statusCode, err) {
+				atomic.AddInt64(&r.stats.batchesSpooled, 1)
+			}
+		}
 		if err != nil {
 			atomic.AddInt64(&r.stats.sendSpansKo, 1)
 			atomic.AddInt64(&r.stats.spansNotSent, int64(len(spans)))
 			atomic.AddInt64(&r.stats.testSpansNotSent, testSpans)
 		} else {
 			atomic.AddInt64(&r.stats.sendSpansOk, 1)
+			if metadata != nil {
+				r.setMetadataSent(metadataVersion)
+			}
 			atomic.AddInt64(&r.stats.spansSent, int64(len(spans)))
 			atomic.AddInt64(&r.stats.testSpansSent, testSpans)
 		}
@@ -228,6 +331,7 @@ func (r *SpanRecorder) writeStats() {
 		r.logger.Printf("     Spans sent: %d\n", r.stats.spansSent)
 		r.logger.Printf("     Spans not sent: %d\n", r.stats.spansNotSent)
 		r.logger.Printf("     Spans rejected: %d\n", r.stats.spansRejected)
+		r.logger.Printf("  Spans not sampled: %d\n", r.stats.spansNotSampled)
 		r.logger.Printf("  Total test spans: %d\n", r.stats.totalTestSpans)
 		r.logger.Printf("     Test spans sent: %d\n", r.stats.testSpansSent)
 		r.logger.Printf("     Test spans not sent: %d\n", r.stats.testSpansNotSent)
@@ -236,88 +340,65 @@ func (r *SpanRecorder) writeStats() {
 		r.logger.Printf("     SendSpans OK: %d\n", r.stats.sendSpansOk)
 		r.logger.Printf("     SendSpans KO: %d\n", r.stats.sendSpansKo)
 		r.logger.Printf("     SendSpans retries: %d\n", r.stats.sendSpansRetries)
+		if r.spool != nil {
+			r.logger.Printf("  Batches spooled: %d\n", r.stats.batchesSpooled)
+			r.logger.Printf("     Spool replay OK: %d\n", r.stats.spoolReplayOk)
+			r.logger.Printf("     Spool replay KO: %d\n", r.stats.spoolReplayKo)
+		}
 	})
 }
 
-// Sends the encoded `payload` to the Scope ingest endpoint
-func (r *SpanRecorder) callIngest(payload *bytes.Buffer) (statusCode int, err error) {
-	payloadBytes := payload.Bytes()
-	var lastError error
-	for i := 0; i <= numOfRetries; i++ {
-		req, err := http.NewRequest("POST", r.url, bytes.NewBuffer(payloadBytes))
-		if err != nil {
-			return 0, err
-		}
-		req.Header.Set("User-Agent", r.userAgent)
-		req.Header.Set("Content-Type", "application/msgpack")
-		req.Header.Set("Content-Encoding", "gzip")
-		req.Header.Set("X-Scope-ApiKey", r.apiKey)
-
-		if r.debugMode {
-			if i == 0 {
-				r.logger.Println("sending payload")
-			} else {
-				r.logger.Printf("sending payload [retry %d]", i)
-			}
-		}
-
-		resp, err := r.client.Do(req)
+// Sends the batches stored in the spool, from previous runs or from failed attempts of the current one
+func (r *SpanRecorder) replaySpool() {
+	if r.spool == nil || !atomic.CompareAndSwapInt32(&r.spoolPending, 1, 0) {
+		return
+	}
+	items := r.spool.ClaimPending()
+	for idx, item := range items {
+		payload, err := r.spool.Read(item)
 		if err != nil {
-			if v, ok := err.(*url.Error); ok {
-				// Don't retry if the error was due to TLS cert verification failure.
-				if _, ok := v.Err.(x509.UnknownAuthorityError); ok {
-					return 0, errors.New(fmt.Sprintf("error: http client returns: %s", err.Error()))
-				}
-			}
-
-			lastError = err
-			r.logger.Printf("client error '%s', retrying in %d seconds", err.Error(), retryBackoff/time.Second)
-			time.Sleep(retryBackoff)
-			atomic.AddInt64(&r.stats.sendSpansRetries, 1)
+			r.logger.Printf("spool: error reading batch %s: %v", item.Name(), err)
+			r.spool.Remove(item)
 			continue
 		}
-
-		var (
-			bodyData []byte
-			status   string
-		)
-		statusCode = resp.StatusCode
-		status = resp.Status
-		if resp.Body != nil && resp.Body != http.NoBody {
-			body, err := ioutil.ReadAll(resp.Body)
-			if err == nil {
-				bodyData = body
+		r.logger.Printf("spool: replaying batch %s", item.Name())
+		statusCode, err := r.client.Send(payload, item.AgentId, item.BatchId)
+		if r.settleSpoolItem(item, statusCode, err) {
+			atomic.AddInt64(&r.stats.spoolReplayKo, 1)
+			// The backend is still unreachable, we release the remaining batches for a later retry
+			for _, remain := range items[idx+1:] {
+				r.spool.Release(remain)
 			}
+			return
 		}
-		if err := resp.Body.Close(); err != nil { // We can't defer inside a for loop
-			r.logger.Printf("error: closing the response body. %s", err.Error())
-		}
-
-		if statusCode == 0 || statusCode >= 400 {
-			lastError = errors.New(fmt.Sprintf("error from API [status: %s]: %s", status, string(bodyData)))
-		}
-
-		// Check the response code. We retry on 500-range responses to allow
-		// the server time to recover, as 500's are typically not permanent
-		// errors and may relate to outages on the server side. This will catch
-		// invalid response codes as well, like 0 and 999.
-		if statusCode == 0 || (statusCode >= 500 && statusCode != 501) {
-			r.logger.Printf("error: [status code: %d], retrying in %d seconds", statusCode, retryBackoff/time.Second)
-			time.Sleep(retryBackoff)
-			atomic.AddInt64(&r.stats.sendSpansRetries, 1)
-			continue
+		if err == nil {
+			atomic.AddInt64(&r.stats.spoolReplayOk, 1)
+		} else {
+			atomic.AddInt64(&r.stats.spoolReplayKo, 1)
 		}
+	}
+}
 
-		if i > 0 {
-			r.logger.Printf("payload was sent successfully after retry.")
-		}
-		break
+// Updates a spooled batch after an ingest call, returns true if the batch is kept for a later retry
+func (r *SpanRecorder) settleSpoolItem(item *ingest.SpoolItem, statusCode int, err error) bool {
+	if !r.spool.Settle(item, statusCode, err) {
+		return false
 	}
+	atomic.StoreInt32(&r.spoolPending, 1)
+	return true
+}
 
-	if statusCode != 0 && statusCode < 400 {
-		return statusCode, nil
+// Creates the spool of the agent tenant, in the local folder if the spool path is not set
+func (r *SpanRecorder) newSpool(agent *Agent) (*ingest.Spool, error) {
+	folder := agent.spoolPath
+	if folder == "" {
+		lFolder, err := getLocalFolder("spool")
+		if err != nil {
+			return nil, err
+		}
+		folder = lFolder
 	}
-	return statusCode, lastError
+	return ingest.NewSpool(folder, agent.apiEndpoint, agent.apiKey, agent.debugMode, agent.logger)
 }
 
 // Get payload components
@@ -0,0 +1,28 @@
+package agent
+
+import "testing"
+
+func TestRecorderMetadata(t *testing.T) {
+	r := &SpanRecorder{metadata: map[string]interface{}{"service": "default"}, metadataVersion: 1}
+
+	metadata, version := r.getPendingMetadata()
+	if metadata["service"] != "default" {
+		t.Fatalf("the metadata is not pending: %v", metadata)
+	}
+	r.setMetadataSent(version)
+	if metadata, _ := r.getPendingMetadata(); metadata != nil {
+		t.Fatalf("the metadata has been already sent: %v", metadata)
+	}
+
+	// The metadata set after the first payload is sent again
+	r.setMetadata("test_impact.base_commit", "abc")
+	metadata, version = r.getPendingMetadata()
+	if metadata["service"] != "default" || metadata["test_impact.base_commit"] != "abc" {
+		t.Fatalf("the updated metadata is not pending: %v", metadata)
+	}
+	r.setMetadata("test_impact.skipped_tests", 2)
+	r.setMetadataSent(version)
+	if metadata, _ := r.getPendingMetadata(); metadata["test_impact.skipped_tests"] != 2 {
+		t.Fatalf("the metadata set while sending is not pending: %v", metadata)
+	}
+}
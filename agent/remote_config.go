@@ -14,6 +14,9 @@ import (
 	"go.undefinedlabs.com/scopeagent/tags"
 )
 
+const retryBackoff = 1 * time.Second
+const numOfRetries = 3
+
 // Loads the remote agent configuration from local cache, if not exists then retrieve it from the server
 func (a *Agent) loadRemoteConfiguration() map[string]interface{} {
 	if a == nil {
@@ -3,13 +3,30 @@ package agent
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 )
 
 func (a *Agent) PrintReport() {
 	a.printReportOnce.Do(func() {
+		if a.localReportMode && a.testingMode && a.htmlReportPath != "" {
+			fmt.Printf("\n** Scope Test Report **\n")
+			a.printLocalReport()
+			fmt.Println()
+		}
 		if a.recorder != nil && a.testingMode && a.recorder.stats.totalTestSpans > 0 {
 			fmt.Printf("\n** Scope Test Report **\n")
-			if a.recorder.stats.testSpansNotSent == 0 && a.recorder.stats.testSpansRejected == 0 {
+			a.printLocalReport()
+			if a.isOffline() {
+				if a.recorder.stats.testSpansNotSent == 0 && a.recorder.stats.testSpansRejected == 0 {
+					fmt.Println("Test results for this build have been exported to:")
+				} else {
+					a.recorder.writeStats()
+					fmt.Println("There was a problem exporting the test results, partial results have been exported to:")
+				}
+				fmt.Printf("   %s\n", a.offlineExportPath)
+				fmt.Println("Upload them to Scope once it is reachable using:")
+				fmt.Printf("   go run go.undefinedlabs.com/scopeagent/cmd/scope-upload -path %s\n\n", a.offlineExportPath)
+			} else if a.recorder.stats.testSpansNotSent == 0 && a.recorder.stats.testSpansRejected == 0 {
 				fmt.Println("Access the detailed test report for this build at:")
 				fmt.Printf("   %s\n\n", a.getUrl(fmt.Sprintf("external/v1/results/%s", a.agentId)))
 			} else {
@@ -18,6 +35,9 @@ func (a *Agent) PrintReport() {
 				}
 				a.recorder.writeStats()
 				fmt.Println("There was a problem sending data to Scope.")
+				if a.recorder.stats.batchesSpooled > 0 {
+					fmt.Printf("%d batches were saved locally and will be sent in the next run.\n", a.recorder.stats.batchesSpooled)
+				}
 				if a.recorder.stats.testSpansSent > 0 {
 					fmt.Println("Partial results for this build are available at:")
 					fmt.Printf("   %s\n\n", a.getUrl(fmt.Sprintf("external/v1/results/%s", a.agentId)))
@@ -28,6 +48,21 @@ func (a *Agent) PrintReport() {
 	})
 }
 
+// Prints the path of the HTML report
+func (a *Agent) printLocalReport() {
+	if a.htmlReportPath != "" {
+		fmt.Println("The local test report has been written to:")
+		fmt.Printf("   %s\n", getAbsolutePath(a.htmlReportPath))
+	}
+}
+
+func getAbsolutePath(path string) string {
+	if absPath, err := filepath.Abs(path); err == nil {
+		return absPath
+	}
+	return path
+}
+
 func (a *Agent) logMetadata() {
 	metaBytes, _ := json.Marshal(a.metadata)
 	strMetadata := string(metaBytes)
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"go.undefinedlabs.com/scopeagent/env"
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/runner"
+)
+
+// Remote configuration key with the retries by package or test (`{package}.{test name}`)
+const remoteConfigRetries = "retries"
+
+// Loads the retry policy from the environment variables (the options take precedence)
+func (a *Agent) loadRetryPolicy() {
+	if a.retriesOverrides == nil && env.ScopeTestingFailRetriesOverrides.Value != nil {
+		a.retriesOverrides = map[string]int{}
+		for key, value := range env.ScopeTestingFailRetriesOverrides.Value {
+			retries, err := strconv.Atoi(fmt.Sprint(value))
+			if err != nil {
+				a.logger.Printf("invalid retries for '%s' in %s: %v", key, env.ScopeTestingFailRetriesOverrides.Key, value)
+				continue
+			}
+			a.retriesOverrides[key] = retries
+		}
+	}
+	if a.retryDelay == 0 {
+		a.retryDelay = env.ScopeTestingRetryDelay.Value
+		a.retryBackoff = env.ScopeTestingRetryBackoff.Value
+	}
+	if a.retryOn == nil && env.ScopeTestingRetryOn.Value != nil {
+		kinds, err := runner.ParseFailureKinds(env.ScopeTestingRetryOn.Value)
+		if err != nil {
+			a.logger.Printf("error parsing %s: %v", env.ScopeTestingRetryOn.Key, err)
+		}
+		a.retryOn = kinds
+	}
+	if a.maxTotalRetries == 0 {
+		a.maxTotalRetries = env.ScopeTestingMaxTotalRetries.Value
+	}
+}
+
+// Sets the retry policy in the runner options
+func (a *Agent) setRetryPolicy(options *runner.Options) {
+	options.RetryDelay = a.retryDelay
+	options.RetryBackoff = a.retryBackoff
+	options.RetryOn = a.retryOn
+	options.MaxTotalRetries = a.maxTotalRetries
+
+	remoteRetries, _ := instrumentation.GetRemoteConfiguration()[remoteConfigRetries].(map[string]interface{})
+	if len(a.retriesOverrides) == 0 && len(remoteRetries) == 0 {
+		return
+	}
+	options.GetFailRetries = func(test testing.InternalTest) (int, bool) {
+		suite := scopetesting.GetTestSuite(test)
+		return getRetriesOverride(a.retriesOverrides, remoteRetries, suite, test.Name)
+	}
+}
+
+// Gets the retries of a test, the test configuration takes precedence over the package configuration
+// and the local configuration over the remote configuration
+func getRetriesOverride(local map[string]int, remote map[string]interface{}, suite string, name string) (int, bool) {
+	for _, key := range []string{fmt.Sprintf("%s.%s", suite, name), suite} {
+		if retries, ok := local[key]; ok {
+			return retries, true
+		}
+		if retries, ok := toFloat(remote[key]); ok {
+			return int(retries), true
+		}
+	}
+	return 0, false
+}
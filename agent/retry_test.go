@@ -0,0 +1,26 @@
+package agent
+
+import (
+	"testing"
+)
+
+func TestRetriesOverride(t *testing.T) {
+	local := map[string]int{"pkg.TestA": 3, "pkg": 1}
+	remote := map[string]interface{}{"pkg.TestB": int8(5), "other": 2.0}
+	cases := []struct {
+		suite, name string
+		retries     int
+		ok          bool
+	}{
+		{"pkg", "TestA", 3, true},
+		{"pkg", "TestB", 5, true},
+		{"pkg", "TestC", 1, true},
+		{"other", "TestA", 2, true},
+		{"another", "TestA", 0, false},
+	}
+	for _, c := range cases {
+		if retries, ok := getRetriesOverride(local, remote, c.suite, c.name); retries != c.retries || ok != c.ok {
+			t.Errorf("unexpected retries for %s.%s: %d %v", c.suite, c.name, retries, ok)
+		}
+	}
+}
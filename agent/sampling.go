@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"sync"
+
+	"go.undefinedlabs.com/scopeagent/env"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Agent sampler, the strategy can be replaced after the tracer creation (ex: by the remote configuration)
+	agentSampler struct {
+		mu   sync.RWMutex
+		root tracer.Sampler
+
+		rate         float64
+		rateSet      bool
+		rateLimit    float64
+		rateLimitSet bool
+	}
+)
+
+const (
+	remoteConfigSampling          = "sampling"
+	remoteConfigSamplingRate      = "rate"
+	remoteConfigSamplingRateLimit = "rate_limit"
+)
+
+// Creates the agent sampler: test spans are always sampled, child spans follow the root span decision
+// and root spans are sampled by the custom sampler or by the sampling rate and rate limit.
+func newAgentSampler(a *Agent) tracer.Sampler {
+	s := &agentSampler{
+		rate:         1,
+		rateSet:      a.samplingRateSet,
+		rateLimit:    a.samplingRateLimit,
+		rateLimitSet: a.samplingRateLimitSet,
+	}
+	if a.samplingRateSet {
+		s.rate = a.samplingRate
+	} else if rate, set := env.ScopeTracerSamplingRate.Tuple(); set {
+		s.rate = rate
+		s.rateSet = true
+	}
+	if !a.samplingRateLimitSet {
+		if limit, set := env.ScopeTracerSamplingRateLimit.Tuple(); set {
+			s.rateLimit = limit
+			s.rateLimitSet = true
+		}
+	}
+	if a.sampler != nil {
+		s.root = a.sampler
+	} else {
+		s.root = s.newRootSampler()
+	}
+	a.samplerConfig = s
+	return tracer.NewParentBasedSampler(tracer.NewTestSampler(s))
+}
+
+func (s *agentSampler) ShouldSample(params tracer.SamplingParameters) bool {
+	s.mu.RLock()
+	root := s.root
+	s.mu.RUnlock()
+	return root.ShouldSample(params)
+}
+
+func (s *agentSampler) newRootSampler() tracer.Sampler {
+	var samplers []tracer.Sampler
+	if s.rate < 1 {
+		samplers = append(samplers, tracer.NewProbabilisticSampler(s.rate))
+	}
+	if s.rateLimit > 0 {
+		samplers = append(samplers, tracer.NewRateLimitingSampler(s.rateLimit))
+	}
+	switch len(samplers) {
+	case 0:
+		return tracer.NewAlwaysSampler()
+	case 1:
+		return samplers[0]
+	default:
+		return tracer.NewAndSampler(samplers...)
+	}
+}
+
+// Applies the sampling rate and rate limit from the remote configuration, options and env vars take precedence
+func (s *agentSampler) applyRemoteConfiguration(config map[string]interface{}, custom bool) bool {
+	if s == nil || config == nil || custom {
+		return false
+	}
+	sampling, ok := config[remoteConfigSampling].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	changed := false
+	if rate, ok := toFloat(sampling[remoteConfigSamplingRate]); ok && !s.rateSet {
+		s.rate = rate
+		changed = true
+	}
+	if limit, ok := toFloat(sampling[remoteConfigSamplingRateLimit]); ok && !s.rateLimitSet {
+		s.rateLimit = limit
+		changed = true
+	}
+	if changed {
+		root := s.newRootSampler()
+		s.mu.Lock()
+		s.root = root
+		s.mu.Unlock()
+	}
+	return changed
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func TestSamplingRemoteConfiguration(t *testing.T) {
+	agent := &Agent{samplingRateLimit: 5, samplingRateLimitSet: true}
+	sampler := newAgentSampler(agent)
+	params := tracer.SamplingParameters{TraceID: uuid.New(), Operation: "op"}
+	if !sampler.ShouldSample(params) {
+		t.Fatal("the span must be sampled by default")
+	}
+
+	config := map[string]interface{}{
+		remoteConfigSampling: map[string]interface{}{
+			remoteConfigSamplingRate:      int8(0),
+			remoteConfigSamplingRateLimit: 100.0,
+		},
+	}
+	if !agent.samplerConfig.applyRemoteConfiguration(config, false) {
+		t.Fatal("the remote configuration has not been applied")
+	}
+	if agent.samplerConfig.rate != 0 || agent.samplerConfig.rateLimit != 5 {
+		t.Fatalf("the options must take precedence over the remote configuration: %+v", agent.samplerConfig)
+	}
+	if sampler.ShouldSample(params) {
+		t.Fatal("the span must not be sampled with a zero rate")
+	}
+	params.Tags = map[string]interface{}{"span.kind": "test"}
+	if !sampler.ShouldSample(params) {
+		t.Fatal("the test spans must always be sampled")
+	}
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+)
+
+// Gets the test durations used to balance the shards, nil if the durations file is not set (the tests are
+// distributed by hash). The local test history is not used because each shard only has the durations of
+// its own tests, so the shards would get a different split: the durations file is written from the history
+// (see WithShardDurationsExport) and shared by all the shards.
+func (a *Agent) getShardDurations() (func(test testing.InternalTest) (time.Duration, bool), error) {
+	if a.shardDurationsFile == "" {
+		return nil, nil
+	}
+	durations, err := loadShardDurations(a.shardDurationsFile)
+	if err != nil {
+		return nil, err
+	}
+	a.logger.Printf("sharding: %d test durations loaded from %s", len(durations), a.shardDurationsFile)
+	return func(test testing.InternalTest) (time.Duration, bool) {
+		duration, ok := durations[fmt.Sprintf("%s.%s", scopetesting.GetTestSuite(test), test.Name)]
+		return duration, ok
+	}, nil
+}
+
+// Loads the test durations file (`{"{suite}.{test}": "1.5s"}`), the values can be also numbers of seconds
+func loadShardDurations(path string) (map[string]time.Duration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	durations := make(map[string]time.Duration, len(values))
+	for fqn, value := range values {
+		switch v := value.(type) {
+		case string:
+			duration, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid duration of %s: %v", path, fqn, err)
+			}
+			durations[fqn] = duration
+		case float64:
+			durations[fqn] = time.Duration(v * float64(time.Second))
+		default:
+			return nil, fmt.Errorf("%s: invalid duration of %s: %v", path, fqn, value)
+		}
+	}
+	return durations, nil
+}
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/runner"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func TestShardDurations(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-shard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	var tests []testing.InternalTest
+	for i := 0; i < 20; i++ {
+		tests = append(tests, testing.InternalTest{Name: fmt.Sprintf("Test%d", i), F: func(t *testing.T) {}})
+	}
+	suite := scopetesting.GetTestSuite(tests[0])
+
+	// Each shard only has the durations of the tests it has run before
+	newShardAgent := func(index int) *Agent {
+		history := &testHistory{folder: filepath.Join(folder, fmt.Sprintf("history%d", index))}
+		hSuite := &historySuite{Suite: suite, Tests: map[string][]historyRun{}}
+		for i, test := range tests {
+			if i%2 == index {
+				fqn := fmt.Sprintf("%s.%s", suite, test.Name)
+				hSuite.Tests[fqn] = []historyRun{{Status: tags.TestStatus_PASS, Duration: time.Duration(i+1) * time.Second}}
+			}
+		}
+		data, _ := json.Marshal(hSuite)
+		if err := os.MkdirAll(history.folder, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(history.suiteFile(suite), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return &Agent{logger: log.New(ioutil.Discard, "", 0), testHistory: history}
+	}
+	agents := []*Agent{newShardAgent(0), newShardAgent(1)}
+
+	checkShards := func(name string) {
+		seen := map[string]int{}
+		for index, agent := range agents {
+			durations, err := agent.getShardDurations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, test := range runner.GetShardTests(tests, index, len(agents), durations) {
+				seen[test.Name]++
+			}
+		}
+		if len(seen) != len(tests) {
+			t.Fatalf("%s: the shards don't include all the tests: %v", name, seen)
+		}
+		for test, count := range seen {
+			if count != 1 {
+				t.Fatalf("%s: the test %s is in %d shards", name, test, count)
+			}
+		}
+	}
+
+	// Without a shared durations file the tests are distributed by hash
+	for _, agent := range agents {
+		if durations, err := agent.getShardDurations(); durations != nil || err != nil {
+			t.Fatalf("the local history must not be used to balance the shards: %v", err)
+		}
+	}
+	checkShards("local history")
+
+	// With a shared durations file all the shards get the same split
+	durationsFile := filepath.Join(folder, "durations.json")
+	if err := ioutil.WriteFile(durationsFile, []byte(fmt.Sprintf(`{"%s.Test0": "10s", "%s.Test1": 2.5}`, suite, suite)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, agent := range agents {
+		agent.shardDurationsFile = durationsFile
+	}
+	checkShards("durations file")
+	durations, err := agents[0].getShardDurations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duration, ok := durations(tests[0]); !ok || duration != 10*time.Second {
+		t.Fatalf("unexpected duration of Test0: %v", duration)
+	}
+	if duration, ok := durations(tests[1]); !ok || duration != 2500*time.Millisecond {
+		t.Fatalf("unexpected duration of Test1: %v", duration)
+	}
+	if _, ok := durations(tests[2]); ok {
+		t.Fatal("Test2 has no duration in the file")
+	}
+
+	// The run fails if the durations file can't be loaded, instead of splitting the tests differently
+	if err := ioutil.WriteFile(durationsFile, []byte(`{"Test0": "ten seconds"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agents[0].getShardDurations(); err == nil {
+		t.Fatal("an invalid durations file must fail")
+	}
+}
+
+func TestShardDurationsExport(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-shard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	durationsFile := filepath.Join(folder, "cache", "durations.json")
+	if err := os.MkdirAll(filepath.Dir(durationsFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The durations of the tests of other shards are kept
+	if err := ioutil.WriteFile(durationsFile, []byte(`{"pkg.TestOther": "3s", "pkg.TestA": 20}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &testHistory{
+		logger:        log.New(ioutil.Discard, "", 0),
+		folder:        filepath.Join(folder, "history"),
+		durationsFile: durationsFile,
+		attempts:      map[string][]*historyAttempts{},
+	}
+	record := func(name string, duration time.Duration) {
+		h.RecordSpan(tracer.RawSpan{Duration: duration, Tags: opentracing.Tags{
+			"span.kind":   "test",
+			"test.suite":  "pkg",
+			"test.name":   name,
+			"test.status": tags.TestStatus_PASS,
+		}})
+	}
+	record("TestA", time.Second)
+	record("TestB", 500*time.Millisecond)
+	if err := h.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	record("TestA", 3*time.Second)
+	if err := h.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	durations, err := loadShardDurations(durationsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]time.Duration{
+		"pkg.TestA":     2 * time.Second,
+		"pkg.TestB":     500 * time.Millisecond,
+		"pkg.TestOther": 3 * time.Second,
+	}
+	if !reflect.DeepEqual(durations, expected) {
+		t.Fatalf("unexpected exported durations: %v", durations)
+	}
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/env"
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/runner"
+)
+
+// Loads the tests timeouts from the environment variables (the options take precedence)
+func (a *Agent) loadTestTimeouts() {
+	if a.testTimeout == 0 {
+		a.testTimeout = env.ScopeTestingTimeout.Value
+	}
+	if a.testTimeouts == nil && env.ScopeTestingTimeoutOverrides.Value != nil {
+		a.testTimeouts = map[string]time.Duration{}
+		for key, value := range env.ScopeTestingTimeoutOverrides.Value {
+			timeout, err := time.ParseDuration(fmt.Sprint(value))
+			if err != nil {
+				a.logger.Printf("invalid timeout for '%s' in %s: %v", key, env.ScopeTestingTimeoutOverrides.Key, value)
+				continue
+			}
+			a.testTimeouts[key] = timeout
+		}
+	}
+}
+
+// Sets the tests timeouts in the runner options
+func (a *Agent) setTestTimeouts(options *runner.Options) {
+	options.TestTimeout = a.testTimeout
+	if len(a.testTimeouts) > 0 {
+		options.GetTestTimeout = func(test testing.InternalTest) (time.Duration, bool) {
+			return getTimeoutOverride(a.testTimeouts, scopetesting.GetTestSuite(test), test.Name)
+		}
+	}
+	options.OnTimeout = func(t *testing.T, timeout time.Duration, goroutineStacks []byte) {
+		a.logger.Printf("test '%s' timed out after %v, goroutines:\n%s", t.Name(), timeout, goroutineStacks)
+		scopetesting.TimeoutTest(t, timeout, goroutineStacks)
+		// Sends the finished timeout span with the goroutines dump before the test is failed
+		a.Flush()
+	}
+}
+
+// Gets the timeout of a test, the test configuration takes precedence over the package configuration
+func getTimeoutOverride(timeouts map[string]time.Duration, suite string, name string) (time.Duration, bool) {
+	for _, key := range []string{fmt.Sprintf("%s.%s", suite, name), suite} {
+		if timeout, ok := timeouts[key]; ok {
+			return timeout, true
+		}
+	}
+	return 0, false
+}
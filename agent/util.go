@@ -3,8 +3,12 @@ package agent
 import (
 	"bytes"
 	"compress/gzip"
-	"github.com/vmihailenco/msgpack"
+	"fmt"
 	"os"
+	"runtime"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/vmihailenco/msgpack"
 )
 
 func addToMapIfEmpty(dest map[string]interface{}, source map[string]interface{}) {
@@ -66,3 +70,21 @@ func sliceContains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// Gets the path of a folder inside the scope local folder, the folder is created if it doesn't exist
+func getLocalFolder(name string) (string, error) {
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	var folder string
+	if runtime.GOOS == "windows" {
+		folder = fmt.Sprintf("%s/AppData/Roaming/scope/%s", homeDir, name)
+	} else {
+		folder = fmt.Sprintf("%s/.scope/%s", homeDir, name)
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", err
+	}
+	return folder, nil
+}
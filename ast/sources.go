@@ -13,6 +13,7 @@ import (
 
 var (
 	methodCodes map[string]map[string]*MethodCodeBoundaries
+	callCodes   map[string]map[int]*MethodCodeBoundaries
 	mutex       sync.Mutex
 )
 
@@ -61,6 +62,42 @@ func GetFuncSource(pc uintptr) (*MethodCodeBoundaries, error) {
 	return fileCode[funcName], nil
 }
 
+// Gets the source code boundaries of the outermost call starting in a line (ex: a `It("...", func() {...})` spec)
+func GetCallSource(file string, line int) (*MethodCodeBoundaries, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	file = filepath.Clean(file)
+	if callCodes == nil {
+		callCodes = map[string]map[int]*MethodCodeBoundaries{}
+	}
+	if callCodes[file] == nil {
+		fSet := token.NewFileSet()
+		f, err := parser.ParseFile(fSet, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		callCodes[file] = map[int]*MethodCodeBoundaries{}
+		packageName := f.Name.String()
+		ast.Inspect(f, func(node ast.Node) bool {
+			if cExpr, ok := node.(*ast.CallExpr); ok {
+				pos := fSet.PositionFor(cExpr.Pos(), true)
+				end := fSet.PositionFor(cExpr.End(), true)
+				// The nodes are inspected in depth-first order, so the first call of the line is the outermost
+				if _, ok := callCodes[file][pos.Line]; !ok {
+					callCodes[file][pos.Line] = &MethodCodeBoundaries{
+						Package: packageName,
+						File:    file,
+						Start:   CodePos{Line: pos.Line, Column: pos.Column},
+						End:     CodePos{Line: end.Line, Column: end.Column},
+					}
+				}
+			}
+			return true
+		})
+	}
+	return callCodes[file][line], nil
+}
+
 func getCodesForFile(file string) (map[string]*MethodCodeBoundaries, error) {
 	mutex.Lock()
 	defer mutex.Unlock()
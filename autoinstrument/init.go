@@ -11,7 +11,9 @@ import (
 	"go.undefinedlabs.com/scopeagent/agent"
 	"go.undefinedlabs.com/scopeagent/env"
 	"go.undefinedlabs.com/scopeagent/instrumentation"
+	scopeginkgo "go.undefinedlabs.com/scopeagent/instrumentation/ginkgo"
 	scopegocheck "go.undefinedlabs.com/scopeagent/instrumentation/gocheck"
+	scopetestify "go.undefinedlabs.com/scopeagent/instrumentation/testify"
 	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
 )
 
@@ -45,6 +47,8 @@ func init() {
 		logOnError(err)
 
 		scopegocheck.Init()
+		scopeginkgo.Init()
+		scopetestify.Init()
 	})
 }
 
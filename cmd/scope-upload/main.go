@@ -0,0 +1,53 @@
+// Uploads the ingest payloads written by the agent in offline export mode
+// (SCOPE_OFFLINE_EXPORT_PATH) to the Scope ingest endpoint.
+//
+// Usage:
+//
+//	SCOPE_DSN=https://{apikey}@app.scope.dev scope-upload -path /path/to/export
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go.undefinedlabs.com/scopeagent/agent/ingest"
+	"go.undefinedlabs.com/scopeagent/env"
+)
+
+func main() {
+	path := flag.String("path", env.ScopeOfflineExportPath.Value, "path of the offline export folder")
+	dsn := flag.String("dsn", "", "Scope DSN, if empty the SCOPE_DSN environment variable is used")
+	debug := flag.Bool("debug", false, "enable the debug mode")
+	flag.Parse()
+
+	var options ingest.UploadOptions
+	if *dsn != "" {
+		apiKey, apiEndpoint, err := ingest.ParseDSN(*dsn)
+		if err != nil {
+			fmt.Printf("error parsing the dsn: %v\n", err)
+			os.Exit(2)
+		}
+		options.ApiKey, options.ApiEndpoint = apiKey, apiEndpoint
+	}
+	if *debug {
+		options.DebugMode = true
+		options.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	result, err := ingest.UploadOfflineExport(*path, options)
+	if result != nil {
+		fmt.Printf("Batches sent: %d, rejected: %d, pending: %d\n", result.Sent, result.Rejected, result.Pending)
+		if len(result.ResultUrls) > 0 {
+			fmt.Println("Access the detailed test reports at:")
+			for _, url := range result.ResultUrls {
+				fmt.Printf("   %s\n", url)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Printf("error uploading the offline export: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type (
@@ -24,6 +25,16 @@ type (
 		Value int
 	}
 
+	FloatEnvVar struct {
+		eVar
+		Value float64
+	}
+
+	DurationEnvVar struct {
+		eVar
+		Value time.Duration
+	}
+
 	StringEnvVar struct {
 		eVar
 		Value string
@@ -84,6 +95,34 @@ func newIntEnvVar(defaultValue int, keys ...string) IntEnvVar {
 	return envVar
 }
 
+func newFloatEnvVar(defaultValue float64, keys ...string) FloatEnvVar {
+	envVar := FloatEnvVar{eVar: newEVar(keys...)}
+	if !envVar.IsSet {
+		envVar.Value = defaultValue
+		return envVar
+	}
+	value, err := strconv.ParseFloat(envVar.Raw, 64)
+	if err != nil {
+		panic(fmt.Sprintf("unable to parse %s - does not seem to be a float", envVar.Key))
+	}
+	envVar.Value = value
+	return envVar
+}
+
+func newDurationEnvVar(defaultValue time.Duration, keys ...string) DurationEnvVar {
+	envVar := DurationEnvVar{eVar: newEVar(keys...)}
+	if !envVar.IsSet {
+		envVar.Value = defaultValue
+		return envVar
+	}
+	value, err := time.ParseDuration(envVar.Raw)
+	if err != nil {
+		panic(fmt.Sprintf("unable to parse %s - does not seem to be a duration", envVar.Key))
+	}
+	envVar.Value = value
+	return envVar
+}
+
 func newStringEnvVar(defaultValue string, keys ...string) StringEnvVar {
 	envVar := StringEnvVar{eVar: newEVar(keys...)}
 	if !envVar.IsSet {
@@ -137,6 +176,12 @@ func (e *BooleanEnvVar) Tuple() (bool, bool) {
 func (e *IntEnvVar) Tuple() (int, bool) {
 	return e.Value, e.IsSet
 }
+func (e *FloatEnvVar) Tuple() (float64, bool) {
+	return e.Value, e.IsSet
+}
+func (e *DurationEnvVar) Tuple() (time.Duration, bool) {
+	return e.Value, e.IsSet
+}
 func (e *StringEnvVar) Tuple() (string, bool) {
 	return e.Value, e.IsSet
 }
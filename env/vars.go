@@ -1,6 +1,10 @@
 package env
 
-import "go.undefinedlabs.com/scopeagent/tags"
+import (
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/tags"
+)
 
 var (
 	ScopeDsn                              = newStringEnvVar("", "SCOPE_DSN")
@@ -14,9 +18,23 @@ var (
 	ScopeLoggerRoot                       = newStringEnvVar("", "SCOPE_LOGGER_ROOT", "SCOPE_LOG_ROOT_PATH")
 	ScopeDebug                            = newBooleanEnvVar(false, "SCOPE_DEBUG")
 	ScopeTracerGlobal                     = newBooleanEnvVar(false, "SCOPE_TRACER_GLOBAL", "SCOPE_SET_GLOBAL_TRACER")
+	ScopeTracerPropagation                = newSliceEnvVar(nil, "SCOPE_TRACER_PROPAGATION")
+	ScopeTracerSamplingRate               = newFloatEnvVar(1, "SCOPE_TRACER_SAMPLING_RATE")
+	ScopeTracerSamplingRateLimit          = newFloatEnvVar(0, "SCOPE_TRACER_SAMPLING_RATE_LIMIT")
 	ScopeTestingMode                      = newBooleanEnvVar(false, "SCOPE_TESTING_MODE")
 	ScopeTestingFailRetries               = newIntEnvVar(0, "SCOPE_TESTING_FAIL_RETRIES")
 	ScopeTestingPanicAsFail               = newBooleanEnvVar(false, "SCOPE_TESTING_PANIC_AS_FAIL")
+	ScopeTestingFailRetriesOverrides      = newMapEnvVar(nil, "SCOPE_TESTING_FAIL_RETRIES_OVERRIDES")
+	ScopeTestingRetryDelay                = newDurationEnvVar(0, "SCOPE_TESTING_RETRY_DELAY")
+	ScopeTestingRetryBackoff              = newFloatEnvVar(1, "SCOPE_TESTING_RETRY_BACKOFF")
+	ScopeTestingRetryOn                   = newSliceEnvVar(nil, "SCOPE_TESTING_RETRY_ON")
+	ScopeTestingMaxTotalRetries           = newIntEnvVar(0, "SCOPE_TESTING_MAX_TOTAL_RETRIES")
+	ScopeTestingTimeout                   = newDurationEnvVar(0, "SCOPE_TESTING_TIMEOUT")
+	ScopeTestingTimeoutOverrides          = newMapEnvVar(nil, "SCOPE_TESTING_TIMEOUT_OVERRIDES")
+	ScopeTestingGoroutineLeaks            = newBooleanEnvVar(false, "SCOPE_TESTING_GOROUTINE_LEAKS")
+	ScopeTestingGoroutineLeaksGrace       = newDurationEnvVar(500*time.Millisecond, "SCOPE_TESTING_GOROUTINE_LEAKS_GRACE")
+	ScopeTestingGoroutineLeaksFail        = newBooleanEnvVar(false, "SCOPE_TESTING_GOROUTINE_LEAKS_FAIL")
+	ScopeTestingResourceMetrics           = newBooleanEnvVar(true, "SCOPE_TESTING_RESOURCE_METRICS")
 	ScopeConfiguration                    = newSliceEnvVar([]string{tags.PlatformName, tags.PlatformArchitecture, tags.GoVersion}, "SCOPE_CONFIGURATION")
 	ScopeMetadata                         = newMapEnvVar(nil, "SCOPE_METADATA")
 	ScopeInstrumentationHttpPayloads      = newBooleanEnvVar(false, "SCOPE_INSTRUMENTATION_HTTP_PAYLOADS")
@@ -28,4 +46,29 @@ var (
 	ScopeRunnerExcludeBranches            = newSliceEnvVar(nil, "SCOPE_RUNNER_EXCLUDE_BRANCHES")
 	ScopeDependenciesIndirect             = newBooleanEnvVar(false, "SCOPE_DEPENDENCIES_INDIRECT")
 	ScopeInstrumentationTestingLogger     = newBooleanEnvVar(true, "`SCOPE_INSTRUMENTATION_TESTING_LOGGER`")
+	ScopeSpoolEnabled                     = newBooleanEnvVar(false, "SCOPE_SPOOL_ENABLED")
+	ScopeSpoolPath                        = newStringEnvVar("", "SCOPE_SPOOL_PATH")
+	ScopeOfflineExportPath                = newStringEnvVar("", "SCOPE_OFFLINE_EXPORT_PATH")
+	ScopeTestingJUnitReport               = newStringEnvVar("", "SCOPE_TESTING_JUNIT_REPORT")
+	ScopeTestingHTMLReport                = newStringEnvVar("", "SCOPE_TESTING_HTML_REPORT")
+	ScopeTestingConsoleReport             = newBooleanEnvVar(false, "SCOPE_TESTING_CONSOLE_REPORT")
+	ScopeTestingImpactAnalysis            = newBooleanEnvVar(false, "SCOPE_TESTING_IMPACT_ANALYSIS")
+	ScopeTestingImpactBaseRef             = newStringEnvVar("HEAD", "SCOPE_TESTING_IMPACT_BASE_REF")
+	ScopeTestingBenchmarkBaseline         = newBooleanEnvVar(false, "SCOPE_TESTING_BENCHMARK_BASELINE")
+	ScopeTestingBenchmarkBaseRef          = newStringEnvVar("HEAD", "SCOPE_TESTING_BENCHMARK_BASE_REF")
+	ScopeTestingBenchmarkThreshold        = newFloatEnvVar(0.1, "SCOPE_TESTING_BENCHMARK_THRESHOLD")
+	ScopeTestingBenchmarkFail             = newBooleanEnvVar(false, "SCOPE_TESTING_BENCHMARK_FAIL")
+	ScopeTestingQuarantine                = newBooleanEnvVar(false, "SCOPE_TESTING_QUARANTINE")
+	ScopeTestingQuarantineFile            = newStringEnvVar("", "SCOPE_TESTING_QUARANTINE_FILE")
+	ScopeTestingFlakyThreshold            = newFloatEnvVar(0.2, "SCOPE_TESTING_FLAKY_THRESHOLD")
+	ScopeTestingShardIndex                = newIntEnvVar(0, "SCOPE_TESTING_SHARD_INDEX")
+	ScopeTestingShardTotal                = newIntEnvVar(0, "SCOPE_TESTING_SHARD_TOTAL")
+	ScopeTestingShardDurations            = newStringEnvVar("", "SCOPE_TESTING_SHARD_DURATIONS")
+	ScopeTestingShardDurationsExport      = newStringEnvVar("", "SCOPE_TESTING_SHARD_DURATIONS_EXPORT")
+	ScopeTestingShuffle                   = newBooleanEnvVar(false, "SCOPE_TESTING_SHUFFLE")
+	ScopeTestingShuffleSeed               = newIntEnvVar(0, "SCOPE_TESTING_SHUFFLE_SEED")
+	ScopeTestingShuffleSubTests           = newBooleanEnvVar(false, "SCOPE_TESTING_SHUFFLE_SUBTESTS")
+	ScopeTestingCoverageExport            = newStringEnvVar("", "SCOPE_TESTING_COVERAGE_EXPORT")
+	ScopeTestingCoverageExportPerTest     = newBooleanEnvVar(false, "SCOPE_TESTING_COVERAGE_EXPORT_PER_TEST")
+	ScopeTestingCoverageParallel          = newBooleanEnvVar(false, "SCOPE_TESTING_COVERAGE_PARALLEL")
 )
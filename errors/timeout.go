@@ -0,0 +1,119 @@
+package errors
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+// Write a timeout exception event in span with the stacks of all the goroutines (`runtime.Stack` output)
+func WriteTimeoutEvent(span opentracing.Span, message string, goroutineStacks []byte) {
+	span.SetTag("error", true)
+	errStack := getSourceGoroutineFrames(goroutineStacks, instrumentation.GetSourceRoot())
+	source := ""
+	for _, frame := range errStack {
+		if isInSourceRoot(frame.File, instrumentation.GetSourceRoot()) {
+			source = fmt.Sprintf("%s:%d", frame.File, frame.LineNumber)
+			break
+		}
+	}
+	span.LogFields(
+		log.String(tags.EventType, "error"),
+		log.String(tags.EventSource, source),
+		log.String(tags.EventMessage, message),
+		log.String(tags.EventStack, fmt.Sprintf("[timeout]: %s\n\n%s", message, goroutineStacks)),
+		log.Object(tags.EventException, getExceptionFrameData(message, errStack)),
+	)
+}
+
+// Gets the frames of the first goroutine running code of the source root, or the first goroutine if none
+func getSourceGoroutineFrames(goroutineStacks []byte, sourceRoot string) []errors.StackFrame {
+	goroutines := parseGoroutineStacks(goroutineStacks)
+	for _, frames := range goroutines {
+		for _, frame := range frames {
+			if isInSourceRoot(frame.File, sourceRoot) {
+				return frames
+			}
+		}
+	}
+	if len(goroutines) > 0 {
+		return goroutines[0]
+	}
+	return nil
+}
+
+// Parses the frames of each goroutine in a `runtime.Stack` output
+func parseGoroutineStacks(goroutineStacks []byte) [][]errors.StackFrame {
+	var goroutines [][]errors.StackFrame
+	var current []errors.StackFrame
+	var function string
+	scanner := bufio.NewScanner(bytes.NewReader(goroutineStacks))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			if current != nil {
+				goroutines = append(goroutines, current)
+			}
+			current = []errors.StackFrame{}
+			function = ""
+		case strings.HasPrefix(line, "\t") && function != "":
+			// File line: `\t/path/file.go:123 +0x1f`
+			fileLine := strings.TrimSpace(line)
+			if idx := strings.LastIndex(fileLine, " +0x"); idx >= 0 {
+				fileLine = fileLine[:idx]
+			}
+			if idx := strings.LastIndex(fileLine, ":"); idx >= 0 {
+				lineNumber, _ := strconv.Atoi(fileLine[idx+1:])
+				pkg, name := splitFunctionName(function)
+				current = append(current, errors.StackFrame{
+					File:       fileLine[:idx],
+					LineNumber: lineNumber,
+					Name:       name,
+					Package:    pkg,
+				})
+			}
+			function = ""
+		case line != "" && current != nil:
+			// Function line: `pkg/path.Func(args)` or `created by pkg/path.Func`
+			function = strings.TrimPrefix(line, "created by ")
+			if idx := strings.LastIndex(function, "("); idx > 0 && strings.HasSuffix(function, ")") {
+				function = function[:idx]
+			}
+			if idx := strings.Index(function, " in goroutine "); idx > 0 {
+				function = function[:idx]
+			}
+		}
+	}
+	if current != nil {
+		goroutines = append(goroutines, current)
+	}
+	return goroutines
+}
+
+func splitFunctionName(function string) (string, string) {
+	lastSlash := strings.LastIndex(function, "/")
+	if lastSlash < 0 {
+		lastSlash = 0
+	}
+	dot := strings.Index(function[lastSlash:], ".")
+	if dot < 0 {
+		return "", function
+	}
+	return function[:lastSlash+dot], function[lastSlash+dot+1:]
+}
+
+func isInSourceRoot(file string, sourceRoot string) bool {
+	return sourceRoot != "" && strings.Index(filepath.Dir(file), sourceRoot) != -1
+}
@@ -0,0 +1,26 @@
+package errors
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseGoroutineStacks(t *testing.T) {
+	buf := make([]byte, 1024*1024)
+	stacks := buf[:runtime.Stack(buf, true)]
+	goroutines := parseGoroutineStacks(stacks)
+	if len(goroutines) < 2 {
+		t.Fatalf("unexpected number of goroutines: %d", len(goroutines))
+	}
+	current := goroutines[0]
+	if len(current) == 0 || current[0].Name != "TestParseGoroutineStacks" ||
+		!strings.HasSuffix(current[0].Package, "scopeagent/errors") || !strings.HasSuffix(current[0].File, "timeout_test.go") {
+		t.Fatalf("unexpected frame: %+v", current)
+	}
+
+	frames := getSourceGoroutineFrames(stacks, "/not/found")
+	if len(frames) != len(current) {
+		t.Fatal("the first goroutine must be used if there isn't code of the source root")
+	}
+}
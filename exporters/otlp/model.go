@@ -0,0 +1,317 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+// OTLP trace data model (opentelemetry/proto/trace/v1/trace.proto), the json tags follow the OTLP/JSON mapping
+type (
+	exportTraceServiceRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+
+	resource struct {
+		Attributes []keyValue `json:"attributes,omitempty"`
+	}
+
+	scopeSpans struct {
+		Scope instrumentationScope `json:"scope"`
+		Spans []span               `json:"spans"`
+	}
+
+	instrumentationScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+
+	span struct {
+		TraceId           hexBytes   `json:"traceId"`
+		SpanId            hexBytes   `json:"spanId"`
+		ParentSpanId      hexBytes   `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano uint64     `json:"startTimeUnixNano,string"`
+		EndTimeUnixNano   uint64     `json:"endTimeUnixNano,string"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Events            []event    `json:"events,omitempty"`
+		Status            status     `json:"status"`
+	}
+
+	event struct {
+		TimeUnixNano uint64     `json:"timeUnixNano,string"`
+		Name         string     `json:"name"`
+		Attributes   []keyValue `json:"attributes,omitempty"`
+	}
+
+	status struct {
+		Message string `json:"message,omitempty"`
+		Code    int    `json:"code,omitempty"`
+	}
+
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+
+	anyValue struct {
+		StringValue *string      `json:"stringValue,omitempty"`
+		BoolValue   *bool        `json:"boolValue,omitempty"`
+		IntValue    *int64       `json:"intValue,omitempty,string"`
+		DoubleValue *float64     `json:"doubleValue,omitempty"`
+		ArrayValue  *arrayValue  `json:"arrayValue,omitempty"`
+		KvlistValue *kvlistValue `json:"kvlistValue,omitempty"`
+	}
+
+	arrayValue struct {
+		Values []anyValue `json:"values"`
+	}
+
+	kvlistValue struct {
+		Values []keyValue `json:"values"`
+	}
+
+	// Trace and span ids are encoded as hex strings in OTLP/JSON
+	hexBytes []byte
+)
+
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+	spanKindProducer = 4
+	spanKindConsumer = 5
+
+	statusCodeOk    = 1
+	statusCodeError = 2
+
+	baggagePrefix = "baggage."
+)
+
+func (b hexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *hexBytes) UnmarshalText(text []byte) error {
+	value, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*b = value
+	return nil
+}
+
+// Converts a Scope raw span to an OTLP span
+func convertSpan(raw tracer.RawSpan) span {
+	otSpan := span{
+		TraceId:           traceIdBytes(raw.Context.TraceID),
+		SpanId:            spanIdBytes(raw.Context.SpanID),
+		Name:              raw.Operation,
+		Kind:              spanKind(raw.Tags["span.kind"]),
+		StartTimeUnixNano: uint64(raw.Start.UnixNano()),
+		EndTimeUnixNano:   uint64(raw.Start.Add(raw.Duration).UnixNano()),
+	}
+	if raw.ParentSpanID != 0 {
+		otSpan.ParentSpanId = spanIdBytes(raw.ParentSpanID)
+	}
+
+	for _, key := range sortedKeys(raw.Tags) {
+		otSpan.Attributes = append(otSpan.Attributes, keyValue{Key: key, Value: convertValue(raw.Tags[key])})
+	}
+	baggageKeys := make([]string, 0, len(raw.Context.Baggage))
+	for key := range raw.Context.Baggage {
+		baggageKeys = append(baggageKeys, key)
+	}
+	sort.Strings(baggageKeys)
+	for _, key := range baggageKeys {
+		otSpan.Attributes = append(otSpan.Attributes, keyValue{Key: baggagePrefix + key, Value: convertValue(raw.Context.Baggage[key])})
+	}
+
+	var errorMessage string
+	for _, record := range raw.Logs {
+		otEvent := event{
+			TimeUnixNano: uint64(record.Timestamp.UnixNano()),
+			Name:         tags.LogEvent,
+		}
+		var message string
+		for _, field := range record.Fields {
+			switch field.Key() {
+			case tags.EventType:
+				otEvent.Name = fmt.Sprint(field.Value())
+				continue
+			case tags.EventMessage:
+				message = fmt.Sprint(field.Value())
+			}
+			otEvent.Attributes = append(otEvent.Attributes, keyValue{Key: field.Key(), Value: convertValue(field.Value())})
+		}
+		if errorMessage == "" && (otEvent.Name == "error" || otEvent.Name == tags.EventTestFailure) {
+			errorMessage = message
+		}
+		otSpan.Events = append(otSpan.Events, otEvent)
+	}
+
+	if isError(raw) {
+		otSpan.Status = status{Code: statusCodeError, Message: errorMessage}
+	} else if raw.Tags["test.status"] == tags.TestStatus_PASS {
+		otSpan.Status = status{Code: statusCodeOk}
+	}
+	return otSpan
+}
+
+// Gets if the span has finished with an error (`error` tag or failed test)
+func isError(raw tracer.RawSpan) bool {
+	if value, ok := raw.Tags["error"]; ok {
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return true
+			}
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil && b {
+				return true
+			}
+		}
+	}
+	return raw.Tags["test.status"] == tags.TestStatus_FAIL
+}
+
+func spanKind(value interface{}) int {
+	switch value {
+	case "server":
+		return spanKindServer
+	case "client":
+		return spanKindClient
+	case "producer":
+		return spanKindProducer
+	case "consumer":
+		return spanKindConsumer
+	default:
+		return spanKindInternal
+	}
+}
+
+func traceIdBytes(traceId uuid.UUID) hexBytes {
+	return hexBytes(traceId[:])
+}
+
+func spanIdBytes(spanId uint64) hexBytes {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, spanId)
+	return value
+}
+
+// Converts a tag or field value to an OTLP any value
+func convertValue(value interface{}) anyValue {
+	switch v := value.(type) {
+	case nil:
+		return stringValue("")
+	case string:
+		return stringValue(v)
+	case bool:
+		return anyValue{BoolValue: &v}
+	case int:
+		return intValue(int64(v))
+	case int8:
+		return intValue(int64(v))
+	case int16:
+		return intValue(int64(v))
+	case int32:
+		return intValue(int64(v))
+	case int64:
+		return intValue(v)
+	case uint:
+		return uintValue(uint64(v))
+	case uint8:
+		return intValue(int64(v))
+	case uint16:
+		return intValue(int64(v))
+	case uint32:
+		return intValue(int64(v))
+	case uint64:
+		return uintValue(v)
+	case float32:
+		return doubleValue(float64(v))
+	case float64:
+		return doubleValue(v)
+	case []string:
+		values := make([]anyValue, len(v))
+		for idx, item := range v {
+			values[idx] = stringValue(item)
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	case []interface{}:
+		values := make([]anyValue, len(v))
+		for idx, item := range v {
+			values[idx] = convertValue(item)
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	case map[string]interface{}:
+		kvList := &kvlistValue{}
+		for _, key := range sortedKeys(v) {
+			kvList.Values = append(kvList.Values, keyValue{Key: key, Value: convertValue(v[key])})
+		}
+		return anyValue{KvlistValue: kvList}
+	case map[string]string:
+		kvList := &kvlistValue{}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			kvList.Values = append(kvList.Values, keyValue{Key: key, Value: stringValue(v[key])})
+		}
+		return anyValue{KvlistValue: kvList}
+	case error:
+		return stringValue(v.Error())
+	case fmt.Stringer:
+		return stringValue(v.String())
+	default:
+		return stringValue(fmt.Sprint(v))
+	}
+}
+
+func stringValue(value string) anyValue {
+	return anyValue{StringValue: &value}
+}
+
+func intValue(value int64) anyValue {
+	return anyValue{IntValue: &value}
+}
+
+func uintValue(value uint64) anyValue {
+	if value > math.MaxInt64 {
+		return stringValue(strconv.FormatUint(value, 10))
+	}
+	return intValue(int64(value))
+}
+
+func doubleValue(value float64) anyValue {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return stringValue(strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return anyValue{DoubleValue: &value}
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
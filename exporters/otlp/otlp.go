@@ -0,0 +1,311 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Span recorder that exports the spans to an OpenTelemetry collector using OTLP/HTTP
+	Recorder struct {
+		endpoint      string
+		encoding      Encoding
+		headers       map[string]string
+		serviceName   string
+		attributes    map[string]interface{}
+		batchSize     int
+		flushInterval time.Duration
+		maxRetries    int
+		client        *http.Client
+		logger        *log.Logger
+
+		t        tomb.Tomb
+		mu       sync.Mutex
+		spans    []tracer.RawSpan
+		sendLock sync.Mutex
+	}
+
+	// OTLP/HTTP payload encoding
+	Encoding int
+
+	Option func(*Recorder)
+)
+
+const (
+	// Binary protobuf encoding (application/x-protobuf)
+	EncodingProtobuf Encoding = iota
+	// OTLP/JSON encoding (application/json)
+	EncodingJSON
+)
+
+const (
+	tracesPath   = "/v1/traces"
+	scopeName    = "go.undefinedlabs.com/scopeagent"
+	sdkLanguage  = "go"
+	defaultBatch = 512
+)
+
+// Sets the payload encoding (protobuf by default)
+func WithEncoding(encoding Encoding) Option {
+	return func(r *Recorder) {
+		r.encoding = encoding
+	}
+}
+
+// Adds headers to the export requests (ex: authentication)
+func WithHeaders(headers map[string]string) Option {
+	return func(r *Recorder) {
+		for k, v := range headers {
+			r.headers[k] = v
+		}
+	}
+}
+
+// Sets the `service.name` resource attribute
+func WithServiceName(service string) Option {
+	return func(r *Recorder) {
+		r.serviceName = service
+	}
+}
+
+// Adds resource attributes to the exported spans
+func WithResourceAttributes(attributes map[string]interface{}) Option {
+	return func(r *Recorder) {
+		for k, v := range attributes {
+			r.attributes[k] = v
+		}
+	}
+}
+
+// Sets the max number of spans in each export request
+func WithBatchSize(size int) Option {
+	return func(r *Recorder) {
+		r.batchSize = size
+	}
+}
+
+// Sets the interval for exporting the buffered spans
+func WithFlushInterval(interval time.Duration) Option {
+	return func(r *Recorder) {
+		r.flushInterval = interval
+	}
+}
+
+// Sets the max number of retries of a failed export request
+func WithMaxRetries(retries int) Option {
+	return func(r *Recorder) {
+		r.maxRetries = retries
+	}
+}
+
+// Sets the http client used to call the collector
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *Recorder) {
+		r.client = client
+	}
+}
+
+// Sets the logger of the recorder
+func WithLogger(logger *log.Logger) Option {
+	return func(r *Recorder) {
+		r.logger = logger
+	}
+}
+
+// Creates a new OTLP recorder, the endpoint is the collector base url (ex: http://localhost:4318) or the full traces url
+func NewRecorder(endpoint string, options ...Option) *Recorder {
+	r := &Recorder{
+		endpoint:      tracesUrl(endpoint),
+		encoding:      EncodingProtobuf,
+		headers:       map[string]string{},
+		serviceName:   "default",
+		attributes:    map[string]interface{}{},
+		batchSize:     defaultBatch,
+		flushInterval: time.Second,
+		maxRetries:    3,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		logger:        log.New(ioutil.Discard, "", 0),
+	}
+	for _, opt := range options {
+		opt(r)
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultBatch
+	}
+	r.t.Go(r.loop)
+	return r
+}
+
+// Appends a span to the buffer, the spans are exported asynchronously
+func (r *Recorder) RecordSpan(span tracer.RawSpan) {
+	if !span.Context.Sampled {
+		return
+	}
+	if !r.t.Alive() {
+		r.logger.Printf("otlp: a span has been received but the recorder is not running")
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+// Exports all the buffered spans
+func (r *Recorder) Flush() error {
+	var lastError error
+	for {
+		spans, more := r.popSpans()
+		if len(spans) == 0 {
+			return lastError
+		}
+		if err := r.export(spans); err != nil {
+			r.logger.Printf("otlp: error exporting %d spans: %v", len(spans), err)
+			lastError = err
+		}
+		if !more {
+			return lastError
+		}
+	}
+}
+
+// Stops the recorder and exports the remaining spans
+func (r *Recorder) Stop() error {
+	if r.t.Alive() {
+		r.t.Kill(nil)
+	}
+	_ = r.t.Wait()
+	return r.Flush()
+}
+
+func (r *Recorder) loop() error {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = r.Flush()
+		case <-r.t.Dying():
+			return nil
+		}
+	}
+}
+
+func (r *Recorder) popSpans() ([]tracer.RawSpan, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.spans) <= r.batchSize {
+		spans := r.spans
+		r.spans = nil
+		return spans, false
+	}
+	spans := r.spans[:r.batchSize]
+	r.spans = r.spans[r.batchSize:]
+	return spans, true
+}
+
+// Exports a batch of spans to the collector
+func (r *Recorder) export(spans []tracer.RawSpan) error {
+	r.sendLock.Lock()
+	defer r.sendLock.Unlock()
+
+	payload, contentType, err := r.encode(r.newRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	var lastError error
+	for i := 0; i <= r.maxRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i*i) * 100 * time.Millisecond)
+		}
+		var retryable bool
+		retryable, lastError = r.send(payload, contentType)
+		if lastError == nil || !retryable {
+			return lastError
+		}
+	}
+	return lastError
+}
+
+func (r *Recorder) send(payload []byte, contentType string) (bool, error) {
+	req, err := http.NewRequest("POST", r.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("otlp: collector responded with status code %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, err
+	}
+	return false, err
+}
+
+// Creates the export request with all the spans in a single resource and scope
+func (r *Recorder) newRequest(spans []tracer.RawSpan) *exportTraceServiceRequest {
+	res := resource{}
+	attributes := map[string]interface{}{
+		"service.name":           r.serviceName,
+		"telemetry.sdk.name":     scopeName,
+		"telemetry.sdk.language": sdkLanguage,
+	}
+	for k, v := range r.attributes {
+		attributes[k] = v
+	}
+	for _, key := range sortedKeys(attributes) {
+		res.Attributes = append(res.Attributes, keyValue{Key: key, Value: convertValue(attributes[key])})
+	}
+
+	scope := scopeSpans{Scope: instrumentationScope{Name: scopeName}}
+	for _, raw := range spans {
+		scope.Spans = append(scope.Spans, convertSpan(raw))
+	}
+	return &exportTraceServiceRequest{
+		ResourceSpans: []resourceSpans{{Resource: res, ScopeSpans: []scopeSpans{scope}}},
+	}
+}
+
+func (r *Recorder) encode(request *exportTraceServiceRequest) ([]byte, string, error) {
+	switch r.encoding {
+	case EncodingJSON:
+		payload, err := json.Marshal(request)
+		return payload, "application/json", err
+	case EncodingProtobuf:
+		return request.marshalProto(), "application/x-protobuf", nil
+	}
+	return nil, "", errors.New("otlp: unknown encoding")
+}
+
+// Gets the traces url from a collector endpoint
+func tracesUrl(endpoint string) string {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if strings.HasSuffix(endpoint, tracesPath) {
+		return endpoint
+	}
+	return endpoint + tracesPath
+}
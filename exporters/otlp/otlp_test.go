@@ -0,0 +1,191 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type collector struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	payloads [][]byte
+	types    []string
+	failures int
+}
+
+func newCollector() *collector {
+	c := &collector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if req.URL.Path != tracesPath || req.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if c.failures > 0 {
+			c.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		c.payloads = append(c.payloads, body)
+		c.types = append(c.types, req.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	return c
+}
+
+func newRawSpan() tracer.RawSpan {
+	return tracer.RawSpan{
+		Context: tracer.SpanContext{
+			TraceID: uuid.New(),
+			SpanID:  0x0102030405060708,
+			Sampled: true,
+			Baggage: map[string]string{"trace.kind": "test"},
+		},
+		ParentSpanID: 42,
+		Operation:    "TestOTLP",
+		Start:        time.Unix(100, 0),
+		Duration:     time.Second,
+		Tags: opentracing.Tags{
+			"span.kind":   "test",
+			"test.status": tags.TestStatus_FAIL,
+			"test.count":  3,
+		},
+		Logs: []opentracing.LogRecord{{
+			Timestamp: time.Unix(100, 500),
+			Fields: []log.Field{
+				log.String(tags.EventType, tags.EventTestFailure),
+				log.String(tags.EventMessage, "assertion failed"),
+			},
+		}},
+	}
+}
+
+func TestJSONExport(t *testing.T) {
+	c := newCollector()
+	defer c.server.Close()
+	c.failures = 1
+
+	raw := newRawSpan()
+	recorder := NewRecorder(c.server.URL, WithEncoding(EncodingJSON), WithServiceName("my-service"),
+		WithHeaders(map[string]string{"Authorization": "Bearer token"}))
+	recorder.RecordSpan(raw)
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.payloads) != 1 || c.types[0] != "application/json" {
+		t.Fatalf("unexpected requests: %v", c.types)
+	}
+	var request exportTraceServiceRequest
+	if err := json.Unmarshal(c.payloads[0], &request); err != nil {
+		t.Fatal(err)
+	}
+	sp := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if !bytes.Equal(sp.TraceId, raw.Context.TraceID[:]) || !bytes.Equal(sp.SpanId, []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Fatalf("unexpected ids: %x %x", sp.TraceId, sp.SpanId)
+	}
+	if sp.Kind != spanKindInternal || sp.EndTimeUnixNano-sp.StartTimeUnixNano != uint64(time.Second) {
+		t.Fatalf("unexpected span: %+v", sp)
+	}
+	if sp.Status.Code != statusCodeError || sp.Status.Message != "assertion failed" {
+		t.Fatalf("unexpected status: %+v", sp.Status)
+	}
+	if len(sp.Events) != 1 || sp.Events[0].Name != tags.EventTestFailure {
+		t.Fatalf("unexpected events: %+v", sp.Events)
+	}
+	attributes := map[string]anyValue{}
+	for _, kv := range sp.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	if v := attributes["test.count"]; v.IntValue == nil || *v.IntValue != 3 {
+		t.Fatal("the int tag has not been converted")
+	}
+	if v := attributes[baggagePrefix+"trace.kind"]; v.StringValue == nil || *v.StringValue != "test" {
+		t.Fatal("the baggage has not been exported")
+	}
+}
+
+func TestProtobufExport(t *testing.T) {
+	c := newCollector()
+	defer c.server.Close()
+
+	raw := newRawSpan()
+	recorder := NewRecorder(c.server.URL+tracesPath, WithHeaders(map[string]string{"Authorization": "Bearer token"}))
+	recorder.RecordSpan(raw)
+	if err := recorder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.payloads) != 1 || c.types[0] != "application/x-protobuf" {
+		t.Fatalf("unexpected requests: %v", c.types)
+	}
+	// ExportTraceServiceRequest.resource_spans -> ResourceSpans.scope_spans -> ScopeSpans.spans
+	spanData, err := findField(c.payloads[0], 1, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceId, err := findField(spanData, 1)
+	if err != nil || !bytes.Equal(traceId, raw.Context.TraceID[:]) {
+		t.Fatalf("unexpected trace id: %x %v", traceId, err)
+	}
+	name, err := findField(spanData, 5)
+	if err != nil || string(name) != raw.Operation {
+		t.Fatalf("unexpected name: %s %v", name, err)
+	}
+	start, err := findField(spanData, 7)
+	if err != nil || binary.LittleEndian.Uint64(start) != uint64(raw.Start.UnixNano()) {
+		t.Fatalf("unexpected start time: %v", err)
+	}
+}
+
+// Finds the first value of a nested field in a protobuf message
+func findField(data []byte, path ...int) ([]byte, error) {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid key")
+		}
+		data = data[n:]
+		var value []byte
+		switch key & 7 {
+		case wireVarint:
+			_, n = binary.Uvarint(data)
+			value, data = data[:n], data[n:]
+		case wireFixed64:
+			value, data = data[:8], data[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			data = data[n:]
+			value, data = data[:length], data[length:]
+		default:
+			return nil, errors.New("unexpected wire type")
+		}
+		if int(key>>3) == path[0] {
+			if len(path) == 1 {
+				return value, nil
+			}
+			return findField(value, path[1:]...)
+		}
+	}
+	return nil, errors.New("field not found")
+}
@@ -0,0 +1,172 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Protobuf encoding of the OTLP trace data model, field numbers from opentelemetry/proto v1
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) tag(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) varint(value uint64) {
+	for value >= 0x80 {
+		b.data = append(b.data, byte(value)|0x80)
+		value >>= 7
+	}
+	b.data = append(b.data, byte(value))
+}
+
+func (b *protoBuffer) fixed64(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	b.tag(field, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], value)
+	b.data = append(b.data, buf[:]...)
+}
+
+func (b *protoBuffer) uvarint(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	b.tag(field, wireVarint)
+	b.varint(value)
+}
+
+func (b *protoBuffer) bytes(field int, value []byte) {
+	if len(value) == 0 {
+		return
+	}
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(value)))
+	b.data = append(b.data, value...)
+}
+
+func (b *protoBuffer) string(field int, value string) {
+	b.bytes(field, []byte(value))
+}
+
+// Writes an embedded message, it's always written (even if empty) to keep the repeated fields
+func (b *protoBuffer) message(field int, write func(*protoBuffer)) {
+	inner := &protoBuffer{}
+	write(inner)
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(inner.data)))
+	b.data = append(b.data, inner.data...)
+}
+
+func (r *exportTraceServiceRequest) marshalProto() []byte {
+	b := &protoBuffer{}
+	for idx := range r.ResourceSpans {
+		rs := &r.ResourceSpans[idx]
+		b.message(1, rs.marshalProto)
+	}
+	return b.data
+}
+
+func (rs *resourceSpans) marshalProto(b *protoBuffer) {
+	b.message(1, func(rb *protoBuffer) {
+		writeAttributes(rb, 1, rs.Resource.Attributes)
+	})
+	for idx := range rs.ScopeSpans {
+		ss := &rs.ScopeSpans[idx]
+		b.message(2, ss.marshalProto)
+	}
+}
+
+func (ss *scopeSpans) marshalProto(b *protoBuffer) {
+	b.message(1, func(sb *protoBuffer) {
+		sb.string(1, ss.Scope.Name)
+		sb.string(2, ss.Scope.Version)
+	})
+	for idx := range ss.Spans {
+		sp := &ss.Spans[idx]
+		b.message(2, sp.marshalProto)
+	}
+}
+
+func (s *span) marshalProto(b *protoBuffer) {
+	b.bytes(1, s.TraceId)
+	b.bytes(2, s.SpanId)
+	b.bytes(4, s.ParentSpanId)
+	b.string(5, s.Name)
+	b.uvarint(6, uint64(s.Kind))
+	b.fixed64(7, s.StartTimeUnixNano)
+	b.fixed64(8, s.EndTimeUnixNano)
+	writeAttributes(b, 9, s.Attributes)
+	for idx := range s.Events {
+		ev := &s.Events[idx]
+		b.message(11, func(eb *protoBuffer) {
+			eb.fixed64(1, ev.TimeUnixNano)
+			eb.string(2, ev.Name)
+			writeAttributes(eb, 3, ev.Attributes)
+		})
+	}
+	b.message(15, func(sb *protoBuffer) {
+		sb.string(2, s.Status.Message)
+		sb.uvarint(3, uint64(s.Status.Code))
+	})
+}
+
+func writeAttributes(b *protoBuffer, field int, attributes []keyValue) {
+	for idx := range attributes {
+		kv := &attributes[idx]
+		b.message(field, kv.marshalProto)
+	}
+}
+
+func (kv *keyValue) marshalProto(b *protoBuffer) {
+	b.string(1, kv.Key)
+	b.message(2, kv.Value.marshalProto)
+}
+
+func (v *anyValue) marshalProto(b *protoBuffer) {
+	// The AnyValue fields are a oneof, so zero values must be written
+	switch {
+	case v.StringValue != nil:
+		value := *v.StringValue
+		b.tag(1, wireBytes)
+		b.varint(uint64(len(value)))
+		b.data = append(b.data, value...)
+	case v.BoolValue != nil:
+		b.tag(2, wireVarint)
+		if *v.BoolValue {
+			b.varint(1)
+		} else {
+			b.varint(0)
+		}
+	case v.IntValue != nil:
+		b.tag(3, wireVarint)
+		b.varint(uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		b.tag(4, wireFixed64)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(*v.DoubleValue))
+		b.data = append(b.data, buf[:]...)
+	case v.ArrayValue != nil:
+		b.message(5, func(ab *protoBuffer) {
+			for idx := range v.ArrayValue.Values {
+				item := &v.ArrayValue.Values[idx]
+				ab.message(1, item.marshalProto)
+			}
+		})
+	case v.KvlistValue != nil:
+		b.message(6, func(kb *protoBuffer) {
+			writeAttributes(kb, 1, v.KvlistValue.Values)
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package coverage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+)
+
+// Decoders of the coverage data formats of the Go 1.20+ coverage implementation (`runtime/coverage` and the
+// `GOCOVERDIR` files), the formats are described in https://github.com/golang/go/blob/master/src/internal/coverage/defs.go
+
+type (
+	// Coverage meta-data of a package
+	covMetaPackage struct {
+		path  string
+		funcs []covMetaFunc
+	}
+
+	// Coverage meta-data of a function
+	covMetaFunc struct {
+		file   string // `{import path}/{file}.go` or the full path of the file
+		blocks []testing.CoverBlock
+	}
+
+	// Coverage meta-data file (`covmeta.{hash}`)
+	covMetaFile struct {
+		mode     string
+		packages []covMetaPackage
+	}
+
+	// Counters of a function in a counter data file (`covcounters.{hash}.{pid}.{time}`)
+	covFuncCounters struct {
+		pkg      uint32
+		fn       uint32
+		counters []uint32
+	}
+
+	// Little endian byte reader with the ULEB128 and string table encodings
+	covReader struct {
+		data []byte
+		off  int
+		err  error
+	}
+)
+
+const (
+	covMetaFilePrefix    = "covmeta"
+	covCounterFilePrefix = "covcounters"
+
+	covMetaFileHeaderSize    = 56
+	covCounterFileHeaderSize = 32
+	covCounterFooterSize     = 16
+
+	covCounterFlavorULeb128 = 2
+)
+
+var (
+	covMetaMagic    = []byte{0x00, 0x63, 0x76, 0x6d}
+	covCounterMagic = []byte{0x00, 0x63, 0x77, 0x6d}
+
+	errCovDataTruncated = errors.New("the coverage data is truncated")
+)
+
+// Gets the counter mode name
+func getCounterModeName(mode uint8) string {
+	switch mode {
+	case 1:
+		return "set"
+	case 2:
+		return "count"
+	case 3:
+		return "atomic"
+	}
+	return ""
+}
+
+// Decodes the coverage meta-data symbol of a package (emitted by the compiler)
+func decodeMetaPackage(data []byte) (*covMetaPackage, error) {
+	r := &covReader{data: data}
+	r.seek(4) // Length
+	r.seek(4) // PkgName
+	pkgPath := r.uint32()
+	r.seek(4 + 16 + 4) // ModulePath, MetaHash and padding
+	r.seek(4)          // NumFiles
+	numFuncs := r.uint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	funcOffsets := make([]uint32, numFuncs)
+	for i := range funcOffsets {
+		funcOffsets[i] = r.uint32()
+	}
+	table := r.stringTable()
+	if r.err != nil {
+		return nil, r.err
+	}
+	getString := func(idx uint64) string {
+		if idx < uint64(len(table)) {
+			return table[idx]
+		}
+		r.err = errors.New("invalid string table index in the coverage meta-data")
+		return ""
+	}
+
+	pkg := &covMetaPackage{path: getString(uint64(pkgPath)), funcs: make([]covMetaFunc, numFuncs)}
+	for i, offset := range funcOffsets {
+		r.off = int(offset)
+		numUnits := r.uleb128()
+		r.uleb128() // function name
+		fn := covMetaFunc{file: getString(r.uleb128())}
+		for u := uint64(0); u < numUnits && r.err == nil; u++ {
+			fn.blocks = append(fn.blocks, testing.CoverBlock{
+				Line0: uint32(r.uleb128()),
+				Col0:  uint16(r.uleb128()),
+				Line1: uint32(r.uleb128()),
+				Col1:  uint16(r.uleb128()),
+				Stmts: uint16(r.uleb128()),
+			})
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		pkg.funcs[i] = fn
+	}
+	return pkg, nil
+}
+
+// Decodes a coverage meta-data file
+func decodeMetaFile(data []byte) (*covMetaFile, error) {
+	if len(data) < covMetaFileHeaderSize || !bytes.Equal(data[:4], covMetaMagic) {
+		return nil, errors.New("invalid coverage meta-data file")
+	}
+	r := &covReader{data: data, off: 16}
+	entries := r.uint64()
+	r.seek(16 + 4 + 4) // MetaFileHash, StrTabOffset and StrTabLength
+	mode := r.uint8()
+	r.seek(1 + 6) // CGranularity and padding
+	offsets := make([]uint64, entries)
+	for i := range offsets {
+		offsets[i] = r.uint64()
+	}
+	lengths := make([]uint64, entries)
+	for i := range lengths {
+		lengths[i] = r.uint64()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	file := &covMetaFile{mode: getCounterModeName(mode)}
+	for i := range offsets {
+		end := offsets[i] + lengths[i]
+		if end > uint64(len(data)) {
+			return nil, errCovDataTruncated
+		}
+		pkg, err := decodeMetaPackage(data[offsets[i]:end])
+		if err != nil {
+			return nil, err
+		}
+		file.packages = append(file.packages, *pkg)
+	}
+	return file, nil
+}
+
+// Decodes a coverage counter data file, the counters of all the segments are returned
+func decodeCounterFile(data []byte) ([]covFuncCounters, error) {
+	if len(data) < covCounterFileHeaderSize+covCounterFooterSize || !bytes.Equal(data[:4], covCounterMagic) {
+		return nil, errors.New("invalid coverage counter data file")
+	}
+	footer := &covReader{data: data, off: len(data) - covCounterFooterSize + 8}
+	numSegments := footer.uint32()
+
+	r := &covReader{data: data, off: 4 + 4 + 16}
+	flavor := r.uint8()
+	bigEndian := r.uint8() != 0
+	r.off = covCounterFileHeaderSize
+
+	readValue := func() uint32 {
+		if flavor == covCounterFlavorULeb128 {
+			return uint32(r.uleb128())
+		}
+		if bigEndian {
+			if !r.check(4) {
+				return 0
+			}
+			r.off += 4
+			return binary.BigEndian.Uint32(r.data[r.off-4:])
+		}
+		return r.uint32()
+	}
+
+	var counters []covFuncCounters
+	for segment := uint32(0); segment < numSegments; segment++ {
+		if segment > 0 {
+			r.seek(covCounterFooterSize)
+		}
+		fnEntries := r.uint64()
+		strTabLen := r.uint32()
+		argsLen := r.uint32()
+		r.seek(int(strTabLen) + int(argsLen))
+		if rem := r.off % 4; rem != 0 {
+			r.seek(4 - rem)
+		}
+		for i := uint64(0); i < fnEntries && r.err == nil; i++ {
+			numCounters := readValue()
+			fn := covFuncCounters{pkg: readValue(), fn: readValue()}
+			for c := uint32(0); c < numCounters && r.err == nil; c++ {
+				fn.counters = append(fn.counters, readValue())
+			}
+			counters = append(counters, fn)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	return counters, nil
+}
+
+// Reads the coverage data files of a `GOCOVERDIR` folder (ex: written by a binary built with `go build -cover`)
+func readCoverDir(dir string) (*Profile, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	metaFiles := map[string]*covMetaFile{}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), covMetaFilePrefix+".") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		meta, err := decodeMetaFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name(), err)
+		}
+		metaFiles[strings.TrimPrefix(file.Name(), covMetaFilePrefix+".")] = meta
+	}
+
+	var profile *Profile
+	for _, file := range files {
+		// File name: `covcounters.{meta hash}.{pid}.{time}`
+		parts := strings.Split(file.Name(), ".")
+		if len(parts) != 4 || parts[0] != covCounterFilePrefix {
+			continue
+		}
+		meta, ok := metaFiles[parts[1]]
+		if !ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		counters, err := decodeCounterFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name(), err)
+		}
+		fileProfile := meta.getProfile(counters)
+		if profile == nil {
+			profile = fileProfile
+		} else {
+			profile.Merge(fileProfile)
+		}
+	}
+	return profile, nil
+}
+
+// Creates a temporal `GOCOVERDIR` folder
+func newCoverDir() (string, error) {
+	return ioutil.TempDir("", "scope-covdata")
+}
+
+// Removes a `GOCOVERDIR` folder
+func removeCoverDir(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		instrumentation.Logger().Printf("coverage error: %v", err)
+	}
+}
+
+// Gets the profile of the counters, all the blocks of the meta-data are included
+func (m *covMetaFile) getProfile(counters []covFuncCounters) *Profile {
+	profile := &Profile{Mode: m.mode, Blocks: map[string][]ProfileBlock{}}
+	byFunc := map[[2]uint32][]uint32{}
+	for _, fn := range counters {
+		byFunc[[2]uint32{fn.pkg, fn.fn}] = fn.counters
+	}
+	for pkgIdx, pkg := range m.packages {
+		for fnIdx, fn := range pkg.funcs {
+			fnCounters := byFunc[[2]uint32{uint32(pkgIdx), uint32(fnIdx)}]
+			for i, block := range fn.blocks {
+				count := 0
+				if i < len(fnCounters) {
+					count = int(fnCounters[i])
+				}
+				profile.addBlock(fn.file, block, count)
+			}
+		}
+	}
+	return profile
+}
+
+func (r *covReader) check(n int) bool {
+	if r.err == nil && r.off+n > len(r.data) {
+		r.err = errCovDataTruncated
+	}
+	return r.err == nil
+}
+
+func (r *covReader) seek(n int) {
+	if r.check(n) {
+		r.off += n
+	}
+}
+
+func (r *covReader) uint8() uint8 {
+	if !r.check(1) {
+		return 0
+	}
+	r.off++
+	return r.data[r.off-1]
+}
+
+func (r *covReader) uint32() uint32 {
+	if !r.check(4) {
+		return 0
+	}
+	r.off += 4
+	return binary.LittleEndian.Uint32(r.data[r.off-4:])
+}
+
+func (r *covReader) uint64() uint64 {
+	if !r.check(8) {
+		return 0
+	}
+	r.off += 8
+	return binary.LittleEndian.Uint64(r.data[r.off-8:])
+}
+
+func (r *covReader) uleb128() uint64 {
+	var value uint64
+	var shift uint
+	for {
+		b := r.uint8()
+		if r.err != nil {
+			return 0
+		}
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return value
+		}
+		shift += 7
+	}
+}
+
+func (r *covReader) stringTable() []string {
+	entries := r.uleb128()
+	var table []string
+	for i := uint64(0); i < entries && r.err == nil; i++ {
+		length := int(r.uleb128())
+		if !r.check(length) {
+			return nil
+		}
+		table = append(table, string(r.data[r.off:r.off+length]))
+		r.off += length
+	}
+	return table
+}
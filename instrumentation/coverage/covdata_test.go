@@ -0,0 +1,81 @@
+//go:build go1.20
+// +build go1.20
+
+package coverage
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const commandSource = `package main
+
+import "os"
+
+func main() {
+	if len(os.Args) > 1 {
+		println("args")
+		return
+	}
+	println("no args")
+}
+`
+
+func TestCommandCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "covcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(commandSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/covcmd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "covcmd")
+	build := exec.Command(filepath.Join(runtime.GOROOT(), "bin/go"), "build", "-cover", "-o", binary, ".")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOFLAGS=")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Skipf("the command can't be built with coverage: %v\n%s", err, out)
+	}
+
+	StartCoverage()
+	cmd := exec.Command(binary)
+	cmd.Env = []string{}
+	InjectCoverDir(&cmd.Env)
+	if len(cmd.Env) != 1 {
+		t.Fatalf("the GOCOVERDIR environment variable is missing: %v", cmd.Env)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if !IsEnabled() {
+		t.Fatal("the coverage must be enabled with the command coverage")
+	}
+	cov := EndCoverage()
+	if cov == nil {
+		t.Fatal("the coverage is missing")
+	}
+	blocks := cov.Profile().Blocks["example.com/covcmd/main.go"]
+	if len(blocks) != 3 {
+		t.Fatalf("the command blocks are missing: %v", cov.Profile().Blocks)
+	}
+	covered := 0
+	for _, block := range blocks {
+		if block.Count > 0 {
+			covered++
+		}
+	}
+	if covered != 2 {
+		t.Fatalf("the command coverage is invalid: %v", blocks)
+	}
+	if len(commandCoverDirs) != 0 {
+		t.Fatal("the command coverage folders must be removed")
+	}
+}
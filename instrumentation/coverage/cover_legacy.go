@@ -0,0 +1,34 @@
+//go:build !go1.20
+// +build !go1.20
+
+package coverage
+
+import (
+	"testing"
+	_ "unsafe"
+)
+
+//go:linkname cover testing.cover
+var cover testing.Cover
+
+// The counters are the variables of the running program, they are reset on each coverage session
+const liveCounters = true
+
+// Gets the coverage mode of the test binary, empty if the coverage is not enabled
+func coverMode() string {
+	return cover.Mode
+}
+
+// Gets the counters of the instrumented files
+func getCounterSets() []counterSet {
+	sets := make([]counterSet, 0, len(cover.Counters))
+	for name, counts := range cover.Counters {
+		sets = append(sets, counterSet{
+			key:      name,
+			file:     name,
+			blocks:   cover.Blocks[name],
+			counters: counts,
+		})
+	}
+	return sets
+}
@@ -0,0 +1,105 @@
+//go:build (go1.20 && !go1.23) || (go1.23 && scope_linkname)
+// +build go1.20,!go1.23 go1.23,scope_linkname
+
+package coverage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+)
+
+type (
+	// Mirror of the runtime coverage meta-data blob of a package (`internal/coverage/rtcov.CovMetaBlob`)
+	covMetaBlob struct {
+		P                  *byte
+		Len                uint32
+		Hash               [16]byte
+		PkgPath            string
+		PkgID              int
+		CounterMode        uint8
+		CounterGranularity uint8
+	}
+
+	// Mirror of the runtime coverage counters section of a module (`internal/coverage/rtcov.CovCounterBlob`)
+	covCounterBlob struct {
+		Counters *uint32
+		Len      uint64
+	}
+)
+
+// Offsets of the header of the counters of a function (`internal/coverage.NumCtrsOffset`, ...)
+const (
+	covNumCtrsOffset  = 0
+	covPkgIdOffset    = 1
+	covFuncIdOffset   = 2
+	covFirstCtrOffset = 3
+)
+
+// Decoded meta-data of the instrumented packages, by package index
+var metaPackages []covMetaPackage
+
+// The counters are the variables of the running program, they are reset on each coverage session
+const liveCounters = true
+
+// Gets the coverage mode of the test binary, empty if the coverage is not enabled
+func coverMode() string {
+	return testing.CoverMode()
+}
+
+// Gets the counters of the functions of the instrumented packages. The runtime only registers the counters
+// of a function the first time it runs, the counters of the functions not executed yet are nil.
+func getCounterSets() []counterSet {
+	metaList := getCovMetaList()
+	for len(metaPackages) < len(metaList) {
+		blob := metaList[len(metaPackages)]
+		pkg, err := decodeMetaPackage((*[1 << 30]byte)(unsafe.Pointer(blob.P))[:blob.Len:blob.Len])
+		if err != nil {
+			instrumentation.Logger().Printf("coverage error: %s: %v", blob.PkgPath, err)
+			pkg = &covMetaPackage{path: blob.PkgPath}
+		}
+		metaPackages = append(metaPackages, *pkg)
+	}
+
+	live := map[[2]uint32][]uint32{}
+	for _, blob := range getCovCounterList() {
+		section := (*[1 << 30]uint32)(unsafe.Pointer(blob.Counters))[:blob.Len:blob.Len]
+		for i := 0; i < len(section); i++ {
+			numCtrs := int(atomic.LoadUint32(&section[i+covNumCtrsOffset]))
+			if numCtrs == 0 {
+				continue
+			}
+			start := i + covFirstCtrOffset
+			if start+numCtrs > len(section) {
+				break
+			}
+			// The package id is the index + 1, negative ids are hardcoded ids of the runtime packages
+			pkgID := int32(atomic.LoadUint32(&section[i+covPkgIdOffset]))
+			funcID := atomic.LoadUint32(&section[i+covFuncIdOffset])
+			if pkgID > 0 {
+				live[[2]uint32{uint32(pkgID - 1), funcID}] = section[start : start+numCtrs]
+			}
+			i = start + numCtrs - 1
+		}
+	}
+
+	var sets []counterSet
+	for pkgIdx, pkg := range metaPackages {
+		for funcIdx, fn := range pkg.funcs {
+			ctrs := live[[2]uint32{uint32(pkgIdx), uint32(funcIdx)}]
+			if ctrs != nil && len(ctrs) != len(fn.blocks) {
+				ctrs = nil
+			}
+			sets = append(sets, counterSet{
+				key:      fmt.Sprintf("%d:%d", pkgIdx, funcIdx),
+				file:     fn.file,
+				blocks:   fn.blocks,
+				counters: ctrs,
+			})
+		}
+	}
+	return sets
+}
@@ -0,0 +1,12 @@
+//go:build go1.20 && !go1.23
+// +build go1.20,!go1.23
+
+package coverage
+
+import _ "unsafe"
+
+//go:linkname getCovMetaList runtime/coverage.getCovMetaList
+func getCovMetaList() []covMetaBlob
+
+//go:linkname getCovCounterList runtime/coverage.getCovCounterList
+func getCovCounterList() []covCounterBlob
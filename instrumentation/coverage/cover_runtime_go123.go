@@ -0,0 +1,99 @@
+//go:build go1.23 && !scope_linkname
+// +build go1.23,!scope_linkname
+
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	rtcoverage "runtime/coverage"
+	"sync"
+	"testing"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+)
+
+// The Go 1.23+ linker rejects the links to the internal coverage packages, so the counters are read with the
+// public `runtime/coverage` API. The counters are copies (they are not reset, the coverage of a session is the
+// difference with the session start) and they are only available with `-covermode=atomic` once the coverage
+// meta-data has been emitted, which in a test binary only happens at exit. The per test coverage of a test
+// binary requires the internal links: `go test -tags scope_linkname -ldflags=-checklinkname=0`.
+const liveCounters = false
+
+var (
+	metaOnce sync.Once
+	metaFile *covMetaFile
+	metaErr  error
+)
+
+// Gets the coverage mode of the test binary (or of a binary built with `-cover`), empty if the coverage is
+// not enabled
+func coverMode() string {
+	if mode := testing.CoverMode(); mode != "" {
+		return mode
+	}
+	if loadMetaFile() == nil {
+		return metaFile.mode
+	}
+	return ""
+}
+
+// Loads the coverage meta-data of the running program, the per test coverage is reported as not available once
+func loadMetaFile() error {
+	metaOnce.Do(func() {
+		var buffer bytes.Buffer
+		if metaErr = rtcoverage.WriteMeta(&buffer); metaErr == nil {
+			metaFile, metaErr = decodeMetaFile(buffer.Bytes())
+		}
+		if metaErr == nil {
+			metaErr = rtcoverage.WriteCounters(&bytes.Buffer{})
+		}
+		if metaErr != nil && testing.CoverMode() != "" {
+			message := "the per test coverage is not available with Go 1.23+, run the tests with " +
+				"`-tags scope_linkname -ldflags=-checklinkname=0` to enable it"
+			instrumentation.Logger().Printf("coverage: %s (%v)", message, metaErr)
+			fmt.Printf("[SCOPE COVERAGE] %s\n", message)
+		}
+	})
+	return metaErr
+}
+
+// Gets a copy of the counters of the functions of the instrumented packages, the counters of the functions
+// not executed yet are nil. Nil if the counters are not available.
+func getCounterSets() []counterSet {
+	if loadMetaFile() != nil {
+		return nil
+	}
+
+	var buffer bytes.Buffer
+	if err := rtcoverage.WriteCounters(&buffer); err != nil {
+		instrumentation.Logger().Printf("coverage error: %v", err)
+		return nil
+	}
+	fnCounters, err := decodeCounterFile(buffer.Bytes())
+	if err != nil {
+		instrumentation.Logger().Printf("coverage error: %v", err)
+		return nil
+	}
+	live := map[[2]uint32][]uint32{}
+	for _, fn := range fnCounters {
+		live[[2]uint32{fn.pkg, fn.fn}] = fn.counters
+	}
+
+	var sets []counterSet
+	for pkgIdx, pkg := range metaFile.packages {
+		for funcIdx, fn := range pkg.funcs {
+			ctrs := live[[2]uint32{uint32(pkgIdx), uint32(funcIdx)}]
+			if ctrs != nil && len(ctrs) != len(fn.blocks) {
+				ctrs = nil
+			}
+			sets = append(sets, counterSet{
+				key:      fmt.Sprintf("%d:%d", pkgIdx, funcIdx),
+				file:     fn.file,
+				blocks:   fn.blocks,
+				counters: ctrs,
+			})
+		}
+	}
+	return sets
+}
@@ -0,0 +1,93 @@
+//go:build go1.23 && !scope_linkname
+// +build go1.23,!scope_linkname
+
+package coverage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const sessionSource = `package main
+
+import (
+	"fmt"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation/coverage"
+)
+
+func sum(a, b int) int {
+	return a + b
+}
+
+func double(a int) int {
+	return a * 2
+}
+
+func main() {
+	_ = sum(1, 2)
+	coverage.StartCoverage()
+	_ = double(2)
+	_ = double(3)
+	cov := coverage.EndCoverage()
+	if cov == nil {
+		fmt.Println("no coverage")
+		return
+	}
+	for _, block := range cov.Profile().Blocks["example.com/covsession/main.go"] {
+		fmt.Printf("%d:%d\n", block.StartLine, block.Count)
+	}
+}
+`
+
+func TestPublicAPISessionCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "covsession")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	moduleDir, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod := fmt.Sprintf("module example.com/covsession\n\nrequire go.undefinedlabs.com/scopeagent v0.0.0\n\n"+
+		"replace go.undefinedlabs.com/scopeagent => %s\n", moduleDir)
+	goSum, _ := ioutil.ReadFile(filepath.Join(moduleDir, "go.sum"))
+	for name, data := range map[string][]byte{"main.go": []byte(sessionSource), "go.mod": []byte(goMod), "go.sum": goSum} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binary := filepath.Join(dir, "covsession")
+	build := exec.Command(filepath.Join(runtime.GOROOT(), "bin/go"), "build", "-cover", "-covermode=atomic",
+		"-coverpkg=example.com/covsession", "-o", binary, ".")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Skipf("the program can't be built with coverage: %v\n%s", err, out)
+	}
+	cmd := exec.Command(binary)
+	cmd.Env = append(os.Environ(), "GOCOVERDIR="+dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+
+	// Only the blocks executed in the session are covered: `double` twice, `sum` and `main` before the session
+	counts := strings.Fields(string(out))
+	var covered []string
+	for _, count := range counts {
+		if !strings.HasSuffix(count, ":0") {
+			covered = append(covered, count)
+		}
+	}
+	if len(counts) < 3 || len(covered) != 1 || covered[0] != "14:2" {
+		t.Fatalf("unexpected session coverage: %v", counts)
+	}
+}
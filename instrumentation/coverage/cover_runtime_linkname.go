@@ -0,0 +1,26 @@
+//go:build go1.23 && scope_linkname
+// +build go1.23,scope_linkname
+
+package coverage
+
+import _ "unsafe"
+
+// The Go 1.23+ linker rejects these links to the internal coverage packages, this file is only built with
+// `-tags scope_linkname -ldflags=-checklinkname=0`
+
+// Mirror of `internal/coverage/rtcov.Meta`
+//
+//go:linkname covMeta internal/coverage/rtcov.Meta
+var covMeta struct {
+	List   []covMetaBlob
+	PkgMap map[int]int
+
+	hardCodedListNeedsUpdating bool
+}
+
+//go:linkname getCovCounterList internal/coverage/cfile.getCovCounterList
+func getCovCounterList() []covCounterBlob
+
+func getCovMetaList() []covMetaBlob {
+	return covMeta.List
+}
@@ -15,7 +15,6 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
-	_ "unsafe"
 
 	"github.com/google/uuid"
 	"go.undefinedlabs.com/scopeagent/instrumentation"
@@ -27,6 +26,10 @@ type (
 		Version string         `json:"version" msgpack:"version"`
 		Uuid    string         `json:"uuid" msgpack:"uuid"`
 		Files   []fileCoverage `json:"files" msgpack:"files"`
+
+		// Covered lines ranges by file and coverage profile, used by the agent (not sent)
+		coveredLines map[string][][2]int
+		profile      *Profile
 	}
 	fileCoverage struct {
 		Filename   string  `json:"filename" msgpack:"filename"`
@@ -43,23 +46,37 @@ type (
 		block *testing.CoverBlock
 		count int
 	}
+
+	// Counters of a set of coverage blocks (a file with the legacy coverage, a function with the Go 1.20+ coverage)
+	counterSet struct {
+		key      string
+		file     string // `{import path}/{file}.go`
+		blocks   []testing.CoverBlock
+		counters []uint32 // Counters of the running program (or a copy), nil if they are not available yet
+	}
 )
 
-//go:linkname cover testing.cover
 var (
-	cover         testing.Cover
-	counters      map[string][]uint32
-	countersMutex sync.Mutex
-	filePathData  map[string]string
-	initOnce      sync.Once
+	counters         map[string][]uint32
+	countersMutex    sync.Mutex
+	filePathData     map[string]string
+	commandFilePaths = map[string]string{}
+	initOnce         sync.Once
+
+	sessionActive    bool
+	commandCoverDirs []string
 )
 
 // Initialize coverage
 func initCoverage() {
 	initOnce.Do(func() {
 		var files []string
-		for key := range cover.Blocks {
-			files = append(files, key)
+		fileSet := map[string]struct{}{}
+		for _, set := range getCounterSets() {
+			if _, ok := fileSet[set.file]; !ok {
+				fileSet[set.file] = struct{}{}
+				files = append(files, set.file)
+			}
 		}
 		pkgData, err := findPkgs(files)
 		if err != nil {
@@ -67,7 +84,7 @@ func initCoverage() {
 			instrumentation.Logger().Printf("coverage error: %v", err)
 		}
 		filePathData = map[string]string{}
-		for key := range cover.Blocks {
+		for _, key := range files {
 			filePath, err := findFile(pkgData, key)
 			if err != nil {
 				instrumentation.Logger().Printf("coverage error: %v", err)
@@ -79,19 +96,37 @@ func initCoverage() {
 	})
 }
 
+// Gets if the coverage is enabled (the test binary is instrumented or a command with coverage data was launched
+// in the current coverage session)
+func IsEnabled() bool {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	return coverMode() != "" || len(commandCoverDirs) > 0
+}
+
 // Clean the counters for a new coverage session
 func StartCoverage() {
 	countersMutex.Lock()
 	defer countersMutex.Unlock()
-	if cover.Mode == "" {
+	sessionActive = true
+	removeCommandCoverDirs()
+	if coverMode() == "" {
 		return
 	}
 	initCoverage()
 
-	for name, counts := range cover.Counters {
-		counters[name] = make([]uint32, len(counts))
-		for i := range counts {
-			counters[name][i] = atomic.SwapUint32(&counts[i], 0)
+	counters = map[string][]uint32{}
+	for _, set := range getCounterSets() {
+		if set.counters == nil {
+			continue
+		}
+		if !liveCounters {
+			counters[set.key] = set.counters
+			continue
+		}
+		counters[set.key] = make([]uint32, len(set.counters))
+		for i := range set.counters {
+			counters[set.key][i] = atomic.SwapUint32(&set.counters[i], 0)
 		}
 	}
 }
@@ -100,43 +135,106 @@ func StartCoverage() {
 func RestoreCoverageCounters() {
 	countersMutex.Lock()
 	defer countersMutex.Unlock()
-	if cover.Mode == "" {
+	sessionActive = false
+	removeCommandCoverDirs()
+	mode := coverMode()
+	if mode == "" || !liveCounters {
 		return
 	}
-	for name, counts := range cover.Counters {
-		for i := range counts {
-			atomic.StoreUint32(&counts[i], counters[name][i]+atomic.LoadUint32(&counts[i]))
+	for _, set := range getCounterSets() {
+		for i := range set.counters {
+			restoreCounter(mode, &set.counters[i], getSnapshotCount(set.key, i), atomic.LoadUint32(&set.counters[i]))
 		}
 	}
 }
 
+// Sets a `GOCOVERDIR` folder in the environment variables of a command launched by the running test, so the
+// coverage of the binaries built with `go build -cover` is added to the test coverage
+func InjectCoverDir(env *[]string) {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	if !sessionActive {
+		return
+	}
+	for _, item := range *env {
+		if strings.HasPrefix(item, "GOCOVERDIR=") {
+			return
+		}
+	}
+	dir, err := newCoverDir()
+	if err != nil {
+		instrumentation.Logger().Printf("coverage error: %v", err)
+		return
+	}
+	commandCoverDirs = append(commandCoverDirs, dir)
+	*env = append(*env, "GOCOVERDIR="+dir)
+}
+
 // Get the counters values and extract the coverage info
 func EndCoverage() *coverage {
 	countersMutex.Lock()
 	defer countersMutex.Unlock()
-	if cover.Mode == "" {
+	sessionActive = false
+	mode := coverMode()
+	profile := &Profile{Mode: mode, Blocks: map[string][]ProfileBlock{}}
+	if mode != "" {
+		for _, set := range getCounterSets() {
+			for i := range set.blocks {
+				count := uint32(0)
+				if set.counters != nil {
+					count = atomic.LoadUint32(&set.counters[i])
+					if liveCounters {
+						restoreCounter(mode, &set.counters[i], getSnapshotCount(set.key, i), count)
+					} else if snapshot := getSnapshotCount(set.key, i); count > snapshot {
+						count -= snapshot
+					} else {
+						count = 0
+					}
+				}
+				profile.addBlock(set.file, set.blocks[i], int(count))
+			}
+		}
+	}
+	if cmdProfile := getCommandsProfile(); cmdProfile != nil {
+		if profile.Mode == "" {
+			profile.Mode = cmdProfile.Mode
+		}
+		profile.Merge(cmdProfile)
+	}
+	// Without covered blocks there is no coverage to report (ex: the counters are not available)
+	if !profile.hasCoveredBlocks() {
 		return nil
 	}
+	paths := getProfilePaths(profile)
 
 	var covSource = map[string][]*blockWithCount{}
-	for name, counts := range cover.Counters {
-		if file, ok := filePathData[name]; ok {
-			blocks := cover.Blocks[name]
-			for i := range counts {
-				count := atomic.LoadUint32(&counts[i])
-				atomic.StoreUint32(&counts[i], counters[name][i]+count)
-				covSource[file] = append(covSource[file], &blockWithCount{
-					block: &blocks[i],
-					count: int(count),
-				})
-			}
-			sort.SliceStable(covSource[file][:], func(i, j int) bool {
-				if covSource[file][i].block.Line0 == covSource[file][j].block.Line0 {
-					return covSource[file][i].block.Col0 < covSource[file][j].block.Col0
-				}
-				return covSource[file][i].block.Line0 < covSource[file][j].block.Line0
+	var coveredLines = map[string][][2]int{}
+	for name, blocks := range profile.Blocks {
+		file, ok := paths[name]
+		if !ok {
+			continue
+		}
+		for _, block := range blocks {
+			covSource[file] = append(covSource[file], &blockWithCount{
+				block: &testing.CoverBlock{
+					Line0: uint32(block.StartLine),
+					Col0:  uint16(block.StartCol),
+					Line1: uint32(block.EndLine),
+					Col1:  uint16(block.EndCol),
+					Stmts: uint16(block.NumStmt),
+				},
+				count: block.Count,
 			})
+			if block.Count > 0 {
+				coveredLines[file] = append(coveredLines[file], [2]int{block.StartLine, block.EndLine})
+			}
 		}
+		sort.SliceStable(covSource[file][:], func(i, j int) bool {
+			if covSource[file][i].block.Line0 == covSource[file][j].block.Line0 {
+				return covSource[file][i].block.Col0 < covSource[file][j].block.Col0
+			}
+			return covSource[file][i].block.Line0 < covSource[file][j].block.Line0
+		})
 	}
 
 	fileMap := map[string][][]int{}
@@ -220,10 +318,122 @@ func EndCoverage() *coverage {
 		Version: "0.2.0",
 		Uuid:    uuidValue.String(),
 		Files:   files,
+
+		coveredLines: coveredLines,
+		profile:      profile,
 	}
 	return coverageData
 }
 
+// Gets the lines ranges (start and end line) covered by file
+func (c coverage) CoveredLines() map[string][][2]int {
+	return c.coveredLines
+}
+
+// Gets the coverage profile of the test
+func (c coverage) Profile() *Profile {
+	return c.profile
+}
+
+// Gets the files instrumented for coverage, empty if the coverage is not enabled
+func GetInstrumentedFiles() []string {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	if coverMode() == "" {
+		return nil
+	}
+	initCoverage()
+	files := make([]string, 0, len(filePathData))
+	for _, file := range filePathData {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Gets the counter value of the coverage session start
+func getSnapshotCount(key string, index int) uint32 {
+	if snapshot, ok := counters[key]; ok && index < len(snapshot) {
+		return snapshot[index]
+	}
+	return 0
+}
+
+// Restores a counter adding the value of the coverage session start, in `set` mode the counter is 0 or 1
+func restoreCounter(mode string, counter *uint32, snapshot uint32, count uint32) {
+	value := snapshot + count
+	if mode == "set" && value > 1 {
+		value = 1
+	}
+	atomic.StoreUint32(counter, value)
+}
+
+// Gets the coverage profile of the commands launched in the coverage session
+func getCommandsProfile() *Profile {
+	defer removeCommandCoverDirs()
+	var profile *Profile
+	for _, dir := range commandCoverDirs {
+		dirProfile, err := readCoverDir(dir)
+		if err != nil {
+			instrumentation.Logger().Printf("coverage error: %v", err)
+			continue
+		}
+		if dirProfile == nil {
+			continue
+		}
+		if profile == nil {
+			profile = dirProfile
+		} else {
+			profile.Merge(dirProfile)
+		}
+	}
+	return profile
+}
+
+func removeCommandCoverDirs() {
+	for _, dir := range commandCoverDirs {
+		removeCoverDir(dir)
+	}
+	commandCoverDirs = nil
+}
+
+// Gets the paths of the files of a profile, the files not instrumented in the test binary (ex: from a command)
+// are searched once
+func getProfilePaths(profile *Profile) map[string]string {
+	paths := map[string]string{}
+	var missing []string
+	for name := range profile.Blocks {
+		if filePath, ok := filePathData[name]; ok {
+			paths[name] = filePath
+		} else if filePath, ok := commandFilePaths[name]; ok {
+			if filePath != "" {
+				paths[name] = filePath
+			}
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return paths
+	}
+	pkgData, err := findPkgs(missing)
+	if err != nil {
+		instrumentation.Logger().Printf("coverage error: %v", err)
+		pkgData = map[string]*pkg{}
+	}
+	for _, name := range missing {
+		filePath, err := findFile(pkgData, name)
+		if err != nil {
+			instrumentation.Logger().Printf("coverage error: %v", err)
+		}
+		commandFilePaths[name] = filePath
+		if filePath != "" {
+			paths[name] = filePath
+		}
+	}
+	return paths
+}
+
 func contains(outer, inner *testing.CoverBlock) bool {
 	if outer != nil && inner != nil {
 		if outer.Line0 > inner.Line0 || (outer.Line0 == inner.Line0 && outer.Col0 > inner.Col0) {
@@ -0,0 +1,38 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSessionCoverage(t *testing.T) {
+	if testing.CoverMode() == "" {
+		t.Skip("the coverage is not enabled")
+	}
+	if len(getCounterSets()) == 0 {
+		t.Skip("the per test coverage is not available (Go 1.23+ without the scope_linkname build tag)")
+	}
+	StartCoverage()
+	_ = getRate(1, 2)
+	cov := EndCoverage()
+	if cov == nil {
+		t.Fatal("the coverage is missing")
+	}
+	covered := false
+	for name, blocks := range cov.Profile().Blocks {
+		if !strings.HasSuffix(name, "profile.go") {
+			continue
+		}
+		for _, block := range blocks {
+			if block.Count > 0 {
+				covered = true
+			}
+		}
+	}
+	if !covered {
+		t.Fatalf("the covered blocks of profile.go are missing: %v", cov.Profile().Blocks)
+	}
+	if len(cov.CoveredLines()) == 0 {
+		t.Fatal("the covered lines are missing")
+	}
+}
@@ -0,0 +1,355 @@
+package coverage
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type (
+	// Coverage profile, the same data as a `go test -coverprofile` file
+	Profile struct {
+		Mode   string
+		Blocks map[string][]ProfileBlock // By file name (`{import path}/{file}.go`)
+	}
+
+	ProfileBlock struct {
+		StartLine int
+		StartCol  int
+		EndLine   int
+		EndCol    int
+		NumStmt   int
+		Count     int
+	}
+
+	coberturaCoverage struct {
+		XMLName         xml.Name           `xml:"coverage"`
+		LineRate        string             `xml:"line-rate,attr"`
+		BranchRate      string             `xml:"branch-rate,attr"`
+		LinesCovered    int                `xml:"lines-covered,attr"`
+		LinesValid      int                `xml:"lines-valid,attr"`
+		BranchesCovered int                `xml:"branches-covered,attr"`
+		BranchesValid   int                `xml:"branches-valid,attr"`
+		Complexity      int                `xml:"complexity,attr"`
+		Version         string             `xml:"version,attr"`
+		Timestamp       int64              `xml:"timestamp,attr"`
+		Sources         []string           `xml:"sources>source"`
+		Packages        []coberturaPackage `xml:"packages>package"`
+	}
+
+	coberturaPackage struct {
+		Name       string           `xml:"name,attr"`
+		LineRate   string           `xml:"line-rate,attr"`
+		BranchRate string           `xml:"branch-rate,attr"`
+		Complexity int              `xml:"complexity,attr"`
+		Classes    []coberturaClass `xml:"classes>class"`
+	}
+
+	coberturaClass struct {
+		Name       string          `xml:"name,attr"`
+		Filename   string          `xml:"filename,attr"`
+		LineRate   string          `xml:"line-rate,attr"`
+		BranchRate string          `xml:"branch-rate,attr"`
+		Complexity int             `xml:"complexity,attr"`
+		Methods    struct{}        `xml:"methods"`
+		Lines      []coberturaLine `xml:"lines>line"`
+	}
+
+	coberturaLine struct {
+		Number int `xml:"number,attr"`
+		Hits   int `xml:"hits,attr"`
+	}
+)
+
+// Gets the coverage profile of the whole test run, nil if the coverage is not enabled
+func GetRunProfile() *Profile {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	mode := coverMode()
+	if mode == "" {
+		return nil
+	}
+	profile := &Profile{Mode: mode, Blocks: map[string][]ProfileBlock{}}
+	for _, set := range getCounterSets() {
+		for i := range set.blocks {
+			count := 0
+			if set.counters != nil {
+				count = int(atomic.LoadUint32(&set.counters[i]))
+			}
+			profile.addBlock(set.file, set.blocks[i], count)
+		}
+	}
+	return profile
+}
+
+// Parses a coverage profile in the `go test -coverprofile` format
+func ParseProfile(r io.Reader) (*Profile, error) {
+	profile := &Profile{Blocks: map[string][]ProfileBlock{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "mode: ") {
+			profile.Mode = strings.TrimPrefix(line, "mode: ")
+			continue
+		}
+		// Line format: `{file}:{start line}.{start col},{end line}.{end col} {statements} {count}`
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid coverage profile line: %s", line)
+		}
+		var block ProfileBlock
+		if _, err := fmt.Sscanf(line[colon+1:], "%d.%d,%d.%d %d %d", &block.StartLine, &block.StartCol,
+			&block.EndLine, &block.EndCol, &block.NumStmt, &block.Count); err != nil {
+			return nil, fmt.Errorf("invalid coverage profile line: %s", line)
+		}
+		profile.Blocks[line[:colon]] = append(profile.Blocks[line[:colon]], block)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if profile.Mode == "" {
+		return nil, fmt.Errorf("the coverage profile mode is missing")
+	}
+	return profile, nil
+}
+
+// Merges the counts of other profile (ex: the profile of other test process), in `set` mode a block is
+// covered if it's covered in any profile, in the other modes the counts are added
+func (p *Profile) Merge(other *Profile) {
+	if other == nil {
+		return
+	}
+	for name, otherBlocks := range other.Blocks {
+		index := map[[4]int]int{}
+		for i, block := range p.Blocks[name] {
+			index[block.position()] = i
+		}
+		for _, otherBlock := range otherBlocks {
+			i, ok := index[otherBlock.position()]
+			if !ok {
+				p.Blocks[name] = append(p.Blocks[name], otherBlock)
+				index[otherBlock.position()] = len(p.Blocks[name]) - 1
+				continue
+			}
+			block := &p.Blocks[name][i]
+			if p.Mode == "set" {
+				if otherBlock.Count > block.Count {
+					block.Count = otherBlock.Count
+				}
+			} else {
+				block.Count += otherBlock.Count
+			}
+		}
+	}
+}
+
+// Writes the profile in the `go test -coverprofile` format
+func (p *Profile) WriteGo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "mode: %s\n", p.Mode); err != nil {
+		return err
+	}
+	for _, name := range p.fileNames() {
+		for _, block := range p.sortedBlocks(name) {
+			if _, err := fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", name, block.StartLine, block.StartCol,
+				block.EndLine, block.EndCol, block.NumStmt, block.Count); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Writes the profile in the LCOV tracefile format, the file names are replaced by the paths
+func (p *Profile) WriteLCOV(w io.Writer, paths map[string]string) error {
+	bw := bufio.NewWriter(w)
+	for _, name := range p.fileNames() {
+		lines := p.lineHits(name)
+		if _, err := fmt.Fprintf(bw, "SF:%s\n", getPath(paths, name)); err != nil {
+			return err
+		}
+		covered := 0
+		for _, line := range lines {
+			if line.Hits > 0 {
+				covered++
+			}
+			if _, err := fmt.Fprintf(bw, "DA:%d,%d\n", line.Number, line.Hits); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(lines), covered); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Writes the profile in the Cobertura XML format, the file names are replaced by the paths relative to the source root
+func (p *Profile) WriteCobertura(w io.Writer, paths map[string]string, sourceRoot string) error {
+	report := coberturaCoverage{
+		BranchRate: "0",
+		Version:    "1.9",
+		Timestamp:  time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if sourceRoot != "" {
+		report.Sources = []string{sourceRoot}
+	}
+	packages := map[string]*coberturaPackage{}
+	var pkgNames []string
+	pkgLines := map[string][2]int{}
+	for _, name := range p.fileNames() {
+		pkgName := path.Dir(name)
+		pkg, ok := packages[pkgName]
+		if !ok {
+			pkg = &coberturaPackage{Name: pkgName, BranchRate: "0"}
+			packages[pkgName] = pkg
+			pkgNames = append(pkgNames, pkgName)
+		}
+		filename := getPath(paths, name)
+		if sourceRoot != "" {
+			if rel, err := filepath.Rel(sourceRoot, filename); err == nil && !strings.HasPrefix(rel, "..") {
+				filename = filepath.ToSlash(rel)
+			}
+		}
+		class := coberturaClass{Name: path.Base(name), Filename: filename, BranchRate: "0"}
+		covered := 0
+		for _, line := range p.lineHits(name) {
+			if line.Hits > 0 {
+				covered++
+			}
+			class.Lines = append(class.Lines, line)
+		}
+		class.LineRate = getRate(covered, len(class.Lines))
+		pkg.Classes = append(pkg.Classes, class)
+
+		pkgCount := pkgLines[pkgName]
+		pkgLines[pkgName] = [2]int{pkgCount[0] + covered, pkgCount[1] + len(class.Lines)}
+		report.LinesCovered += covered
+		report.LinesValid += len(class.Lines)
+	}
+	for _, pkgName := range pkgNames {
+		pkg := packages[pkgName]
+		pkg.LineRate = getRate(pkgLines[pkgName][0], pkgLines[pkgName][1])
+		report.Packages = append(report.Packages, *pkg)
+	}
+	report.LineRate = getRate(report.LinesCovered, report.LinesValid)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "<!DOCTYPE coverage SYSTEM \"http://cobertura.sourceforge.net/xml/coverage-04.dtd\">\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Gets the paths of the files of the profile (the files not found are not included)
+func (p *Profile) GetFilePaths() map[string]string {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	if coverMode() != "" {
+		initCoverage()
+	}
+	// The profile can contain files of other test processes
+	return getProfilePaths(p)
+}
+
+func (p *Profile) addBlock(name string, block testing.CoverBlock, count int) {
+	p.Blocks[name] = append(p.Blocks[name], ProfileBlock{
+		StartLine: int(block.Line0),
+		StartCol:  int(block.Col0),
+		EndLine:   int(block.Line1),
+		EndCol:    int(block.Col1),
+		NumStmt:   int(block.Stmts),
+		Count:     count,
+	})
+}
+
+// Gets if any block of the profile has been executed
+func (p *Profile) hasCoveredBlocks() bool {
+	for _, blocks := range p.Blocks {
+		for _, block := range blocks {
+			if block.Count > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Profile) fileNames() []string {
+	names := make([]string, 0, len(p.Blocks))
+	for name := range p.Blocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *Profile) sortedBlocks(name string) []ProfileBlock {
+	blocks := append([]ProfileBlock(nil), p.Blocks[name]...)
+	sort.SliceStable(blocks, func(i, j int) bool {
+		if blocks[i].StartLine == blocks[j].StartLine {
+			return blocks[i].StartCol < blocks[j].StartCol
+		}
+		return blocks[i].StartLine < blocks[j].StartLine
+	})
+	return blocks
+}
+
+// Gets the hits of each line with statements, a line is hit if any block in the line is hit
+func (p *Profile) lineHits(name string) []coberturaLine {
+	hits := map[int]int{}
+	for _, block := range p.Blocks[name] {
+		if block.NumStmt == 0 {
+			continue
+		}
+		for line := block.StartLine; line <= block.EndLine; line++ {
+			if current, ok := hits[line]; !ok || block.Count > current {
+				hits[line] = block.Count
+			}
+		}
+	}
+	lines := make([]coberturaLine, 0, len(hits))
+	for number, count := range hits {
+		lines = append(lines, coberturaLine{Number: number, Hits: count})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Number < lines[j].Number })
+	return lines
+}
+
+func (b ProfileBlock) position() [4]int {
+	return [4]int{b.StartLine, b.StartCol, b.EndLine, b.EndCol}
+}
+
+func getPath(paths map[string]string, name string) string {
+	if filePath, ok := paths[name]; ok {
+		return filePath
+	}
+	return name
+}
+
+func getRate(covered int, total int) string {
+	if total == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(covered)/float64(total), 'f', 4, 64)
+}
@@ -0,0 +1,81 @@
+package coverage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testProfile = `mode: count
+example.com/pkg/calc.go:3.20,5.2 1 2
+example.com/pkg/calc.go:7.20,9.2 1 0
+`
+
+func TestParseAndWriteProfile(t *testing.T) {
+	profile, err := ParseProfile(strings.NewReader(testProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Mode != "count" || len(profile.Blocks["example.com/pkg/calc.go"]) != 2 {
+		t.Fatalf("the parsed profile is invalid: %v", profile)
+	}
+	var buffer bytes.Buffer
+	if err := profile.WriteGo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != testProfile {
+		t.Fatalf("the written profile is invalid:\n%s", buffer.String())
+	}
+	if _, err := ParseProfile(strings.NewReader("example.com/pkg/calc.go:3.20,5.2 1 2")); err == nil {
+		t.Fatal("a profile without mode must fail")
+	}
+}
+
+func TestMergeProfile(t *testing.T) {
+	profile, _ := ParseProfile(strings.NewReader(testProfile))
+	other, _ := ParseProfile(strings.NewReader(`mode: count
+example.com/pkg/calc.go:7.20,9.2 1 3
+example.com/pkg/other.go:1.10,2.2 1 1
+`))
+	profile.Merge(other)
+	blocks := profile.sortedBlocks("example.com/pkg/calc.go")
+	if blocks[0].Count != 2 || blocks[1].Count != 3 || len(profile.Blocks["example.com/pkg/other.go"]) != 1 {
+		t.Fatalf("the merged profile is invalid: %v", profile.Blocks)
+	}
+
+	setProfile := &Profile{Mode: "set", Blocks: map[string][]ProfileBlock{
+		"a.go": {{StartLine: 1, EndLine: 2, NumStmt: 1, Count: 1}},
+	}}
+	setProfile.Merge(&Profile{Mode: "set", Blocks: map[string][]ProfileBlock{
+		"a.go": {{StartLine: 1, EndLine: 2, NumStmt: 1, Count: 1}},
+	}})
+	if setProfile.Blocks["a.go"][0].Count != 1 {
+		t.Fatalf("the merged count in set mode must be 1: %v", setProfile.Blocks)
+	}
+}
+
+func TestWriteLCOVAndCobertura(t *testing.T) {
+	profile, _ := ParseProfile(strings.NewReader(testProfile))
+	paths := map[string]string{"example.com/pkg/calc.go": "/src/pkg/calc.go"}
+
+	var lcov bytes.Buffer
+	if err := profile.WriteLCOV(&lcov, paths); err != nil {
+		t.Fatal(err)
+	}
+	expected := "SF:/src/pkg/calc.go\nDA:3,2\nDA:4,2\nDA:5,2\nDA:7,0\nDA:8,0\nDA:9,0\nLF:6\nLH:3\nend_of_record\n"
+	if lcov.String() != expected {
+		t.Fatalf("the LCOV file is invalid:\n%s", lcov.String())
+	}
+
+	var cobertura bytes.Buffer
+	if err := profile.WriteCobertura(&cobertura, paths, "/src"); err != nil {
+		t.Fatal(err)
+	}
+	xml := cobertura.String()
+	for _, value := range []string{`line-rate="0.5000"`, `lines-covered="3"`, `<package name="example.com/pkg"`,
+		`filename="pkg/calc.go"`, `<line number="3" hits="2"></line>`, "<source>/src</source>"} {
+		if !strings.Contains(xml, value) {
+			t.Fatalf("the Cobertura file doesn't contain '%s':\n%s", value, xml)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ginkgo
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/types"
+	. "github.com/onsi/gomega"
+
+	"go.undefinedlabs.com/scopeagent"
+	"go.undefinedlabs.com/scopeagent/agent"
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+var r *tracer.InMemorySpanRecorder
+
+func TestMain(m *testing.M) {
+	Init()
+
+	// Test tracer
+	r = tracer.NewInMemoryRecorder()
+	os.Exit(scopeagent.Run(m, agent.WithRecorders(r)))
+}
+
+var _ = Describe("Calculator", func() {
+	var value int
+
+	BeforeEach(func() {
+		value = 2
+	})
+
+	Context("when adding", func() {
+		It("returns the sum", func() {
+			Expect(value + 2).To(Equal(4))
+		})
+	})
+
+	It("is skipped", func() {
+		Skip("My skip reason")
+	})
+
+	PIt("is pending", func() {})
+})
+
+func TestGinkgo(t *testing.T) {
+	r.Reset()
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ginkgo Suite")
+
+	specs := map[string]tracer.RawSpan{}
+	hookSpans := 0
+	for _, span := range r.GetSpans() {
+		if span.Tags[tags.TestHook] == "BeforeEach" {
+			hookSpans++
+			continue
+		}
+		if span.Tags["span.kind"] == "test" {
+			specs[span.Tags["test.name"].(string)] = span
+		}
+	}
+	if hookSpans != 2 {
+		t.Fatalf("there aren't the right number of BeforeEach spans: %d", hookSpans)
+	}
+
+	sum, ok := specs["Calculator when adding returns the sum"]
+	if !ok {
+		t.Fatalf("the spec span is missing: %v", specs)
+	}
+	if sum.Tags["test.suite"] != "Ginkgo Suite" || sum.Tags["test.status"] != tags.TestStatus_PASS {
+		t.Fatalf("the spec span tags are invalid: %v", sum.Tags)
+	}
+	if code, _ := sum.Tags["test.code"].(string); !strings.HasSuffix(code, "ginkgo_test.go:37:39") {
+		t.Fatalf("the spec code is invalid: %v", sum.Tags["test.code"])
+	}
+	if specs["Calculator is skipped"].Tags["test.status"] != tags.TestStatus_SKIP {
+		t.Fatal("the skipped spec must have the SKIP status")
+	}
+	if specs["Calculator is pending"].Tags["test.status"] != tags.TestStatus_SKIP {
+		t.Fatal("the pending spec must have the SKIP status")
+	}
+}
+
+func TestWriteFailureEvent(t *testing.T) {
+	r.Reset()
+	span := instrumentation.Tracer().StartSpan("spec")
+	writeFailureEvent(span, types.SpecStateFailed, types.SpecFailure{
+		Message:  "Expected\n    <int>: 3\nto equal\n    <int>: 4",
+		Location: types.CodeLocation{FileName: "/src/calc_test.go", LineNumber: 12},
+	})
+	span.Finish()
+
+	spans := r.GetSpans()
+	if len(spans) != 1 || len(spans[0].Logs) != 1 {
+		t.Fatalf("the failure event is missing: %v", spans)
+	}
+	fields := map[string]string{}
+	for _, field := range spans[0].Logs[0].Fields {
+		fields[field.Key()] = field.Value().(string)
+	}
+	if fields[tags.EventType] != tags.EventTestFailure || fields[tags.EventSource] != "/src/calc_test.go:12" ||
+		!strings.HasPrefix(fields[tags.EventMessage], "Expected") {
+		t.Fatalf("the failure event is invalid: %v", fields)
+	}
+}
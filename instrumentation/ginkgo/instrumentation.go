@@ -0,0 +1,304 @@
+package ginkgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/undefinedlabs/go-mpatch"
+
+	"go.undefinedlabs.com/scopeagent/ast"
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/coverage"
+	"go.undefinedlabs.com/scopeagent/instrumentation/logging"
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/runner"
+	"go.undefinedlabs.com/scopeagent/tags"
+	scopetracer "go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Mirror of the ginkgo leaf node runner (`github.com/onsi/ginkgo/internal/leafnodes.runner`)
+	leafRunner struct {
+		isAsync          bool
+		asyncFunc        func(chan<- interface{})
+		syncFunc         func()
+		codeLocation     types.CodeLocation
+		timeoutThreshold time.Duration
+		nodeType         types.SpecComponentType
+		componentIndex   int
+		failer           unsafe.Pointer
+	}
+
+	// Ginkgo reporter writing a test span for each spec
+	scopeReporter struct {
+		suite    string
+		attempts map[string]int
+	}
+
+	Spec struct {
+		ctx  context.Context
+		span opentracing.Span
+	}
+)
+
+const framework = "github.com/onsi/ginkgo"
+
+var (
+	currentSpec      *Spec
+	currentSpecMutex sync.RWMutex
+)
+
+//go:linkname lRunSync github.com/onsi/ginkgo/internal/leafnodes.(*runner).runSync
+func lRunSync(r *leafRunner) (types.SpecState, types.SpecFailure)
+
+//go:linkname lRunAsync github.com/onsi/ginkgo/internal/leafnodes.(*runner).runAsync
+func lRunAsync(r *leafRunner) (types.SpecState, types.SpecFailure)
+
+func Init() {
+	var runSpecsPatch *mpatch.Patch
+	var err error
+	runSpecsPatch, err = mpatch.PatchMethod(ginkgo.RunSpecsWithCustomReporters,
+		func(t ginkgo.GinkgoTestingT, description string, specReporters []ginkgo.Reporter) bool {
+			runSpecsPatch.Unpatch()
+			defer runSpecsPatch.Patch()
+
+			if testingT, ok := t.(*testing.T); ok {
+				// Ginkgo handles the retries of the specs (`-ginkgo.flakeAttempts`)
+				runner.IgnoreRetries(testingT)
+
+				// We get the instrumented test struct and clean it, that removes the results of that test to be sent to scope
+				*scopetesting.GetTest(testingT) = scopetesting.Test{}
+			}
+
+			specReporters = append(specReporters, &scopeReporter{attempts: map[string]int{}})
+			return ginkgo.RunSpecsWithCustomReporters(t, description, specReporters)
+		})
+	logOnError(err)
+
+	var runSyncPatch *mpatch.Patch
+	runSyncPatch, err = mpatch.PatchMethod(lRunSync, func(r *leafRunner) (types.SpecState, types.SpecFailure) {
+		runSyncPatch.Unpatch()
+		defer runSyncPatch.Patch()
+		return runNode(r, lRunSync)
+	})
+	logOnError(err)
+
+	var runAsyncPatch *mpatch.Patch
+	runAsyncPatch, err = mpatch.PatchMethod(lRunAsync, func(r *leafRunner) (types.SpecState, types.SpecFailure) {
+		runAsyncPatch.Unpatch()
+		defer runAsyncPatch.Patch()
+		return runNode(r, lRunAsync)
+	})
+	logOnError(err)
+}
+
+func logOnError(err error) {
+	if err != nil {
+		instrumentation.Logger().Println(err)
+	}
+}
+
+// Runs a leaf node, the setup nodes (ex: `BeforeEach`, `AfterEach`) are run in a span (child of the spec span)
+func runNode(r *leafRunner, run func(r *leafRunner) (types.SpecState, types.SpecFailure)) (types.SpecState, types.SpecFailure) {
+	hook := getHookName(r.nodeType)
+	if hook == "" {
+		return run(r)
+	}
+
+	ctx := context.Background()
+	currentSpecMutex.RLock()
+	if currentSpec != nil && r.nodeType != types.SpecComponentTypeBeforeSuite && r.nodeType != types.SpecComponentTypeAfterSuite {
+		ctx = currentSpec.ctx
+	}
+	currentSpecMutex.RUnlock()
+
+	hookTags := opentracing.Tags{
+		"test.framework": framework,
+		tags.TestHook:    hook,
+	}
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, instrumentation.Tracer(), hook, hookTags)
+	state, failure := run(r)
+	if state.IsFailure() {
+		span.SetTag("error", true)
+		writeFailureEvent(span, state, failure)
+	}
+	span.Finish()
+	return state, failure
+}
+
+// Gets the hook name of a setup node type
+func getHookName(nodeType types.SpecComponentType) string {
+	switch nodeType {
+	case types.SpecComponentTypeBeforeSuite:
+		return "BeforeSuite"
+	case types.SpecComponentTypeAfterSuite:
+		return "AfterSuite"
+	case types.SpecComponentTypeBeforeEach:
+		return "BeforeEach"
+	case types.SpecComponentTypeJustBeforeEach:
+		return "JustBeforeEach"
+	case types.SpecComponentTypeJustAfterEach:
+		return "JustAfterEach"
+	case types.SpecComponentTypeAfterEach:
+		return "AfterEach"
+	}
+	return ""
+}
+
+// Gets the spec name from the texts of the containers and the spec (without the top level container)
+func getSpecName(summary *types.SpecSummary) string {
+	texts := summary.ComponentTexts
+	if len(texts) > 1 {
+		texts = texts[1:]
+	}
+	return strings.Join(texts, " ")
+}
+
+// Gets the source code boundaries of the spec
+func getSpecCode(summary *types.SpecSummary) string {
+	if len(summary.ComponentCodeLocations) == 0 {
+		return ""
+	}
+	location := summary.ComponentCodeLocations[len(summary.ComponentCodeLocations)-1]
+	if location.FileName == "" {
+		return ""
+	}
+	sourceBounds, err := ast.GetCallSource(location.FileName, location.LineNumber)
+	if err != nil {
+		instrumentation.Logger().Printf("error calculating the source boundaries for '%s': %v", getSpecName(summary), err)
+	}
+	if sourceBounds == nil {
+		return fmt.Sprintf("%s:%d:%d", location.FileName, location.LineNumber, location.LineNumber)
+	}
+	return fmt.Sprintf("%s:%d:%d", sourceBounds.File, sourceBounds.Start.Line, sourceBounds.End.Line)
+}
+
+// Writes the failure event of a spec or a setup node (ex: the gomega assertion message)
+func writeFailureEvent(span opentracing.Span, state types.SpecState, failure types.SpecFailure) {
+	message := failure.Message
+	if state == types.SpecStatePanicked && failure.ForwardedPanic != "" {
+		message = fmt.Sprintf("%s: %s", message, failure.ForwardedPanic)
+	}
+	if message == "" {
+		message = "Test failed"
+	}
+	fields := []log.Field{
+		log.String(tags.EventType, tags.EventTestFailure),
+		log.String(tags.EventMessage, message),
+		log.String("log.internal_level", "Fatal"),
+		log.String("log.logger", "ginkgo"),
+	}
+	if failure.Location.FileName != "" {
+		fields = append(fields, log.String(tags.EventSource,
+			fmt.Sprintf("%s:%d", failure.Location.FileName, failure.Location.LineNumber)))
+	}
+	if failure.Location.FullStackTrace != "" {
+		fields = append(fields, log.String(tags.EventStack, failure.Location.FullStackTrace))
+	}
+	span.LogFields(fields...)
+}
+
+func (r *scopeReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+	r.suite = summary.SuiteDescription
+}
+
+func (r *scopeReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+// Starts the span of the spec
+func (r *scopeReporter) SpecWillRun(specSummary *types.SpecSummary) {
+	name := getSpecName(specSummary)
+	testTags := opentracing.Tags{
+		"span.kind":      "test",
+		"test.name":      name,
+		"test.suite":     r.suite,
+		"test.framework": framework,
+		"test.language":  "go",
+	}
+	if testCode := getSpecCode(specSummary); testCode != "" {
+		testTags["test.code"] = testCode
+	}
+	if config.GinkgoConfig.FlakeAttempts > 1 {
+		r.attempts[name]++
+		testTags[tags.TestAttempt] = r.attempts[name]
+	}
+
+	span, ctx := opentracing.StartSpanFromContextWithTracer(context.Background(), instrumentation.Tracer(), name, testTags)
+	span.SetBaggageItem("trace.kind", "test")
+	currentSpecMutex.Lock()
+	currentSpec = &Spec{ctx: ctx, span: span}
+	currentSpecMutex.Unlock()
+
+	logging.Reset()
+	coverage.StartCoverage()
+}
+
+// Ends the span of the spec with the spec state
+func (r *scopeReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	finishTime := time.Now()
+	currentSpecMutex.Lock()
+	spec := currentSpec
+	currentSpec = nil
+	currentSpecMutex.Unlock()
+	if spec == nil {
+		return
+	}
+
+	finishOptions := opentracing.FinishOptions{
+		FinishTime: finishTime,
+		LogRecords: logging.GetRecords(),
+	}
+	if coverage.IsEnabled() {
+		if cov := coverage.EndCoverage(); cov != nil {
+			if span, ok := spec.span.(scopetracer.Span); ok {
+				span.UnsafeSetTag(tags.Coverage, *cov)
+			} else {
+				spec.span.SetTag(tags.Coverage, *cov)
+			}
+		}
+	}
+
+	switch specSummary.State {
+	case types.SpecStatePassed:
+		spec.span.SetTag("test.status", tags.TestStatus_PASS)
+	case types.SpecStatePending, types.SpecStateSkipped:
+		// The pending specs and the specs not focused or filtered by `-ginkgo.skip` are skipped
+		spec.span.SetTag("test.status", tags.TestStatus_SKIP)
+		reason := specSummary.Failure.Message
+		if specSummary.State == types.SpecStatePending {
+			reason = "Pending spec"
+		}
+		if reason != "" {
+			spec.span.LogFields(
+				log.String(tags.EventType, tags.EventTestSkip),
+				log.String(tags.EventMessage, reason),
+				log.String("log.internal_level", "Fatal"),
+				log.String("log.logger", "ginkgo"),
+			)
+		}
+	default:
+		spec.span.SetTag("test.status", tags.TestStatus_FAIL)
+		spec.span.SetTag("error", true)
+		writeFailureEvent(spec.span, specSummary.State, specSummary.Failure)
+	}
+
+	spec.span.FinishWithOptions(finishOptions)
+
+	// Once the spec doesn't fail or the attempts are exhausted, the next execution is a new run (ex: `go test -count=N`)
+	if name := getSpecName(specSummary); !specSummary.HasFailureState() || r.attempts[name] >= config.GinkgoConfig.FlakeAttempts {
+		delete(r.attempts, name)
+	}
+}
+
+func (r *scopeReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (r *scopeReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"math"
 	"reflect"
-	"testing"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -14,6 +13,8 @@ import (
 	"go.undefinedlabs.com/scopeagent/instrumentation"
 	"go.undefinedlabs.com/scopeagent/instrumentation/coverage"
 	"go.undefinedlabs.com/scopeagent/instrumentation/logging"
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/reflection"
 	"go.undefinedlabs.com/scopeagent/tags"
 	scopetracer "go.undefinedlabs.com/scopeagent/tracer"
 
@@ -51,8 +52,8 @@ func writeCachedResult(method *methodType) {
 	span.Finish()
 }
 
-// start test func
-func startTest(method *methodType, c *chk.C) *Test {
+// start test func, the attempt is the run number of the test when it's retried by the runner (0 without the runner)
+func startTest(method *methodType, c *chk.C, attempt int) *Test {
 	test := &Test{
 		method: method,
 		c:      c,
@@ -77,6 +78,10 @@ func startTest(method *methodType, c *chk.C) *Test {
 		testTags["test.code"] = testCode
 	}
 
+	if attempt > 0 {
+		testTags[tags.TestAttempt] = attempt
+	}
+
 	if test.ctx == nil {
 		test.ctx = context.Background()
 	}
@@ -102,7 +107,7 @@ func (test *Test) end(c *chk.C) {
 		LogRecords: logRecords,
 	}
 
-	if testing.CoverMode() != "" {
+	if coverage.IsEnabled() {
 		if cov := coverage.EndCoverage(); cov != nil {
 			if span, ok := test.span.(scopetracer.Span); ok {
 				span.UnsafeSetTag(tags.Coverage, *cov)
@@ -207,6 +212,13 @@ func writeBenchmarkResult(method *methodType, c *chk.C, tm timer) {
 	span.SetTag("benchmark.memory.mean_allocations", meanAllocsPerOp)
 	span.SetTag("benchmark.memory.mean_bytes_allocations", meanAllocedBytesPerOp)
 
+	benchmarkRunsMutex.Lock()
+	runs := benchmarkRuns[c]
+	delete(benchmarkRuns, c)
+	benchmarkRunsMutex.Unlock()
+	// go-check doesn't support custom metrics
+	scopetesting.SetBenchmarkResultTags(span, runs, tm.bytes, c.N, tm.duration, nil)
+
 	reason := getTestReason(c)
 	status := getTestStatus(c)
 	switch status {
@@ -258,3 +270,23 @@ func writeBenchmarkResult(method *methodType, c *chk.C, tm timer) {
 		FinishTime: tm.start.Add(tm.duration),
 	})
 }
+
+// Adds the result of the current run of the benchmark function
+func addBenchmarkRun(c *chk.C) {
+	// The timer is stopped by go-check after this function returns, stopping it twice has no effect
+	c.StopTimer()
+	ptr, err := reflection.GetFieldPointerOf(c, "timer")
+	if err != nil || c.N <= 0 {
+		return
+	}
+	tm := *(*timer)(ptr)
+	n := float64(c.N)
+	benchmarkRunsMutex.Lock()
+	defer benchmarkRunsMutex.Unlock()
+	benchmarkRuns[c] = append(benchmarkRuns[c], scopetesting.BenchmarkRun{
+		N:           c.N,
+		Duration:    math.Round(float64(tm.duration.Nanoseconds())/n*100) / 100,
+		Allocations: math.Round(float64(tm.netAllocs)/n*100) / 100,
+		Bytes:       math.Round(float64(tm.netBytes)/n*100) / 100,
+	})
+}
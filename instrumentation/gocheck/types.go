@@ -94,6 +94,7 @@ type (
 		options *runner.Options
 		test    *Test
 		writer  io.Writer
+		attempt int
 	}
 
 	testLogWriter struct {
@@ -113,6 +114,9 @@ const (
 var (
 	testMap      = map[*chk.C]*testData{}
 	testMapMutex = sync.RWMutex{}
+
+	benchmarkRuns      = map[*chk.C][]scopetesting.BenchmarkRun{}
+	benchmarkRunsMutex = sync.Mutex{}
 )
 
 //go:linkname nSRunner gopkg.in/check%2ev1.newSuiteRunner
@@ -142,6 +146,12 @@ func Init() {
 			item := r.tests[idx]
 
 			if strings.HasPrefix(item.Info.Name, "Benchmark") {
+				// The benchmark function is called once per run with an increasing N
+				benchFunc := func(c *chk.C) {
+					item.Call([]reflect.Value{reflect.ValueOf(c)})
+					addBenchmarkRun(c)
+				}
+				r.tests[idx] = &methodType{reflect.ValueOf(benchFunc), item.Info}
 				continue
 			}
 
@@ -162,7 +172,7 @@ func Init() {
 					testMapMutex.Unlock()
 				}()
 
-				test := startTest(item, c)
+				test := startTest(item, c, tData.attempt)
 				tData.test = test
 				tData.writer.(*testLogWriter).test = test
 				defer test.end(c)
@@ -303,6 +313,7 @@ func getRunnerTestFunc(tData *testData) func(*chk.C) {
 		tData.c = c
 		run := 1
 		for {
+			tData.attempt = run
 			wg := new(sync.WaitGroup)
 			wg.Add(1)
 			go func() {
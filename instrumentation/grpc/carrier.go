@@ -23,8 +23,10 @@ func (w metadataReaderWriter) Set(key, val string) {
 	// As such, since the HTTP_HEADERS format is case-insensitive anyway, we
 	// blindly lowercase the key (which is guaranteed to work in the
 	// Inject/Extract sense per the OpenTracing spec).
+	// The key is replaced, so each configured propagation format is injected only once even if
+	// the outgoing metadata already contains the span context of another call.
 	key = strings.ToLower(key)
-	w.MD[key] = append(w.MD[key], val)
+	w.MD[key] = []string{val}
 }
 
 func (w metadataReaderWriter) ForeachKey(handler func(key, val string) error) error {
@@ -9,6 +9,7 @@ import (
 	"github.com/opentracing/opentracing-go"
 
 	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/coverage"
 	scopetracer "go.undefinedlabs.com/scopeagent/tracer"
 )
 
@@ -36,6 +37,8 @@ func InjectToCmdWithSpan(ctx context.Context, command *exec.Cmd) (opentracing.Sp
 	innerSpan.SetTag("Path", command.Path)
 	innerSpan.SetTag("Dir", command.Dir)
 	InjectToCmd(innerCtx, command)
+	// The coverage of a binary built with `go build -cover` is added to the running test coverage
+	coverage.InjectCoverDir(&command.Env)
 	return innerSpan, innerCtx
 }
 
@@ -0,0 +1,217 @@
+package testify
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime/debug"
+	"sync"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/suite"
+	"github.com/undefinedlabs/go-mpatch"
+
+	"go.undefinedlabs.com/scopeagent/errors"
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/runner"
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+const framework = "github.com/stretchr/testify/suite"
+
+var testNameRegex = regexp.MustCompile("^Test")
+
+func Init() {
+	_, err := mpatch.PatchMethod(suite.Run, func(t *testing.T, s suite.TestingSuite) {
+		// We tell the runner to ignore retries on this testing.T, each suite method is retried instead
+		runner.IgnoreRetries(t)
+
+		// We get the instrumented test struct and clean it, that removes the results of that test to be sent to scope
+		*scopetesting.GetTest(t) = scopetesting.Test{}
+
+		runSuite(t, s)
+	})
+	logOnError(err)
+}
+
+func logOnError(err error) {
+	if err != nil {
+		instrumentation.Logger().Println(err)
+	}
+}
+
+// Runs the suite methods as sub tests, same as `suite.Run` but each method is reported as a test
+func runSuite(t *testing.T, s suite.TestingSuite) {
+	testsSync := &sync.WaitGroup{}
+	s.SetT(t)
+	defer failOnPanic(t, nil)
+
+	suiteName, pkgName := getSuiteName(s)
+	methodFinder := reflect.TypeOf(s)
+	var methods []reflect.Method
+	for index := 0; index < methodFinder.NumMethod(); index++ {
+		method := methodFinder.Method(index)
+		ok, err := methodFilter(method.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "testify: invalid regexp for -m: %s\n", err)
+			os.Exit(1)
+		}
+		if ok {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return
+	}
+
+	if setupAllSuite, ok := s.(suite.SetupAllSuite); ok {
+		runHook(context.Background(), pkgName, "SetupSuite", setupAllSuite.SetupSuite)
+	}
+	defer func() {
+		if tearDownAllSuite, ok := s.(suite.TearDownAllSuite); ok {
+			testsSync.Wait()
+			runHook(context.Background(), pkgName, "TearDownSuite", tearDownAllSuite.TearDownSuite)
+		}
+	}()
+
+	for _, method := range methods {
+		method := method
+		testsSync.Add(1)
+		var once sync.Once
+		runner.RunSubTest(t, method.Name, func(t *testing.T) {
+			// The retries run the func again, but the suite only waits for the first attempt
+			defer once.Do(testsSync.Done)
+			runTest(t, s, suiteName, pkgName, method)
+		})
+	}
+}
+
+// Runs a suite method with the test hooks, the test is reported in the suite package with the name
+// `{suite}.{method}` (same as gocheck) so the tests of suites with the same name in other packages don't collide
+func runTest(t *testing.T, s suite.TestingSuite, suiteName string, pkgName string, method reflect.Method) {
+	testName := fmt.Sprintf("%s.%s", suiteName, method.Name)
+	if isTestCached(pkgName, testName) {
+		writeCachedResult(pkgName, testName)
+		t.SkipNow()
+		return
+	}
+
+	parentT := s.T()
+	s.SetT(t)
+	defer s.SetT(parentT)
+
+	test := scopetesting.StartTestFromCaller(t, method.Func.Pointer(),
+		scopetesting.WithName(testName),
+		scopetesting.WithSuite(pkgName),
+		scopetesting.WithFramework(framework))
+	defer test.End()
+	defer failOnPanic(t, test)
+
+	if setupTestSuite, ok := s.(suite.SetupTestSuite); ok {
+		runHook(test.Context(), pkgName, "SetupTest", setupTestSuite.SetupTest)
+	}
+	if beforeTestSuite, ok := s.(suite.BeforeTest); ok {
+		runHook(test.Context(), pkgName, "BeforeTest", func() {
+			beforeTestSuite.BeforeTest(suiteName, method.Name)
+		})
+	}
+	defer func() {
+		if afterTestSuite, ok := s.(suite.AfterTest); ok {
+			runHook(test.Context(), pkgName, "AfterTest", func() {
+				afterTestSuite.AfterTest(suiteName, method.Name)
+			})
+		}
+		if tearDownTestSuite, ok := s.(suite.TearDownTestSuite); ok {
+			runHook(test.Context(), pkgName, "TearDownTest", tearDownTestSuite.TearDownTest)
+		}
+	}()
+	method.Func.Call([]reflect.Value{reflect.ValueOf(s)})
+}
+
+// Runs a suite hook in a span, the span is a child of the test span in the test hooks
+func runHook(ctx context.Context, pkgName string, hook string, fn func()) {
+	hookTags := opentracing.Tags{
+		"test.suite":     pkgName,
+		"test.framework": framework,
+		tags.TestHook:    hook,
+	}
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, instrumentation.Tracer(), hook, hookTags)
+	defer span.Finish()
+	defer func() {
+		if r := recover(); r != nil {
+			errors.WriteExceptionEvent(span, r, 1)
+			panic(r)
+		}
+	}()
+	fn()
+}
+
+// Fails the test on panic (same as testify), the exception is written in the test span
+func failOnPanic(t *testing.T, test *scopetesting.Test) {
+	r := recover()
+	if r != nil {
+		if test != nil {
+			if span := opentracing.SpanFromContext(test.Context()); span != nil {
+				errors.WriteExceptionEvent(span, r, 1)
+			}
+		}
+		t.Errorf("test panicked: %v\n%s", r, debug.Stack())
+		t.FailNow()
+	}
+}
+
+// Filters the suite methods using the testify `-testify.m` flag
+func methodFilter(name string) (bool, error) {
+	if !testNameRegex.MatchString(name) {
+		return false, nil
+	}
+	matchMethod := ""
+	if mFlag := flag.Lookup("testify.m"); mFlag != nil {
+		matchMethod = mFlag.Value.String()
+	}
+	return regexp.MatchString(matchMethod, name)
+}
+
+// Gets the suite name and package from the suite type
+func getSuiteName(s suite.TestingSuite) (string, string) {
+	sType := reflect.TypeOf(s)
+	if sType.Kind() == reflect.Ptr {
+		sType = sType.Elem()
+	}
+	return sType.Name(), sType.PkgPath()
+}
+
+// gets if the test is cached
+func isTestCached(suiteName string, testName string) bool {
+	fqn := fmt.Sprintf("%s.%s", suiteName, testName)
+	cachedMap := config.GetCachedTestsMap()
+	if _, ok := cachedMap[fqn]; ok {
+		instrumentation.Logger().Printf("Test '%v' is cached.", fqn)
+		fmt.Print("[SCOPE CACHED] ")
+		return true
+	}
+	instrumentation.Logger().Printf("Test '%v' is not cached.", fqn)
+	return false
+}
+
+// write cached result span
+func writeCachedResult(suiteName string, testName string) {
+	testTags := opentracing.Tags{
+		"span.kind":      "test",
+		"test.name":      testName,
+		"test.suite":     suiteName,
+		"test.framework": framework,
+		"test.language":  "go",
+	}
+
+	span, _ := opentracing.StartSpanFromContextWithTracer(context.Background(), instrumentation.Tracer(), testName, testTags)
+	span.SetBaggageItem("trace.kind", "test")
+	span.SetTag("test.status", tags.TestStatus_CACHE)
+	span.Finish()
+}
@@ -0,0 +1,103 @@
+package testify
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"go.undefinedlabs.com/scopeagent"
+	"go.undefinedlabs.com/scopeagent/agent"
+	scopetesting "go.undefinedlabs.com/scopeagent/instrumentation/testing"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+var (
+	r *tracer.InMemorySpanRecorder
+
+	flakyCount = 0
+	hooks      []string
+)
+
+func TestMain(m *testing.M) {
+	Init()
+
+	// Test tracer
+	r = tracer.NewInMemoryRecorder()
+	scopetesting.PatchTestingLogger()
+	defer scopetesting.UnpatchTestingLogger()
+	os.Exit(scopeagent.Run(m, agent.WithRecorders(r), agent.WithRetriesOnFail(2)))
+}
+
+type MySuite struct {
+	suite.Suite
+}
+
+func (s *MySuite) SetupSuite()    { hooks = append(hooks, "SetupSuite") }
+func (s *MySuite) TearDownSuite() { hooks = append(hooks, "TearDownSuite") }
+func (s *MySuite) SetupTest()     { hooks = append(hooks, "SetupTest") }
+func (s *MySuite) TearDownTest()  { hooks = append(hooks, "TearDownTest") }
+
+func (s *MySuite) TestPass() {
+	s.T().Log("Hello World")
+	s.Equal(1, 1)
+}
+
+func (s *MySuite) TestFlaky() {
+	flakyCount++
+	s.Equal(2, flakyCount)
+}
+
+func (s *MySuite) TestSkip() {
+	s.T().Skip("My skip reason")
+}
+
+func TestSuite(t *testing.T) {
+	r.Reset()
+	suite.Run(t, new(MySuite))
+
+	if flakyCount != 2 {
+		t.Fatalf("the flaky test ran an unexpected number of times: %d", flakyCount)
+	}
+	if len(hooks) != 10 || hooks[0] != "SetupSuite" || hooks[9] != "TearDownSuite" {
+		t.Fatalf("the suite hooks ran in an unexpected order: %v", hooks)
+	}
+
+	statuses := map[string][]string{}
+	hookSpans := 0
+	for _, span := range r.GetSpans() {
+		if span.Tags[tags.TestHook] != nil {
+			hookSpans++
+			continue
+		}
+		if span.Tags["span.kind"] != "test" {
+			continue
+		}
+		if span.Tags["test.suite"] != "go.undefinedlabs.com/scopeagent/instrumentation/testify" || span.Tags["test.framework"] != framework {
+			t.Fatalf("the test span tags are invalid: %v", span.Tags)
+		}
+		if code, _ := span.Tags["test.code"].(string); code == "" {
+			t.Fatalf("the test code of '%v' is empty", span.Operation)
+		}
+		name := span.Tags["test.name"].(string)
+		statuses[name] = append(statuses[name], span.Tags["test.status"].(string))
+	}
+	if hookSpans != 10 {
+		t.Fatalf("there aren't the right number of hook spans: %d", hookSpans)
+	}
+	checkStatuses(t, statuses["MySuite.TestPass"], tags.TestStatus_PASS)
+	checkStatuses(t, statuses["MySuite.TestFlaky"], tags.TestStatus_FAIL, tags.TestStatus_PASS)
+	checkStatuses(t, statuses["MySuite.TestSkip"], tags.TestStatus_SKIP)
+}
+
+func checkStatuses(t *testing.T, statuses []string, expected ...string) {
+	if len(statuses) != len(expected) {
+		t.Fatalf("expected statuses: %v, got: %v", expected, statuses)
+	}
+	for idx := range expected {
+		if statuses[idx] != expected[idx] {
+			t.Fatalf("expected statuses: %v, got: %v", expected, statuses)
+		}
+	}
+}
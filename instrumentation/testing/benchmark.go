@@ -2,6 +2,8 @@ package testing
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"go.undefinedlabs.com/scopeagent/tags"
 	"math"
 	"regexp"
@@ -20,7 +22,17 @@ import (
 
 type (
 	Benchmark struct {
-		b *testing.B
+		b    *testing.B
+		runs []BenchmarkRun
+	}
+
+	// Result of a run of the benchmark function, the testing package runs the function with an increasing N
+	// until the benchmark time is reached
+	BenchmarkRun struct {
+		N           int     `json:"n"`
+		Duration    float64 `json:"duration"`
+		Allocations float64 `json:"allocations"`
+		Bytes       float64 `json:"bytes"`
 	}
 )
 
@@ -80,8 +92,12 @@ func startBenchmark(b *testing.B, pc uintptr, benchFunc func(b *testing.B)) {
 	b.ResetTimer()
 	startTime := time.Now()
 	result := b.Run("*&", func(b1 *testing.B) {
-		addBenchmark(b1, &Benchmark{b: b1})
+		// The function is called once per run with the same *testing.B
+		if !hasBenchmark(b1) {
+			addBenchmark(b1, &Benchmark{b: b1})
+		}
 		benchFunc(b1)
+		GetBenchmark(b1).addRun()
 		bChild = b1
 	})
 	if bChild == nil {
@@ -141,8 +157,11 @@ func startBenchmark(b *testing.B, pc uintptr, benchFunc func(b *testing.B)) {
 	span.SetTag("benchmark.duration.mean", avg)
 	span.SetTag("benchmark.memory.mean_allocations", results.AllocsPerOp())
 	span.SetTag("benchmark.memory.mean_bytes_allocations", results.AllocedBytesPerOp())
+	SetBenchmarkResultTags(span, GetBenchmark(bChild).runs, results.Bytes, results.N, results.T, results.Extra)
 	if result {
 		span.SetTag("test.status", tags.TestStatus_PASS)
+		compareBenchmark(span, fmt.Sprintf("%s.%s", packageName, fullTestName),
+			avg, float64(results.AllocsPerOp()), float64(results.AllocedBytesPerOp()))
 	} else {
 		span.SetTag("test.status", tags.TestStatus_FAIL)
 	}
@@ -150,3 +169,38 @@ func startBenchmark(b *testing.B, pc uintptr, benchFunc func(b *testing.B)) {
 		FinishTime: startTime.Add(results.T),
 	})
 }
+
+// Adds the result of the current run of the benchmark function
+func (bench *Benchmark) addRun() {
+	// The timer is stopped by the testing package after this function returns, stopping it twice has no effect
+	bench.b.StopTimer()
+	duration, allocs, bytes, err := reflection.GetBenchmarkRunResult(bench.b)
+	if err != nil || bench.b.N <= 0 {
+		return
+	}
+	n := float64(bench.b.N)
+	bench.runs = append(bench.runs, BenchmarkRun{
+		N:           bench.b.N,
+		Duration:    math.Round(float64(duration.Nanoseconds())/n*100) / 100,
+		Allocations: math.Round(float64(allocs)/n*100) / 100,
+		Bytes:       math.Round(float64(bytes)/n*100) / 100,
+	})
+}
+
+// Sets the throughput (`b.SetBytes`), the custom metrics (`b.ReportMetric`) and the runs of a benchmark in the span
+func SetBenchmarkResultTags(span opentracing.Span, runs []BenchmarkRun, bytes int64, n int, duration time.Duration,
+	extra map[string]float64) {
+	if bytes > 0 && duration > 0 {
+		mbPerSec := (float64(bytes) * float64(n) / 1e6) / duration.Seconds()
+		span.SetTag("benchmark.bytes", bytes)
+		span.SetTag("benchmark.throughput.mb_per_sec", math.Round(mbPerSec*100)/100)
+	}
+	for unit, value := range extra {
+		span.SetTag(fmt.Sprintf("benchmark.metrics.%s", unit), value)
+	}
+	if len(runs) > 0 {
+		if data, err := json.Marshal(runs); err == nil {
+			span.SetTag("benchmark.samples", string(data))
+		}
+	}
+}
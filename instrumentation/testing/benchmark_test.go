@@ -0,0 +1,29 @@
+package testing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestSetBenchmarkResultTags(t *testing.T) {
+	span := mocktracer.New().StartSpan("benchmark").(*mocktracer.MockSpan)
+	runs := []BenchmarkRun{
+		{N: 1, Duration: 1500, Allocations: 2, Bytes: 1100},
+		{N: 1000, Duration: 1000, Allocations: 1, Bytes: 1024},
+	}
+	SetBenchmarkResultTags(span, runs, 1024, 1000, time.Millisecond, map[string]float64{"widgets/op": 42})
+
+	tags := span.Tags()
+	if tags["benchmark.throughput.mb_per_sec"] != 1024.0 {
+		t.Fatalf("unexpected throughput: %v", tags["benchmark.throughput.mb_per_sec"])
+	}
+	if tags["benchmark.metrics.widgets/op"] != 42.0 {
+		t.Fatalf("unexpected custom metric: %v", tags["benchmark.metrics.widgets/op"])
+	}
+	expected := `[{"n":1,"duration":1500,"allocations":2,"bytes":1100},{"n":1000,"duration":1000,"allocations":1,"bytes":1024}]`
+	if tags["benchmark.samples"] != expected {
+		t.Fatalf("unexpected samples: %v", tags["benchmark.samples"])
+	}
+}
@@ -0,0 +1,244 @@
+package testing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+type (
+	// Comparison of the samples of a benchmark metric against the baseline samples
+	benchmarkComparison struct {
+		baseline float64
+		current  float64
+		delta    float64
+		pValue   float64
+		tested   bool
+		verdict  string
+	}
+)
+
+const (
+	// Significance level of the Mann-Whitney U test
+	benchmarkAlpha = 0.05
+	// Max number of samples of the exact Mann-Whitney U distribution (`n1*n2`)
+	mannWhitneyExactLimit = 2500
+)
+
+var (
+	benchmarkSamplesMutex sync.Mutex
+	benchmarkSamples      = map[string]*config.BenchmarkSamples{}
+)
+
+// Compares the result of a benchmark with the baseline and sets the verdict in the span. The samples of the
+// runs of the same benchmark (`-count`) are accumulated, so the last span has the most accurate verdict
+func compareBenchmark(span opentracing.Span, fqn string, duration float64, allocations float64, bytes float64) {
+	baseline, threshold, ok := config.GetBenchmarkBaseline(fqn)
+	if !ok {
+		return
+	}
+	benchmarkSamplesMutex.Lock()
+	current, ok := benchmarkSamples[fqn]
+	if !ok {
+		current = &config.BenchmarkSamples{}
+		benchmarkSamples[fqn] = current
+	}
+	current.Durations = append(current.Durations, duration)
+	current.Allocations = append(current.Allocations, allocations)
+	current.Bytes = append(current.Bytes, bytes)
+	metrics := []struct {
+		name     string
+		baseline []float64
+		current  []float64
+	}{
+		{"duration", baseline.Durations, append([]float64{}, current.Durations...)},
+		{"memory.allocations", baseline.Allocations, append([]float64{}, current.Allocations...)},
+		{"memory.bytes_allocations", baseline.Bytes, append([]float64{}, current.Bytes...)},
+	}
+	benchmarkSamplesMutex.Unlock()
+
+	verdict := tags.BenchmarkVerdict_UNCHANGED
+	for _, metric := range metrics {
+		if len(metric.baseline) == 0 {
+			continue
+		}
+		comparison := compareBenchmarkSamples(metric.baseline, metric.current, threshold)
+		span.SetTag(fmt.Sprintf("benchmark.%s.baseline", metric.name), comparison.baseline)
+		span.SetTag(fmt.Sprintf("benchmark.%s.delta", metric.name), comparison.delta)
+		if comparison.tested {
+			span.SetTag(fmt.Sprintf("benchmark.%s.p_value", metric.name), comparison.pValue)
+		}
+		span.SetTag(fmt.Sprintf("benchmark.%s.verdict", metric.name), comparison.verdict)
+		if comparison.verdict == tags.BenchmarkVerdict_REGRESSION {
+			verdict = tags.BenchmarkVerdict_REGRESSION
+			instrumentation.Logger().Printf("benchmark regression in %s: %s %.2f -> %.2f (%+.2f%%)\n",
+				fqn, metric.name, comparison.baseline, comparison.current, comparison.delta*100)
+		} else if comparison.verdict == tags.BenchmarkVerdict_IMPROVEMENT && verdict == tags.BenchmarkVerdict_UNCHANGED {
+			verdict = tags.BenchmarkVerdict_IMPROVEMENT
+		}
+	}
+	span.SetTag(tags.BenchmarkVerdict, verdict)
+	config.SetBenchmarkRegression(fqn, verdict == tags.BenchmarkVerdict_REGRESSION)
+}
+
+// Compares the medians of the samples, the change is significant if it's greater than the threshold (ratio) and
+// the Mann-Whitney U test rejects that both samples have the same distribution. If there are not enough samples
+// to get a significant p-value (ex: a single run without `-count`), only the threshold is used
+func compareBenchmarkSamples(baseline []float64, current []float64, threshold float64) benchmarkComparison {
+	comparison := benchmarkComparison{
+		baseline: median(baseline),
+		current:  median(current),
+		verdict:  tags.BenchmarkVerdict_UNCHANGED,
+	}
+	if comparison.baseline != 0 {
+		comparison.delta = (comparison.current - comparison.baseline) / comparison.baseline
+	} else if comparison.current != 0 {
+		// There isn't a ratio from zero, any increase is a 100% change
+		comparison.delta = 1
+	}
+	if mannWhitneyMinPValue(len(baseline), len(current)) < benchmarkAlpha {
+		comparison.pValue = mannWhitneyPValue(baseline, current)
+		comparison.tested = true
+		if comparison.pValue >= benchmarkAlpha {
+			return comparison
+		}
+	}
+	if comparison.delta > threshold {
+		comparison.verdict = tags.BenchmarkVerdict_REGRESSION
+	} else if comparison.delta < -threshold {
+		comparison.verdict = tags.BenchmarkVerdict_IMPROVEMENT
+	}
+	return comparison
+}
+
+// Gets the median of the samples
+func median(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, samples...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// Gets the min two-sided p-value of the Mann-Whitney U test for the sample sizes (`2 / (n1+n2 choose n1)`)
+func mannWhitneyMinPValue(n1 int, n2 int) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	combinations := 1.0
+	for i := 1; i <= n1; i++ {
+		combinations = combinations * float64(n2+i) / float64(i)
+	}
+	return math.Min(1, 2/combinations)
+}
+
+// Gets the two-sided p-value of the Mann-Whitney U test of the samples. The exact distribution is used for small
+// samples without ties, and the normal approximation (with tie correction) otherwise
+func mannWhitneyPValue(x []float64, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type value struct {
+		value float64
+		isX   bool
+	}
+	values := make([]value, 0, n1+n2)
+	for _, v := range x {
+		values = append(values, value{v, true})
+	}
+	for _, v := range y {
+		values = append(values, value{v, false})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].value < values[j].value })
+
+	// Ranks (1 based) with the average rank for ties
+	var rankSumX, tieSum float64
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].value == values[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].isX {
+				rankSumX += rank
+			}
+		}
+		ties := float64(j - i)
+		tieSum += ties*ties*ties - ties
+		i = j
+	}
+	u := rankSumX - float64(n1*(n1+1))/2
+
+	if tieSum == 0 && n1*n2 <= mannWhitneyExactLimit {
+		distribution := mannWhitneyDistribution(n1, n2)
+		var lower, upper float64
+		for k, p := range distribution {
+			if float64(k) <= u {
+				lower += p
+			}
+			if float64(k) >= u {
+				upper += p
+			}
+		}
+		return math.Min(1, 2*math.Min(lower, upper))
+	}
+
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieSum/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// Gets the probabilities of each U value (from 0 to n1*n2) of samples of sizes n1 and n2 without ties
+func mannWhitneyDistribution(n1 int, n2 int) []float64 {
+	// counts[j][u] is the number of orderings of i `x` values and j `y` values with U = u
+	counts := make([][]float64, n2+1)
+	for j := range counts {
+		counts[j] = []float64{1}
+	}
+	for i := 1; i <= n1; i++ {
+		next := make([][]float64, n2+1)
+		next[0] = []float64{1}
+		for j := 1; j <= n2; j++ {
+			next[j] = make([]float64, i*j+1)
+			// The greatest value is from `x` (adds j to U) or from `y`
+			for u, count := range counts[j] {
+				next[j][u+j] += count
+			}
+			for u, count := range next[j-1] {
+				next[j][u] += count
+			}
+		}
+		counts = next
+	}
+	distribution := counts[n2]
+	var total float64
+	for _, count := range distribution {
+		total += count
+	}
+	for u := range distribution {
+		distribution[u] /= total
+	}
+	return distribution
+}
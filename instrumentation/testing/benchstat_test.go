@@ -0,0 +1,49 @@
+package testing
+
+import (
+	"math"
+	"testing"
+
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+func TestMannWhitneyPValue(t *testing.T) {
+	cases := []struct {
+		x, y   []float64
+		pValue float64
+	}{
+		// All the values of x are lower than the values of y: 2 / (6 choose 3)
+		{[]float64{1, 2, 3}, []float64{4, 5, 6}, 0.1},
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 2.0 / 252},
+		{[]float64{1, 3, 5}, []float64{2, 4, 6}, 0.7},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1}, 1},
+	}
+	for _, c := range cases {
+		if pValue := mannWhitneyPValue(c.x, c.y); math.Abs(pValue-c.pValue) > 1e-9 {
+			t.Errorf("unexpected p-value %v for %v and %v, expected %v", pValue, c.x, c.y, c.pValue)
+		}
+	}
+}
+
+func TestCompareBenchmarkSamples(t *testing.T) {
+	cases := []struct {
+		baseline, current []float64
+		verdict           string
+	}{
+		// Not enough samples for the test, only the threshold is used
+		{[]float64{100}, []float64{120}, tags.BenchmarkVerdict_REGRESSION},
+		{[]float64{100}, []float64{105}, tags.BenchmarkVerdict_UNCHANGED},
+		{[]float64{100}, []float64{80}, tags.BenchmarkVerdict_IMPROVEMENT},
+		// Significant change
+		{[]float64{100, 101, 99, 100, 102}, []float64{130, 128, 131, 129, 130}, tags.BenchmarkVerdict_REGRESSION},
+		// Not significant change
+		{[]float64{100, 140, 99, 130, 102}, []float64{130, 98, 131, 101, 129}, tags.BenchmarkVerdict_UNCHANGED},
+		// Allocations from zero
+		{[]float64{0}, []float64{1}, tags.BenchmarkVerdict_REGRESSION},
+	}
+	for _, c := range cases {
+		if comparison := compareBenchmarkSamples(c.baseline, c.current, 0.1); comparison.verdict != c.verdict {
+			t.Errorf("unexpected verdict %s for %v and %v, expected %s", comparison.verdict, c.baseline, c.current, c.verdict)
+		}
+	}
+}
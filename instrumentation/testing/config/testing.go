@@ -3,15 +3,41 @@ package config
 import (
 	"fmt"
 	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"sort"
 	"sync"
+	"time"
 )
 
 var (
-	testsToSkip map[string]struct{}
+	testsToSkip      map[string]struct{}
+	quarantinedTests map[string]struct{}
+
+	shuffleEnabled  bool
+	shuffleSeed     int64
+	shuffleSubTests bool
+
+	leakCheckEnabled bool
+	leakCheckGrace   time.Duration
+	leakCheckFail    bool
+
+	resourceMetricsDisabled bool
+
+	parallelCoverage bool
+
+	benchmarkBaselines   map[string]*BenchmarkSamples
+	benchmarkThreshold   float64
+	benchmarkRegressions map[string]struct{}
 
 	m sync.Mutex
 )
 
+// Samples of the results of a benchmark (one sample per benchmark run)
+type BenchmarkSamples struct {
+	Durations   []float64 `json:"durations"`
+	Allocations []float64 `json:"allocations"`
+	Bytes       []float64 `json:"bytes"`
+}
+
 // Gets the map of cached tests
 func GetCachedTestsMap() map[string]struct{} {
 	m.Lock()
@@ -35,3 +61,183 @@ func GetCachedTestsMap() map[string]struct{} {
 	}
 	return testsToSkip
 }
+
+// Adds tests (`{package}.{test name}`) to the cached tests map, they are skipped and reported as cached
+func AddCachedTests(fqns ...string) {
+	cachedMap := GetCachedTestsMap()
+	m.Lock()
+	defer m.Unlock()
+	for _, fqn := range fqns {
+		cachedMap[fqn] = struct{}{}
+	}
+}
+
+// Gets the map of quarantined tests, their failures don't fail the test run. The map is empty if the quarantine
+// mode is not enabled (the agent adds the quarantined tests)
+func GetQuarantinedTestsMap() map[string]struct{} {
+	m.Lock()
+	defer m.Unlock()
+
+	if quarantinedTests == nil {
+		quarantinedTests = map[string]struct{}{}
+	}
+	return quarantinedTests
+}
+
+// Gets the tests quarantined by the remote configuration (`{package}.{test name}`)
+func GetRemoteQuarantinedTests() []string {
+	config := instrumentation.GetRemoteConfiguration()
+	var fqns []string
+	if config != nil {
+		if iQuarantined, ok := config["quarantined"].([]interface{}); ok {
+			for _, item := range iQuarantined {
+				switch testItem := item.(type) {
+				case string:
+					fqns = append(fqns, testItem)
+				case map[string]interface{}:
+					fqns = append(fqns, fmt.Sprintf("%v.%v", testItem["test_suite"], testItem["test_name"]))
+				}
+			}
+		}
+	}
+	return fqns
+}
+
+// Adds tests (`{package}.{test name}`) to the quarantined tests map
+func AddQuarantinedTests(fqns ...string) {
+	quarantinedMap := GetQuarantinedTestsMap()
+	m.Lock()
+	defer m.Unlock()
+	for _, fqn := range fqns {
+		quarantinedMap[fqn] = struct{}{}
+	}
+}
+
+// Gets if a test (`{package}.{test name}`) is quarantined
+func IsTestQuarantined(fqn string) bool {
+	quarantinedMap := GetQuarantinedTestsMap()
+	m.Lock()
+	defer m.Unlock()
+	_, ok := quarantinedMap[fqn]
+	return ok
+}
+
+// Enables the shuffle of the tests order with a seed, the sub tests are shuffled with `Test.Shuffle` if subTests is true
+func SetShuffle(seed int64, subTests bool) {
+	m.Lock()
+	defer m.Unlock()
+	shuffleEnabled = true
+	shuffleSeed = seed
+	shuffleSubTests = subTests
+}
+
+// Gets the seed used to shuffle the tests, false if the shuffle is not enabled
+func GetShuffleSeed() (int64, bool) {
+	m.Lock()
+	defer m.Unlock()
+	return shuffleSeed, shuffleEnabled
+}
+
+// Gets if the sub tests order is shuffled
+func IsSubTestsShuffleEnabled() bool {
+	m.Lock()
+	defer m.Unlock()
+	return shuffleEnabled && shuffleSubTests
+}
+
+// Enables the goroutine leak check of the tests, the new goroutines still running after the grace period are
+// reported in the test span and the test fails if failTest is true
+func SetGoroutineLeakCheck(grace time.Duration, failTest bool) {
+	m.Lock()
+	defer m.Unlock()
+	leakCheckEnabled = true
+	leakCheckGrace = grace
+	leakCheckFail = failTest
+}
+
+// Disables the goroutine leak check
+func DisableGoroutineLeakCheck() {
+	m.Lock()
+	defer m.Unlock()
+	leakCheckEnabled = false
+}
+
+// Gets the goroutine leak check configuration, false if the check is not enabled
+func GetGoroutineLeakCheck() (grace time.Duration, failTest bool, enabled bool) {
+	m.Lock()
+	defer m.Unlock()
+	return leakCheckGrace, leakCheckFail, leakCheckEnabled
+}
+
+// Enables or disables the resource usage metrics (CPU time, memory allocations, GC, goroutines and open files)
+// in the test spans, the metrics are enabled by default
+func SetResourceMetrics(enabled bool) {
+	m.Lock()
+	defer m.Unlock()
+	resourceMetricsDisabled = !enabled
+}
+
+// Gets if the resource usage metrics are enabled
+func IsResourceMetricsEnabled() bool {
+	m.Lock()
+	defer m.Unlock()
+	return !resourceMetricsDisabled
+}
+
+// Enables the per test coverage of the parallel tests, the parallel tests are run sequentially when the coverage
+// is enabled so the coverage counters of each test are not mixed with the counters of other tests
+func SetParallelCoverage(enabled bool) {
+	m.Lock()
+	defer m.Unlock()
+	parallelCoverage = enabled
+}
+
+// Gets if the per test coverage of the parallel tests is enabled
+func IsParallelCoverageEnabled() bool {
+	m.Lock()
+	defer m.Unlock()
+	return parallelCoverage
+}
+
+// Sets the baseline samples of the benchmarks (`{package}.{benchmark name}`), the benchmarks are compared against
+// the baseline and the changes greater than the threshold (ratio) are reported as regressions or improvements
+func SetBenchmarkBaselines(baselines map[string]*BenchmarkSamples, threshold float64) {
+	m.Lock()
+	defer m.Unlock()
+	benchmarkBaselines = baselines
+	benchmarkThreshold = threshold
+}
+
+// Gets the baseline samples of a benchmark and the regression threshold, false if the benchmark doesn't have a baseline
+func GetBenchmarkBaseline(fqn string) (*BenchmarkSamples, float64, bool) {
+	m.Lock()
+	defer m.Unlock()
+	baseline, ok := benchmarkBaselines[fqn]
+	return baseline, benchmarkThreshold, ok
+}
+
+// Sets if the last comparison of a benchmark against the baseline is a regression
+func SetBenchmarkRegression(fqn string, regression bool) {
+	m.Lock()
+	defer m.Unlock()
+	if benchmarkRegressions == nil {
+		benchmarkRegressions = map[string]struct{}{}
+	}
+	if regression {
+		benchmarkRegressions[fqn] = struct{}{}
+	} else {
+		delete(benchmarkRegressions, fqn)
+	}
+}
+
+// Gets the benchmarks with a regression against the baseline
+func GetBenchmarkRegressions() []string {
+	m.Lock()
+	defer m.Unlock()
+	var fqns []string
+	for fqn := range benchmarkRegressions {
+		fqns = append(fqns, fqn)
+	}
+	sort.Strings(fqns)
+	return fqns
+}
@@ -0,0 +1,160 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/errors"
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+// Runs an instrumented example, the output is captured (and still written to the testing package) to report
+// the differences with the expected output
+func startExample(eg testing.InternalExample, pc uintptr, exampleFunc func()) {
+	pName, _, testCode := instrumentation.GetPackageAndNameAndBoundaries(pc)
+	oTags := opentracing.Tags{
+		"span.kind":      "test",
+		"test.name":      eg.Name,
+		"test.suite":     pName,
+		"test.framework": "testing",
+		"test.language":  "go",
+		"test.type":      "example",
+	}
+	if testCode != "" {
+		oTags["test.code"] = testCode
+	}
+	rPipe, wPipe, err := os.Pipe()
+	if err != nil {
+		instrumentation.Logger().Printf("error capturing the output of the example: %v\n", err)
+		exampleFunc()
+		return
+	}
+	span, _ := opentracing.StartSpanFromContextWithTracer(context.Background(), instrumentation.Tracer(), eg.Name, oTags)
+	span.SetBaggageItem("trace.kind", "test")
+
+	stdout := os.Stdout
+	os.Stdout = wPipe
+	outC := make(chan string)
+	go func() {
+		var buf strings.Builder
+		_, _ = io.Copy(io.MultiWriter(&buf, stdout), rPipe)
+		_ = rPipe.Close()
+		outC <- buf.String()
+	}()
+
+	finished := false
+	defer func() {
+		_ = wPipe.Close()
+		os.Stdout = stdout
+		output := <-outC
+		if r := recover(); r != nil {
+			span.SetTag("test.status", tags.TestStatus_FAIL)
+			errors.WriteExceptionEvent(span, r, 1)
+			span.Finish()
+			panic(r)
+		}
+		if !finished {
+			// runtime.Goexit was called
+			span.SetTag("test.status", tags.TestStatus_FAIL)
+			span.SetTag("error", true)
+		} else if !isExampleOutputEqual(output, eg.Output, eg.Unordered) {
+			span.SetTag("test.status", tags.TestStatus_FAIL)
+			span.SetTag("error", true)
+			writeExampleDiffEvent(span, output, eg.Output, eg.Unordered)
+		} else {
+			span.SetTag("test.status", tags.TestStatus_PASS)
+		}
+		span.Finish()
+	}()
+	exampleFunc()
+	finished = true
+}
+
+// Gets if the example output is the expected output (using the same rules as the testing package)
+func isExampleOutputEqual(got string, want string, unordered bool) bool {
+	gotLines := getExampleOutputLines(got)
+	wantLines := getExampleOutputLines(want)
+	if unordered {
+		sort.Strings(gotLines)
+		sort.Strings(wantLines)
+	}
+	return strings.Join(gotLines, "\n") == strings.Join(wantLines, "\n")
+}
+
+func getExampleOutputLines(output string) []string {
+	output = strings.TrimSpace(output)
+	if runtime.GOOS == "windows" {
+		output = strings.Replace(output, "\r\n", "\n", -1)
+	}
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
+
+// Writes the failure event with the expected output, the actual output and the diff between them
+func writeExampleDiffEvent(span opentracing.Span, got string, want string, unordered bool) {
+	gotLines := getExampleOutputLines(got)
+	wantLines := getExampleOutputLines(want)
+	if unordered {
+		sort.Strings(gotLines)
+		sort.Strings(wantLines)
+	}
+	span.LogFields(
+		log.String(tags.EventType, tags.EventTestFailure),
+		log.String(tags.EventMessage, "the output of the example doesn't match the expected output"),
+		log.String("example.output.expected", strings.TrimSpace(want)),
+		log.String("example.output.actual", strings.TrimSpace(got)),
+		log.String("example.output.diff", getLinesDiff(wantLines, gotLines)),
+		log.Bool("example.output.unordered", unordered),
+		log.String("log.internal_level", "Fatal"),
+		log.String("log.logger", "testing"),
+	)
+}
+
+// Gets the line diff between the expected and the actual lines (`-` for the expected lines missing in the actual
+// lines, `+` for the unexpected lines) using the longest common subsequence
+func getLinesDiff(expected []string, actual []string) string {
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			fmt.Fprintf(&diff, " %s\n", expected[i])
+			i++
+			j++
+		case j < len(actual) && (i == len(expected) || lcs[i][j+1] > lcs[i+1][j]):
+			fmt.Fprintf(&diff, "+%s\n", actual[j])
+			j++
+		default:
+			fmt.Fprintf(&diff, "-%s\n", expected[i])
+			i++
+		}
+	}
+	return diff.String()
+}
@@ -0,0 +1,23 @@
+package testing
+
+import "testing"
+
+func TestIsExampleOutputEqual(t *testing.T) {
+	if !isExampleOutputEqual("a\nb\n", "a\nb", false) {
+		t.Fatal("the output must be equal")
+	}
+	if isExampleOutputEqual("b\na\n", "a\nb", false) {
+		t.Fatal("the ordered output must be different")
+	}
+	if !isExampleOutputEqual("b\na\n", "a\nb", true) {
+		t.Fatal("the unordered output must be equal")
+	}
+}
+
+func TestGetLinesDiff(t *testing.T) {
+	diff := getLinesDiff([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	expected := " a\n-b\n+x\n c\n+d\n"
+	if diff != expected {
+		t.Fatalf("unexpected diff:\n%s\nexpected:\n%s", diff, expected)
+	}
+}
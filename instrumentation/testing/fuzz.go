@@ -0,0 +1,289 @@
+//go:build go1.18
+// +build go1.18
+
+package testing
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/undefinedlabs/go-mpatch"
+
+	"go.undefinedlabs.com/scopeagent/errors"
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/reflection"
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+type (
+	// Instrumented fuzz target
+	fuzzTarget struct {
+		f          *testing.F
+		span       opentracing.Span
+		ctx        context.Context
+		executions int64
+	}
+
+	// Statistics written by the fuzzing coordinator
+	fuzzStats struct {
+		executions int64
+		corpusSize int
+		ok         bool
+	}
+
+	// Mirror of the private testing.fuzzResult struct
+	fuzzResult struct {
+		N     int
+		T     time.Duration
+		Error error
+	}
+)
+
+// Seed corpus folder of the fuzz targets, relative to the package folder
+const fuzzCorpusDir = "testdata/fuzz"
+
+var (
+	fuzzPatch *mpatch.Patch
+
+	fuzzTargetsMutex sync.RWMutex
+	fuzzTargets      = map[*testing.F]*fuzzTarget{}
+
+	fuzzStatsRegex = regexp.MustCompile(`execs: (\d+) .*\(total: (\d+)\)`)
+)
+
+// Instruments the fuzz targets, the fuzz workers processes are not instrumented
+func initFuzzTargets(m *testing.M) {
+	if fw := flag.Lookup("test.fuzzworker"); fw != nil && fw.Value.String() == "true" {
+		return
+	}
+	fPointer, err := reflection.GetFieldPointerOf(m, "fuzzTargets")
+	if err != nil {
+		return
+	}
+	intFuzzTargets := (*[]testing.InternalFuzzTarget)(fPointer)
+	var fuzzTargets []testing.InternalFuzzTarget
+	for _, target := range *intFuzzTargets {
+		funcValue := target.Fn
+		funcPointer := reflect.ValueOf(funcValue).Pointer()
+		fuzzTargets = append(fuzzTargets, testing.InternalFuzzTarget{
+			Name: target.Name,
+			Fn: func(f *testing.F) { // Indirection of the original fuzz target
+				startFuzzTarget(f, funcPointer, funcValue)
+			},
+		})
+	}
+	*intFuzzTargets = fuzzTargets
+	if len(fuzzTargets) > 0 {
+		patchFuzz()
+	}
+}
+
+// Patches `testing.F.Fuzz` to instrument the runs of the fuzz function
+func patchFuzz() {
+	fuzzMethod, ok := reflect.TypeOf(&testing.F{}).MethodByName("Fuzz")
+	if !ok {
+		return
+	}
+	var err error
+	fuzzPatch, err = mpatch.PatchMethodByReflect(fuzzMethod, func(f *testing.F, ff interface{}) {
+		logOnError(fuzzPatch.Unpatch())
+		defer func() {
+			logOnError(fuzzPatch.Patch())
+		}()
+		f.Fuzz(instrumentFuzzFunc(f, ff))
+	})
+	logOnError(err)
+}
+
+// Runs an instrumented fuzz target
+func startFuzzTarget(f *testing.F, pc uintptr, fn func(f *testing.F)) {
+	fullTestName := f.Name()
+	pName, _, testCode := instrumentation.GetPackageAndNameAndBoundaries(pc)
+	mode := "seed"
+	if fz := flag.Lookup("test.fuzz"); fz != nil && fz.Value.String() != "" && getFuzzMode(f) == 1 {
+		mode = "fuzz"
+	}
+	oTags := opentracing.Tags{
+		"span.kind":      "test",
+		"test.name":      fullTestName,
+		"test.suite":     pName,
+		"test.framework": "testing",
+		"test.language":  "go",
+		"test.type":      "fuzz",
+		"fuzz.mode":      mode,
+	}
+	if testCode != "" {
+		oTags["test.code"] = testCode
+	}
+	span, ctx := opentracing.StartSpanFromContextWithTracer(context.Background(), instrumentation.Tracer(), fullTestName, oTags)
+	span.SetBaggageItem("trace.kind", "test")
+	target := &fuzzTarget{f: f, span: span, ctx: ctx}
+	fuzzTargetsMutex.Lock()
+	fuzzTargets[f] = target
+	fuzzTargetsMutex.Unlock()
+
+	var stats fuzzStats
+	defer func() {
+		fuzzTargetsMutex.Lock()
+		delete(fuzzTargets, f)
+		fuzzTargetsMutex.Unlock()
+		if r := recover(); r != nil {
+			span.SetTag("test.status", tags.TestStatus_FAIL)
+			errors.WriteExceptionEvent(span, r, 1)
+			span.Finish()
+			panic(r)
+		}
+		target.end(stats)
+	}()
+	if mode == "fuzz" {
+		// The workers executions are only reported in the coordinator output
+		stats = captureFuzzStats(func() { fn(f) })
+	} else {
+		fn(f)
+	}
+}
+
+// Sets the corpus size, executions and status of the fuzz target and finishes the span
+func (target *fuzzTarget) end(stats fuzzStats) {
+	corpusSize := getFuzzCorpusSize(target.f)
+	executions := atomic.LoadInt64(&target.executions)
+	if stats.ok {
+		corpusSize = stats.corpusSize
+		executions = stats.executions
+	}
+	target.span.SetTag("fuzz.corpus.size", corpusSize)
+	target.span.SetTag("fuzz.executions", executions)
+
+	if ptr, err := reflection.GetFieldPointerOf(target.f, "result"); err == nil {
+		if result := (*fuzzResult)(ptr); result.Error != nil {
+			target.writeCrashEvent(result.Error)
+		}
+	}
+	if target.f.Failed() {
+		target.span.SetTag("test.status", tags.TestStatus_FAIL)
+		target.span.SetTag("error", true)
+	} else if target.f.Skipped() {
+		target.span.SetTag("test.status", tags.TestStatus_SKIP)
+	} else {
+		target.span.SetTag("test.status", tags.TestStatus_PASS)
+	}
+	target.span.Finish()
+}
+
+// Writes the failing input found by the fuzzing engine, the error contains the output and panic stack of the input run
+func (target *fuzzTarget) writeCrashEvent(err error) {
+	message := err.Error()
+	source := ""
+	if crashErr, ok := err.(interface{ CrashPath() string }); ok {
+		source = crashErr.CrashPath()
+		message = fmt.Sprintf("failing input written to %s", source)
+		target.span.SetTag("fuzz.crash.path", source)
+	}
+	target.span.LogFields(
+		log.String(tags.EventType, tags.EventTestFailure),
+		log.String(tags.EventMessage, message),
+		log.String(tags.EventSource, source),
+		log.String(tags.EventStack, err.Error()),
+		log.String("log.internal_level", "Fatal"),
+		log.String("log.logger", "testing"),
+	)
+}
+
+// Wraps the fuzz function, each run of a seed corpus input is reported as a sub test of the fuzz target
+func instrumentFuzzFunc(f *testing.F, ff interface{}) interface{} {
+	fuzzTargetsMutex.RLock()
+	target, ok := fuzzTargets[f]
+	fuzzTargetsMutex.RUnlock()
+	fn := reflect.ValueOf(ff)
+	if !ok || fn.Kind() != reflect.Func || fn.Type().NumIn() < 2 || fn.Type().In(0) != reflect.TypeOf(&testing.T{}) {
+		// Invalid fuzz functions are reported by testing.F.Fuzz
+		return ff
+	}
+	pc := fn.Pointer()
+	return reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+		atomic.AddInt64(&target.executions, 1)
+		t := args[0].Interface().(*testing.T)
+		addAutoInstrumentedTest(t)
+		test := StartTestFromCaller(t, pc, WithContext(target.ctx))
+		if test.span != nil {
+			inputPath := filepath.Join(fuzzCorpusDir, f.Name(), filepath.Base(t.Name()))
+			if _, err := os.Stat(inputPath); err == nil {
+				test.span.SetTag("fuzz.input.path", inputPath)
+			}
+		}
+		defer test.end()
+		return fn.Call(args)
+	}).Interface()
+}
+
+// Gets the mode of the fuzz target (0: seed corpus only, 1: coordinator, 2: worker)
+func getFuzzMode(f *testing.F) int64 {
+	fValue := reflect.Indirect(reflect.ValueOf(f))
+	for _, stateField := range []string{"fstate", "fuzzContext"} {
+		state := fValue.FieldByName(stateField)
+		if state.IsValid() && state.Kind() == reflect.Ptr && !state.IsNil() {
+			switch mode := state.Elem().FieldByName("mode"); mode.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return mode.Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				return int64(mode.Uint())
+			}
+		}
+	}
+	return 0
+}
+
+// Gets the number of entries of the seed corpus (`f.Add` and testdata files)
+func getFuzzCorpusSize(f *testing.F) int {
+	if corpus := reflect.Indirect(reflect.ValueOf(f)).FieldByName("corpus"); corpus.IsValid() {
+		return corpus.Len()
+	}
+	return 0
+}
+
+// Runs the fuzzing coordinator capturing the statistics written to the standard error, the output is still
+// written to the original standard error
+func captureFuzzStats(run func()) (stats fuzzStats) {
+	rPipe, wPipe, err := os.Pipe()
+	if err != nil {
+		run()
+		return
+	}
+	stderr := os.Stderr
+	os.Stderr = wPipe
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(rPipe)
+		for scanner.Scan() {
+			line := scanner.Text()
+			_, _ = fmt.Fprintln(stderr, line)
+			if matches := fuzzStatsRegex.FindStringSubmatch(line); matches != nil {
+				stats.executions, _ = strconv.ParseInt(matches[1], 10, 64)
+				stats.corpusSize, _ = strconv.Atoi(matches[2])
+				stats.ok = true
+			}
+		}
+	}()
+	defer func() {
+		os.Stderr = stderr
+		_ = wPipe.Close()
+		<-done
+		_ = rPipe.Close()
+	}()
+	run()
+	return
+}
@@ -0,0 +1,9 @@
+//go:build !go1.18
+// +build !go1.18
+
+package testing
+
+import "testing"
+
+// Fuzzing is not supported before go1.18
+func initFuzzTargets(m *testing.M) {}
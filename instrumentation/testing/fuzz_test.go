@@ -0,0 +1,21 @@
+//go:build go1.18
+// +build go1.18
+
+package testing
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCaptureFuzzStats(t *testing.T) {
+	stats := captureFuzzStats(func() {
+		fmt.Fprintln(os.Stderr, "fuzz: elapsed: 0s, gathering baseline coverage: 0/2 completed")
+		fmt.Fprintln(os.Stderr, "fuzz: elapsed: 3s, execs: 41695 (13897/sec), new interesting: 1 (total: 3)")
+		fmt.Fprintln(os.Stderr, "fuzz: elapsed: 6s, execs: 83012 (13772/sec), new interesting: 2 (total: 4)")
+	})
+	if !stats.ok || stats.executions != 83012 || stats.corpusSize != 4 {
+		t.Fatalf("unexpected fuzz stats: %+v", stats)
+	}
+}
@@ -11,6 +11,9 @@ import (
 
 var (
 	parallel int
+
+	// Original test func pointers by test name
+	testFuncPointers = map[string]uintptr{}
 )
 
 // Initialize the testing instrumentation
@@ -28,6 +31,7 @@ func Init(m *testing.M) {
 		for _, test := range *intTests {
 			funcValue := test.F
 			funcPointer := reflect.ValueOf(funcValue).Pointer()
+			testFuncPointers[test.Name] = funcPointer
 			tests = append(tests, testing.InternalTest{
 				Name: test.Name,
 				F: func(t *testing.T) { // Creating a new test function as an indirection of the original test
@@ -56,4 +60,38 @@ func Init(m *testing.M) {
 		}
 		*intBenchmarks = benchmarks
 	}
+	if ePointer, err := reflection.GetFieldPointerOf(m, "examples"); err == nil {
+		intExamples := (*[]testing.InternalExample)(ePointer)
+		var examples []testing.InternalExample
+		for _, example := range *intExamples {
+			eg := example
+			funcPointer := reflect.ValueOf(eg.F).Pointer()
+			examples = append(examples, testing.InternalExample{
+				Name:      eg.Name,
+				Output:    eg.Output,
+				Unordered: eg.Unordered,
+				F: func() { // Indirection of the original example
+					startExample(eg, funcPointer, eg.F)
+				},
+			})
+		}
+		*intExamples = examples
+	}
+	initFuzzTargets(m)
+	patchParallel()
+}
+
+// Gets if a test of the suite is quarantined
+func IsQuarantined(test testing.InternalTest) bool {
+	return isTestQuarantined(GetTestSuite(test), test.Name)
+}
+
+// Gets the suite (package name) of a test
+func GetTestSuite(test testing.InternalTest) string {
+	funcPointer, ok := testFuncPointers[test.Name]
+	if !ok {
+		funcPointer = reflect.ValueOf(test.F).Pointer()
+	}
+	pkgName, _ := instrumentation.GetPackageAndName(funcPointer)
+	return pkgName
 }
@@ -0,0 +1,123 @@
+package testing
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+// Goroutines started or created by these functions are not reported as leaks (test runner, runtime and agent goroutines)
+var ignoredGoroutineFuncs = []string{
+	"testing.",
+	"runtime.",
+	"os/signal.",
+	"gopkg.in/tomb.v2.",
+	"go.undefinedlabs.com/scopeagent/agent.",
+	"go.undefinedlabs.com/scopeagent/instrumentation/logging.",
+	"go.undefinedlabs.com/scopeagent/runner.",
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing.(*resourceSampler).",
+}
+
+// Checks the goroutines started by the test and still running after the grace period
+func (test *Test) checkGoroutineLeaks() {
+	grace, failTest, enabled := config.GetGoroutineLeakCheck()
+	if !enabled || test.goroutines == nil {
+		return
+	}
+	leaked := findLeakedGoroutines(test.goroutines, grace)
+	if len(leaked) == 0 {
+		return
+	}
+	message := fmt.Sprintf("%d goroutines leaked by the test", len(leaked))
+	stacks := strings.Join(leaked, "\n\n")
+	test.span.SetTag(tags.TestGoroutineLeaks, len(leaked))
+	test.span.LogFields(
+		log.String(tags.EventType, tags.LogEvent),
+		log.String(tags.EventMessage, message),
+		log.String(tags.EventStack, stacks),
+		log.String(tags.LogEventLevel, tags.LogLevel_WARNING),
+	)
+	if failTest {
+		test.t.Errorf("%s:\n\n%s", message, stacks)
+	}
+}
+
+// Checks the goroutine leaks and finishes the test span, it runs after the cleanup funcs of the test (registered
+// at the test start) so the goroutines stopped by them (ex: `httptest.Server.Close`) are not reported as leaks
+func (test *Test) endAfterCleanup() {
+	finish := test.pendingFinish
+	if finish == nil {
+		return
+	}
+	test.pendingFinish = nil
+	test.checkGoroutineLeaks()
+	finish()
+}
+
+// Gets the stacks of the goroutines not included in the snapshot, waiting up to the grace period for them to finish
+func findLeakedGoroutines(snapshot map[int]string, grace time.Duration) []string {
+	deadline := time.Now().Add(grace)
+	for {
+		var leaked []string
+		for id, stack := range getGoroutines() {
+			if _, ok := snapshot[id]; !ok && !isIgnoredGoroutine(stack) {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			sort.Strings(leaked)
+			return leaked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Gets the stacks of all the goroutines by id
+func getGoroutines() map[int]string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	goroutines := map[int]string{}
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		var id int
+		if _, err := fmt.Sscanf(stack, "goroutine %d ", &id); err == nil {
+			goroutines[id] = strings.TrimSpace(stack)
+		}
+	}
+	return goroutines
+}
+
+// Gets if the goroutine entry func or the func that created it must be ignored
+func isIgnoredGoroutine(stack string) bool {
+	var entry, creator string
+	for _, line := range strings.Split(stack, "\n")[1:] {
+		if line == "" || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if strings.HasPrefix(line, "created by ") {
+			creator = strings.TrimPrefix(line, "created by ")
+		} else if !strings.HasPrefix(line, "runtime.goexit(") {
+			// The stack of a goroutine not started yet ends with `runtime.goexit`
+			entry = line
+		}
+	}
+	for _, prefix := range ignoredGoroutineFuncs {
+		if strings.HasPrefix(entry, prefix) || strings.HasPrefix(creator, prefix) {
+			return true
+		}
+	}
+	return false
+}
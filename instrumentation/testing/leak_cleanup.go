@@ -0,0 +1,10 @@
+//go:build go1.14
+// +build go1.14
+
+package testing
+
+// Registers the goroutine leak check as a cleanup func of the test, the first registered cleanup runs last
+func registerLeakCheckCleanup(test *Test) bool {
+	test.t.Cleanup(test.endAfterCleanup)
+	return true
+}
@@ -0,0 +1,55 @@
+//go:build go1.14
+// +build go1.14
+
+package testing
+
+import (
+	"testing"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func TestLeakCheckAfterCleanup(t *testing.T) {
+	recorder := tracer.NewInMemoryRecorder()
+	previousTracer := instrumentation.Tracer()
+	instrumentation.SetTracer(tracer.New(recorder))
+	defer instrumentation.SetTracer(previousTracer)
+	defer config.DisableGoroutineLeakCheck()
+
+	// The goroutine stopped by a cleanup func (ex: `httptest.Server.Close`) is not a leak
+	config.SetGoroutineLeakCheck(5*time.Second, true)
+	start := time.Now()
+	t.Run("StoppedInCleanup", func(st *testing.T) {
+		test := StartTest(st)
+		defer test.End()
+		stop := make(chan struct{})
+		go leakingFunc(stop)
+		st.Cleanup(func() { close(stop) })
+	})
+	spans := recorder.GetSpans()
+	if len(spans) != 1 || spans[0].Tags["test.status"] != tags.TestStatus_PASS || spans[0].Tags[tags.TestGoroutineLeaks] != nil {
+		t.Fatalf("the goroutine stopped in the cleanup has been reported as a leak: %v", spans)
+	}
+	if time.Since(start) >= 5*time.Second {
+		t.Fatal("the leak check has waited for the whole grace period")
+	}
+
+	// The goroutine still running after the cleanup funcs is a leak
+	recorder.Reset()
+	config.SetGoroutineLeakCheck(50*time.Millisecond, false)
+	stop := make(chan struct{})
+	defer close(stop)
+	t.Run("Leaked", func(st *testing.T) {
+		test := StartTest(st)
+		defer test.End()
+		go leakingFunc(stop)
+	})
+	spans = recorder.GetSpans()
+	if len(spans) != 1 || spans[0].Tags[tags.TestGoroutineLeaks] != 1 {
+		t.Fatalf("the leaked goroutine has not been reported: %v", spans)
+	}
+}
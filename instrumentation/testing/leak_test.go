@@ -0,0 +1,42 @@
+package testing
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindLeakedGoroutines(t *testing.T) {
+	snapshot := getGoroutines()
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go leakingFunc(stop)
+	go func() {
+		<-time.After(20 * time.Millisecond)
+		close(finished)
+	}()
+
+	leaked := findLeakedGoroutines(snapshot, 100*time.Millisecond)
+	close(stop)
+	if len(leaked) != 1 || !strings.Contains(leaked[0], "leakingFunc") {
+		t.Fatalf("unexpected leaked goroutines: %v", leaked)
+	}
+	<-finished
+}
+
+func TestIgnoredGoroutines(t *testing.T) {
+	stack := "goroutine 7 [chan receive]:\ngopkg.in/tomb.v2.(*Tomb).run(0xc0000a0000, 0xc0000b0000)\n" +
+		"\t/go/pkg/mod/gopkg.in/tomb.v2/tomb.go:163 +0x2b\ncreated by gopkg.in/tomb.v2.(*Tomb).Go\n" +
+		"\t/go/pkg/mod/gopkg.in/tomb.v2/tomb.go:159 +0xea"
+	if !isIgnoredGoroutine(stack) {
+		t.Fatal("the tomb goroutine must be ignored")
+	}
+	stack = "goroutine 8 [select]:\nmain.worker()\n\t/src/main.go:10 +0x2b\ncreated by main.start\n\t/src/main.go:5 +0xea"
+	if isIgnoredGoroutine(stack) {
+		t.Fatal("the worker goroutine must not be ignored")
+	}
+}
+
+func leakingFunc(stop chan struct{}) {
+	<-stop
+}
@@ -0,0 +1,34 @@
+package testing
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/undefinedlabs/go-mpatch"
+
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
+)
+
+var parallelPatch *mpatch.Patch
+
+// Patches `testing.T.Parallel` to run the parallel tests sequentially when the coverage and the per test coverage
+// of the parallel tests are enabled. The coverage counters are global, so the code executed by concurrent tests
+// can't be attributed to each test.
+func patchParallel() {
+	if testing.CoverMode() == "" || !config.IsParallelCoverageEnabled() {
+		return
+	}
+	parallelMethod, ok := reflect.TypeOf(&testing.T{}).MethodByName("Parallel")
+	if !ok {
+		return
+	}
+	var err error
+	parallelPatch, err = mpatch.PatchMethodByReflect(parallelMethod, func(t *testing.T) {
+		// The test continues running in the goroutine of the caller as a sequential test
+	})
+	logOnError(err)
+	if err == nil {
+		instrumentation.Logger().Println("the parallel tests are run sequentially to get the coverage of each test")
+	}
+}
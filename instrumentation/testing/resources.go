@@ -0,0 +1,130 @@
+package testing
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+type (
+	// Resource usage of the process during a test
+	resourceUsage struct {
+		userTime      time.Duration
+		systemTime    time.Duration
+		cpuTimeOk     bool
+		mallocs       uint64
+		totalAlloc    uint64
+		numGC         uint32
+		pauseTotalNs  uint64
+		mu            sync.Mutex
+		maxGoroutines int
+		maxOpenFiles  int
+	}
+
+	// Samples the goroutines and open files while there are running tests
+	resourceSampler struct {
+		mu     sync.Mutex
+		usages map[*resourceUsage]struct{}
+		stop   chan struct{}
+	}
+)
+
+const resourceSamplingInterval = 20 * time.Millisecond
+
+var sampler = &resourceSampler{usages: map[*resourceUsage]struct{}{}}
+
+// Starts the resource usage measurement of a test
+func startResourceUsage() *resourceUsage {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	usage := &resourceUsage{
+		mallocs:       memStats.Mallocs,
+		totalAlloc:    memStats.TotalAlloc,
+		numGC:         memStats.NumGC,
+		pauseTotalNs:  memStats.PauseTotalNs,
+		maxGoroutines: runtime.NumGoroutine(),
+	}
+	usage.userTime, usage.systemTime, usage.cpuTimeOk = getProcessCPUTime()
+	if openFiles, ok := getOpenFiles(); ok {
+		usage.maxOpenFiles = openFiles
+	}
+	sampler.add(usage)
+	return usage
+}
+
+// Ends the resource usage measurement and sets the tags in the span. The metrics are from the whole process,
+// so they are not exact if other tests are running in parallel
+func (r *resourceUsage) end(span opentracing.Span, exact bool) {
+	sampler.remove(r)
+	r.sample()
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	span.SetTag("test.resources.exact", exact)
+	if userTime, systemTime, ok := getProcessCPUTime(); ok && r.cpuTimeOk {
+		span.SetTag("test.resources.cpu.user_time", int64(userTime-r.userTime))
+		span.SetTag("test.resources.cpu.system_time", int64(systemTime-r.systemTime))
+	}
+	span.SetTag("test.resources.memory.allocations", memStats.Mallocs-r.mallocs)
+	span.SetTag("test.resources.memory.bytes_allocations", memStats.TotalAlloc-r.totalAlloc)
+	span.SetTag("test.resources.gc.count", memStats.NumGC-r.numGC)
+	span.SetTag("test.resources.gc.pause_total", memStats.PauseTotalNs-r.pauseTotalNs)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	span.SetTag("test.resources.goroutines.max", r.maxGoroutines)
+	if _, ok := getOpenFiles(); ok {
+		span.SetTag("test.resources.open_files.max", r.maxOpenFiles)
+	}
+}
+
+// Updates the max number of goroutines and open files
+func (r *resourceUsage) sample() {
+	goroutines := runtime.NumGoroutine()
+	openFiles, _ := getOpenFiles()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if goroutines > r.maxGoroutines {
+		r.maxGoroutines = goroutines
+	}
+	if openFiles > r.maxOpenFiles {
+		r.maxOpenFiles = openFiles
+	}
+}
+
+func (s *resourceSampler) add(usage *resourceUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usages[usage] = struct{}{}
+	if s.stop == nil {
+		s.stop = make(chan struct{})
+		go s.run(s.stop)
+	}
+}
+
+func (s *resourceSampler) remove(usage *resourceUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.usages, usage)
+	if len(s.usages) == 0 && s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *resourceSampler) run(stop chan struct{}) {
+	ticker := time.NewTicker(resourceSamplingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			for usage := range s.usages {
+				usage.sample()
+			}
+			s.mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package testing
+
+import (
+	"time"
+)
+
+// The CPU time is not supported on this platform
+func getProcessCPUTime() (time.Duration, time.Duration, bool) {
+	return 0, 0, false
+}
+
+// The open files count is not supported on this platform
+func getOpenFiles() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package testing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+var resourcesSink [][]byte
+
+func TestResourceUsage(t *testing.T) {
+	span := mocktracer.New().StartSpan("test").(*mocktracer.MockSpan)
+	usage := startResourceUsage()
+
+	stop := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() { <-stop }()
+	}
+	for i := 0; i < 100; i++ {
+		resourcesSink = append(resourcesSink, make([]byte, 1024))
+	}
+	<-time.After(3 * resourceSamplingInterval)
+	close(stop)
+
+	usage.end(span, true)
+	resourcesSink = nil
+
+	tags := span.Tags()
+	if allocations, _ := tags["test.resources.memory.allocations"].(uint64); allocations < 100 {
+		t.Fatalf("unexpected allocations: %v", tags["test.resources.memory.allocations"])
+	}
+	if bytes, _ := tags["test.resources.memory.bytes_allocations"].(uint64); bytes < 100*1024 {
+		t.Fatalf("unexpected bytes allocations: %v", tags["test.resources.memory.bytes_allocations"])
+	}
+	if goroutines, _ := tags["test.resources.goroutines.max"].(int); goroutines < 10 {
+		t.Fatalf("unexpected max goroutines: %v", tags["test.resources.goroutines.max"])
+	}
+	if exact, _ := tags["test.resources.exact"].(bool); !exact {
+		t.Fatal("the resource usage must be exact")
+	}
+}
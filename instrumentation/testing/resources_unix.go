@@ -0,0 +1,34 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package testing
+
+import (
+	"io/ioutil"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// Gets the user and system CPU time of the process
+func getProcessCPUTime() (time.Duration, time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, 0, false
+	}
+	return time.Duration(usage.Utime.Nano()), time.Duration(usage.Stime.Nano()), true
+}
+
+// Gets the number of open file descriptors of the process
+func getOpenFiles() (int, bool) {
+	fdFolder := "/dev/fd"
+	if runtime.GOOS == "linux" {
+		fdFolder = "/proc/self/fd"
+	}
+	files, err := ioutil.ReadDir(fdFolder)
+	if err != nil {
+		return 0, false
+	}
+	// The folder itself is opened to read the entries
+	return len(files) - 1, true
+}
@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+package testing
+
+import (
+	"syscall"
+	"time"
+)
+
+// Gets the user and system CPU time of the process
+func getProcessCPUTime() (time.Duration, time.Duration, bool) {
+	handle, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, 0, false
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return 0, 0, false
+	}
+	// Filetime values are in 100-nanosecond intervals
+	toDuration := func(ft syscall.Filetime) time.Duration {
+		return time.Duration((int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime)) * 100)
+	}
+	return toDuration(user), toDuration(kernel), true
+}
+
+// The open handles count is not supported on windows
+func getOpenFiles() (int, bool) {
+	return 0, false
+}
@@ -3,6 +3,8 @@ package testing
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"reflect"
 	"regexp"
 	"runtime"
@@ -28,10 +30,18 @@ import (
 type (
 	Test struct {
 		testing.TB
-		ctx    context.Context
-		span   opentracing.Span
-		t      *testing.T
-		codePC uintptr
+		ctx        context.Context
+		span       opentracing.Span
+		t          *testing.T
+		codePC     uintptr
+		goroutines map[int]string
+		resources  *resourceUsage
+		name       string
+		suite      string
+		framework  string
+
+		leakCheckOnCleanup bool   // The goroutine leaks are checked after the cleanup funcs of the test
+		pendingFinish      func() // Finishes the span after the leak check
 	}
 
 	Option func(*Test)
@@ -42,6 +52,8 @@ var (
 	testMap                    = map[*testing.T]*Test{}
 	autoInstrumentedTestsMutex sync.RWMutex
 	autoInstrumentedTests      = map[*testing.T]bool{}
+	attemptSpansMutex          sync.Mutex
+	attemptSpans               = map[string]opentracing.SpanContext{}
 
 	TESTING_LOG_REGEX = regexp.MustCompile(`(?m)^ {4}(?P<file>[\w\/\.]+):(?P<line>\d+): (?P<message>(.*\n {8}.*)*.*)`)
 )
@@ -53,6 +65,27 @@ func WithContext(ctx context.Context) Option {
 	}
 }
 
+// Sets the test name, by default the name of the `testing.T`
+func WithName(name string) Option {
+	return func(test *Test) {
+		test.name = name
+	}
+}
+
+// Sets the test suite, by default the package of the test func
+func WithSuite(suite string) Option {
+	return func(test *Test) {
+		test.suite = suite
+	}
+}
+
+// Sets the test framework (ex: for the tests of a framework running on top of `testing`)
+func WithFramework(framework string) Option {
+	return func(test *Test) {
+		test.framework = framework
+	}
+}
+
 // Starts a new test
 func StartTest(t *testing.T, opts ...Option) *Test {
 	pc, _, _, _ := runtime.Caller(1)
@@ -74,12 +107,13 @@ func StartTestFromCaller(t *testing.T, pc uintptr, opts ...Option) *Test {
 		// to search the func source code bounds and to calculate the package name.
 		fullTestName := runner.GetOriginalTestName(t.Name())
 		pName, _ := instrumentation.GetPackageAndName(pc)
+		fullTestName, pName, framework := test.getInfo(fullTestName, pName)
 
 		testTags := opentracing.Tags{
 			"span.kind":      "test",
 			"test.name":      fullTestName,
 			"test.suite":     pName,
-			"test.framework": "testing",
+			"test.framework": framework,
 			"test.language":  "go",
 		}
 		span, _ := opentracing.StartSpanFromContextWithTracer(test.ctx, instrumentation.Tracer(), fullTestName, testTags)
@@ -96,8 +130,9 @@ func StartTestFromCaller(t *testing.T, pc uintptr, opts ...Option) *Test {
 		// Useful if we want to overwrite the Start call with options
 		test, exist := getOrCreateTest(t)
 		if exist {
-			// If there is already one we want to replace it, so we clear the context
+			// If there is already one we want to replace it, so we clear the context and the test info
 			test.ctx = context.Background()
+			test.name, test.suite, test.framework = "", "", ""
 		}
 		test.codePC = pc
 
@@ -109,28 +144,55 @@ func StartTestFromCaller(t *testing.T, pc uintptr, opts ...Option) *Test {
 		// to search the func source code bounds and to calculate the package name.
 		fullTestName := runner.GetOriginalTestName(t.Name())
 		pName, _, testCode := instrumentation.GetPackageAndNameAndBoundaries(pc)
+		fullTestName, pName, framework := test.getInfo(fullTestName, pName)
 
 		testTags := opentracing.Tags{
 			"span.kind":      "test",
 			"test.name":      fullTestName,
 			"test.suite":     pName,
-			"test.framework": "testing",
+			"test.framework": framework,
 			"test.language":  "go",
 		}
 
 		if testCode != "" {
 			testTags["test.code"] = testCode
 		}
+		if isTestQuarantined(pName, fullTestName) {
+			testTags[tags.TestQuarantined] = true
+		}
+		if seed, ok := config.GetShuffleSeed(); ok {
+			testTags[tags.ShuffleSeed] = seed
+		}
+		spanOptions := []opentracing.StartSpanOption{testTags}
+		attempt := runner.GetTestAttempt(t)
+		if attempt > 0 {
+			testTags[tags.TestAttempt] = attempt
+			// Retries are linked to the previous attempt
+			if previous, ok := getPreviousAttempt(pName, fullTestName, attempt); ok {
+				spanOptions = append(spanOptions, opentracing.FollowsFrom(previous))
+			}
+		}
 
 		if test.ctx == nil {
 			test.ctx = context.Background()
 		}
 
-		span, ctx := opentracing.StartSpanFromContextWithTracer(test.ctx, instrumentation.Tracer(), fullTestName, testTags)
+		span, ctx := opentracing.StartSpanFromContextWithTracer(test.ctx, instrumentation.Tracer(), fullTestName, spanOptions...)
 		span.SetBaggageItem("trace.kind", "test")
+		if attempt > 0 {
+			setAttempt(pName, fullTestName, span.Context())
+		}
 		test.span = span
 		test.ctx = ctx
 
+		if _, _, enabled := config.GetGoroutineLeakCheck(); enabled {
+			test.goroutines = getGoroutines()
+			test.leakCheckOnCleanup = registerLeakCheckCleanup(test)
+		}
+		if config.IsResourceMetricsEnabled() {
+			test.resources = startResourceUsage()
+		}
+
 		logging.Reset()
 		coverage.StartCoverage()
 
@@ -138,6 +200,21 @@ func StartTestFromCaller(t *testing.T, pc uintptr, opts ...Option) *Test {
 	}
 }
 
+// Gets the test name, suite and framework, the values set by the options take precedence
+func (test *Test) getInfo(name string, suite string) (string, string, string) {
+	framework := "testing"
+	if test.name != "" {
+		name = test.name
+	}
+	if test.suite != "" {
+		suite = test.suite
+	}
+	if test.framework != "" {
+		framework = test.framework
+	}
+	return name, suite, framework
+}
+
 // Set test code
 func (test *Test) SetTestCode(pc uintptr) {
 	test.codePC = pc
@@ -180,6 +257,54 @@ func (test *Test) Run(name string, f func(t *testing.T)) bool {
 	})
 }
 
+// Shuffles the sub tests table (ex: before calling `Run` for each item) when the sub tests shuffle is enabled.
+// The order depends on the shuffle seed and the test name, so it can be reproduced with the same seed
+func (test *Test) Shuffle(n int, swap func(i, j int)) {
+	if !config.IsSubTestsShuffleEnabled() {
+		return
+	}
+	seed, _ := config.GetShuffleSeed()
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(runner.GetOriginalTestName(test.t.Name())))
+	rand.New(rand.NewSource(seed^int64(h.Sum64()))).Shuffle(n, swap)
+}
+
+// Writes the timeout exception event with the goroutines stacks of a running test. The test span is still open
+// (the test is hung), so the event is written in a child span finished right away that can be flushed before
+// the test span ends, the test span is only marked as timed out
+func TimeoutTest(t *testing.T, timeout time.Duration, goroutineStacks []byte) {
+	test := GetTest(t)
+	if test.span == nil {
+		return
+	}
+	message := fmt.Sprintf("test timed out after %v", timeout)
+	timeoutSpan := instrumentation.Tracer().StartSpan("Test timeout", opentracing.ChildOf(test.span.Context()))
+	errors.WriteTimeoutEvent(timeoutSpan, message, goroutineStacks)
+	timeoutSpan.Finish()
+	test.span.SetTag("error", true)
+	test.span.SetTag(tags.TestTimeout, timeout.String())
+}
+
+// Finishes the span of a test still running (ex: a hung test), the test is marked as failed
+func FinishRunningTest(t *testing.T) {
+	testMapMutex.RLock()
+	test, ok := testMap[t]
+	testMapMutex.RUnlock()
+	if !ok {
+		return
+	}
+	autoInstrumentedTestsMutex.Lock()
+	delete(autoInstrumentedTests, t)
+	autoInstrumentedTestsMutex.Unlock()
+	t.Fail()
+	test.end()
+	// The cleanup funcs of a hung test don't run
+	if finish := test.pendingFinish; finish != nil {
+		test.pendingFinish = nil
+		finish()
+	}
+}
+
 // Ends the current test (this method is called from the auto-instrumentation)
 func (test *Test) end() {
 	// We check if we have a span to work with, if not span is found we exit
@@ -189,6 +314,17 @@ func (test *Test) end() {
 
 	finishTime := time.Now()
 
+	// The resource usage is from the whole process, so it includes the usage of other parallel tests
+	if test.resources != nil {
+		test.resources.end(test.span, !reflection.GetIsParallel(test.t) || parallel <= 1)
+	}
+
+	// The goroutines of other parallel tests can't be distinguished from the ones started by this test
+	checkLeaks := test.goroutines != nil && (!reflection.GetIsParallel(test.t) || parallel <= 1)
+	if checkLeaks && !test.leakCheckOnCleanup {
+		test.checkGoroutineLeaks()
+	}
+
 	// If we have our own implementation of the span, we can set the exact start time from the test
 	if ownSpan, ok := test.span.(tracer.Span); ok {
 		if startTime, err := reflection.GetTestStartTime(test.t); err == nil {
@@ -208,10 +344,11 @@ func (test *Test) end() {
 		LogRecords: logRecords,
 	}
 
-	if testing.CoverMode() != "" {
+	if coverage.IsEnabled() {
 		// Checks if the current test is running parallel to extract the coverage or not
 		if reflection.GetIsParallel(test.t) && parallel > 1 {
-			instrumentation.Logger().Printf("CodePath in parallel test is not supported: %v\n", test.t.Name())
+			instrumentation.Logger().Printf("CodePath in parallel test is not supported (enable the parallel tests coverage "+
+				"to run them sequentially): %v\n", test.t.Name())
 			coverage.RestoreCoverageCounters()
 		} else if cov := coverage.EndCoverage(); cov != nil {
 			if sp, ok := test.span.(tracer.Span); ok {
@@ -228,16 +365,23 @@ func (test *Test) end() {
 		test.span.FinishWithOptions(finishOptions)
 		panic(r)
 	}
-	if test.t.Failed() {
-		test.span.SetTag("test.status", tags.TestStatus_FAIL)
-		test.span.SetTag("error", true)
-	} else if test.t.Skipped() {
-		test.span.SetTag("test.status", tags.TestStatus_SKIP)
-	} else {
-		test.span.SetTag("test.status", tags.TestStatus_PASS)
+	finish := func() {
+		if test.t.Failed() {
+			test.span.SetTag("test.status", tags.TestStatus_FAIL)
+			test.span.SetTag("error", true)
+		} else if test.t.Skipped() {
+			test.span.SetTag("test.status", tags.TestStatus_SKIP)
+		} else {
+			test.span.SetTag("test.status", tags.TestStatus_PASS)
+		}
+		test.span.FinishWithOptions(finishOptions)
 	}
-
-	test.span.FinishWithOptions(finishOptions)
+	if checkLeaks && test.leakCheckOnCleanup {
+		// The leak check can fail the test, so the span is finished after it
+		test.pendingFinish = finish
+		return
+	}
+	finish()
 }
 
 func findMatchesLogRegex(output string) [][]string {
@@ -337,3 +481,27 @@ func isTestCached(t *testing.T, pc uintptr) bool {
 	instrumentation.Logger().Printf("Test '%v' is not cached.", fqn)
 	return false
 }
+
+// Get if the test is quarantined (the sub tests use the quarantine of the parent test)
+func isTestQuarantined(pkgName string, testName string) bool {
+	fqn := fmt.Sprintf("%s.%s", pkgName, strings.SplitN(testName, "/", 2)[0])
+	return config.IsTestQuarantined(fqn)
+}
+
+// Gets the span context of the previous attempt of a test
+func getPreviousAttempt(pkgName string, testName string, attempt int) (opentracing.SpanContext, bool) {
+	if attempt <= 1 {
+		return nil, false
+	}
+	attemptSpansMutex.Lock()
+	defer attemptSpansMutex.Unlock()
+	spanCtx, ok := attemptSpans[fmt.Sprintf("%s.%s", pkgName, testName)]
+	return spanCtx, ok
+}
+
+// Sets the span context of the current attempt of a test
+func setAttempt(pkgName string, testName string, spanCtx opentracing.SpanContext) {
+	attemptSpansMutex.Lock()
+	defer attemptSpansMutex.Unlock()
+	attemptSpans[fmt.Sprintf("%s.%s", pkgName, testName)] = spanCtx
+}
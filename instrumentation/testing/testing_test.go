@@ -6,7 +6,11 @@ import (
 	"testing"
 	"time"
 
+	"go.undefinedlabs.com/scopeagent/instrumentation"
+	"go.undefinedlabs.com/scopeagent/instrumentation/testing/config"
 	"go.undefinedlabs.com/scopeagent/reflection"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
 )
 
 func TestLogBufferRegex(t *testing.T) {
@@ -52,10 +56,23 @@ func BenchmarkTestInit(b *testing.B) {
 			testing.InternalBenchmark{Name: "Test04", F: func(b *testing.B) {}},
 			testing.InternalBenchmark{Name: "Test05", F: func(b *testing.B) {}},
 		)
-		Init(testing.MainStart(nil, tests, benchmarks, nil))
+		Init(newTestingM(tests, benchmarks))
 	}
 }
 
+// Creates a `testing.M` with the tests and benchmarks (`testing.MainStart` changes its signature between Go
+// versions and requires the internal test deps)
+func newTestingM(tests []testing.InternalTest, benchmarks []testing.InternalBenchmark) *testing.M {
+	m := &testing.M{}
+	if ptr, err := reflection.GetFieldPointerOf(m, "tests"); err == nil {
+		*(*[]testing.InternalTest)(ptr) = tests
+	}
+	if ptr, err := reflection.GetFieldPointerOf(m, "benchmarks"); err == nil {
+		*(*[]testing.InternalBenchmark)(ptr) = benchmarks
+	}
+	return m
+}
+
 func BenchmarkLoggerPatcher(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		PatchTestingLogger()
@@ -97,3 +114,54 @@ func TestIsParallelByReflection(t *testing.T) {
 		t.Fatal("Test is too slow")
 	}
 }
+
+func TestParallelCoverage(t *testing.T) {
+	if testing.CoverMode() == "" {
+		t.Skip("the coverage is not enabled")
+	}
+	config.SetParallelCoverage(true)
+	patchParallel()
+	defer func() {
+		config.SetParallelCoverage(false)
+		if parallelPatch != nil {
+			logOnError(parallelPatch.Unpatch())
+		}
+	}()
+
+	finished := false
+	t.Run("Parallel", func(t *testing.T) {
+		t.Parallel()
+		if reflection.GetIsParallel(t) {
+			t.Error("the test must run sequentially")
+		}
+		finished = true
+	})
+	if !finished {
+		t.Fatal("the parallel test must finish before returning from Run")
+	}
+}
+
+func TestTimeoutTest(t *testing.T) {
+	recorder := tracer.NewInMemoryRecorder()
+	previousTracer := instrumentation.Tracer()
+	instrumentation.SetTracer(tracer.New(recorder))
+	defer instrumentation.SetTracer(previousTracer)
+
+	test := StartTest(t)
+	TimeoutTest(t, time.Second, []byte("goroutine 1 [running]:\nmain.main()\n\t/src/main.go:10 +0x1f\n"))
+
+	// The timeout span is finished before the test span, so it can be flushed while the test is hung
+	spans := recorder.GetSpans()
+	if len(spans) != 1 || spans[0].Operation != "Test timeout" || spans[0].Tags["error"] != true || len(spans[0].Logs) != 1 {
+		t.Fatalf("the timeout span is invalid: %v", spans)
+	}
+	test.End()
+	if spans = recorder.GetSpans(); len(spans) != 2 || spans[0].ParentSpanID != spans[1].Context.SpanID {
+		t.Fatalf("the timeout span is not a child of the test span: %v", spans)
+	}
+	// The goroutines dump is only written in the timeout span
+	testSpan := spans[1]
+	if testSpan.Tags["error"] != true || testSpan.Tags[tags.TestTimeout] != "1s" || len(testSpan.Logs) != 0 {
+		t.Fatalf("the test span is not marked as timed out: %v", testSpan)
+	}
+}
@@ -150,6 +150,29 @@ func GetBenchmarkResult(b *testing.B) (*testing.BenchmarkResult, error) {
 	}
 }
 
+// Gets the duration, allocations and allocated bytes of the last run of a benchmark from the private fields of
+// testing.B (the timer must be stopped)
+func GetBenchmarkRunResult(b *testing.B) (time.Duration, uint64, uint64, error) {
+	mu := GetBenchmarkMutex(b)
+	if mu != nil {
+		mu.Lock()
+		defer mu.Unlock()
+	}
+	durationPtr, err := GetFieldPointerOf(b, "duration")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	allocsPtr, err := GetFieldPointerOf(b, "netAllocs")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	bytesPtr, err := GetFieldPointerOf(b, "netBytes")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return *(*time.Duration)(durationPtr), *(*uint64)(allocsPtr), *(*uint64)(bytesPtr), nil
+}
+
 // Mark the current test as skipped and finished without exit the current goroutine
 func SkipAndFinishTest(t *testing.T) {
 	mu := GetTestMutex(t)
@@ -0,0 +1,280 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/reporters"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Span recorder that prints the progress of the test run and a summary of the results to the console
+	Recorder struct {
+		w        io.Writer
+		live     bool
+		slowest  int
+		stopOnce sync.Once
+
+		mu       sync.Mutex
+		results  []*reporters.TestResult
+		suites   []string
+		progress map[string]*suiteProgress
+		lineLen  int
+	}
+
+	suiteProgress struct {
+		tests   int
+		passed  int
+		failed  int
+		skipped int
+		cached  int
+		elapsed time.Duration
+	}
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
+
+	defaultSlowestTests = 5
+	maxMessageLength    = 120
+)
+
+// Creates a new console recorder writing to the given writer, the progress line is updated in place
+// if the writer is a terminal
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w:        w,
+		live:     isTerminal(w),
+		slowest:  defaultSlowestTests,
+		progress: map[string]*suiteProgress{},
+	}
+}
+
+// Sets the number of slowest tests printed in the summary
+func (r *Recorder) SetSlowestTests(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slowest = count
+}
+
+// Records a span, the progress of the suite is printed for each test span
+func (r *Recorder) RecordSpan(span tracer.RawSpan) {
+	if !reporters.IsTestSpan(span) {
+		return
+	}
+	result := reporters.NewTestResult(span)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+	progress, ok := r.progress[result.Suite]
+	if !ok {
+		progress = &suiteProgress{}
+		r.progress[result.Suite] = progress
+		r.suites = append(r.suites, result.Suite)
+	}
+	progress.add(result.Status, result.Duration)
+
+	if r.live {
+		r.clearLine()
+		if result.Status == tags.TestStatus_FAIL {
+			fmt.Fprintf(r.w, "%s %s (%s)\n", r.color(colorRed, "FAIL"), result.Name, formatDuration(result.Duration))
+		}
+		line := fmt.Sprintf("%s: %s", result.Suite, progress.String())
+		r.lineLen = len(line)
+		fmt.Fprint(r.w, line)
+	} else if result.Status == tags.TestStatus_FAIL {
+		fmt.Fprintf(r.w, "FAIL %s.%s (%s) [%s]\n", result.Suite, result.Name, formatDuration(result.Duration), progress.String())
+	}
+}
+
+// Prints the summary of the test results, only the first call prints the summary
+func (r *Recorder) Stop() error {
+	var err error
+	r.stopOnce.Do(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.clearLine()
+		err = r.writeSummary()
+	})
+	return err
+}
+
+// Writes the summary: results by suite, the failed, flaky, skipped and cached tests and the slowest tests
+func (r *Recorder) writeSummary() error {
+	if len(r.results) == 0 {
+		return nil
+	}
+	runs := reporters.GroupTestRuns(r.results)
+	tw := tabwriter.NewWriter(r.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\n** Test Summary **")
+	fmt.Fprintln(tw, "SUITE\tTESTS\tPASSED\tFAILED\tSKIPPED\tCACHED\tDURATION")
+	// The attempts of a test (runner retries) are counted as one test with the status of the last attempt
+	suites := map[string]*suiteProgress{}
+	for _, run := range runs {
+		if _, ok := suites[run.Suite]; !ok {
+			suites[run.Suite] = &suiteProgress{}
+		}
+		suites[run.Suite].add(run.Status(), run.Duration())
+	}
+	for _, suite := range r.suites {
+		p := suites[suite]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", suite, p.tests, p.passed, p.failed, p.skipped, p.cached, formatDuration(p.elapsed))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var failed, flaky, skipped, cached []*reporters.TestRun
+	for _, run := range runs {
+		switch {
+		case run.Status() == tags.TestStatus_FAIL:
+			failed = append(failed, run)
+		case run.IsFlaky():
+			flaky = append(flaky, run)
+		case run.Status() == tags.TestStatus_SKIP:
+			skipped = append(skipped, run)
+		case run.Status() == tags.TestStatus_CACHE:
+			cached = append(cached, run)
+		}
+	}
+	r.writeRuns(r.color(colorRed, "Failed tests"), failed)
+	r.writeRuns(r.color(colorYellow, "Flaky tests"), flaky)
+	r.writeRuns(r.color(colorGray, "Skipped tests"), skipped)
+	r.writeRuns(r.color(colorGray, "Cached tests"), cached)
+
+	if r.slowest > 0 {
+		sorted := append([]*reporters.TestRun(nil), runs...)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Duration() > sorted[j].Duration() })
+		if len(sorted) > r.slowest {
+			sorted = sorted[:r.slowest]
+		}
+		fmt.Fprintf(r.w, "\nSlowest tests:\n")
+		for _, run := range sorted {
+			fmt.Fprintf(r.w, "  %s  %s.%s\n", r.color(colorYellow, fmt.Sprintf("%10s", formatDuration(run.Duration()))), run.Suite, run.Name)
+		}
+	}
+
+	status := r.color(colorGreen, "PASS")
+	if len(failed) > 0 {
+		status = r.color(colorRed, "FAIL")
+	}
+	_, err := fmt.Fprintf(r.w, "\n%s: %d tests, %d failed, %d flaky, %d skipped, %d cached\n",
+		status, len(runs), len(failed), len(flaky), len(skipped), len(cached))
+	return err
+}
+
+// Writes the list of test runs with their first failure (or skip) message and source location
+func (r *Recorder) writeRuns(title string, runs []*reporters.TestRun) {
+	if len(runs) == 0 {
+		return
+	}
+	fmt.Fprintf(r.w, "\n%s (%d):\n", title, len(runs))
+	for _, run := range runs {
+		final := run.Final()
+		line := fmt.Sprintf("  %s.%s", run.Suite, run.Name)
+		if len(run.Attempts) > 1 {
+			line += fmt.Sprintf(" (%d attempts)", len(run.Attempts))
+		}
+		fmt.Fprintln(r.w, line)
+		if message := getMessage(run); message != "" {
+			fmt.Fprintf(r.w, "      %s\n", message)
+		}
+		if file, startLine, _, ok := final.CodeLocation(); ok {
+			fmt.Fprintf(r.w, "      %s\n", r.color(colorGray, fmt.Sprintf("%s:%d", file, startLine)))
+		}
+	}
+}
+
+// Gets the first failure message of the test (of the first failed attempt for flaky tests) or the skip message
+func getMessage(run *reporters.TestRun) string {
+	for _, attempt := range run.Attempts {
+		if attempt.Status != tags.TestStatus_FAIL {
+			continue
+		}
+		if event := attempt.FirstFailure(); event != nil {
+			return formatMessage(event)
+		}
+		return ""
+	}
+	if event := run.Final().SkipEvent(); event != nil {
+		return formatMessage(event)
+	}
+	return ""
+}
+
+func formatMessage(event *reporters.TestEvent) string {
+	message := strings.TrimSpace(event.Message)
+	if idx := strings.IndexByte(message, '\n'); idx >= 0 {
+		message = message[:idx] + " ..."
+	}
+	if len(message) > maxMessageLength {
+		message = message[:maxMessageLength] + "..."
+	}
+	if event.Source != "" {
+		message += " (" + event.Source + ")"
+	}
+	return message
+}
+
+func (r *Recorder) clearLine() {
+	if r.live && r.lineLen > 0 {
+		fmt.Fprint(r.w, "\r\033[K")
+		r.lineLen = 0
+	}
+}
+
+func (r *Recorder) color(color string, value string) string {
+	if !r.live {
+		return value
+	}
+	return color + value + colorReset
+}
+
+// Adds a test to the suite counters
+func (p *suiteProgress) add(status string, duration time.Duration) {
+	p.tests++
+	p.elapsed += duration
+	switch status {
+	case tags.TestStatus_FAIL:
+		p.failed++
+	case tags.TestStatus_SKIP:
+		p.skipped++
+	case tags.TestStatus_CACHE:
+		p.cached++
+	default:
+		p.passed++
+	}
+}
+
+func (p *suiteProgress) String() string {
+	return fmt.Sprintf("%d tests, %d passed, %d failed, %d skipped, %d cached", p.tests, p.passed, p.failed, p.skipped, p.cached)
+}
+
+// Gets if the writer is a terminal (character device)
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration < time.Millisecond {
+		return duration.Round(time.Microsecond).String()
+	}
+	return duration.Round(time.Millisecond).String()
+}
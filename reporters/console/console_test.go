@@ -0,0 +1,77 @@
+package console
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/reporters"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func TestConsoleReport(t *testing.T) {
+	const suite = "example.com/calc"
+	failure := []log.Field{
+		log.String(tags.EventType, tags.EventTestFailure),
+		log.String(tags.EventMessage, "expected 1\ngot 2"),
+		log.String(tags.EventSource, "/src/calc_test.go:12"),
+	}
+	skip := []log.Field{
+		log.String(tags.EventType, tags.EventTestSkip),
+		log.String(tags.EventMessage, "not supported"),
+	}
+
+	var buffer bytes.Buffer
+	recorder := NewRecorder(&buffer)
+	recorder.SetSlowestTests(2)
+	recorder.RecordSpan(tracer.RawSpan{Operation: "HTTP GET", Tags: opentracing.Tags{"span.kind": "client"}})
+	code := reporters.WithTestCode("/src/calc_test.go:10:20")
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestPass", tags.TestStatus_PASS, code, reporters.WithTestDuration(3*time.Second)))
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestFail", tags.TestStatus_FAIL, code, reporters.WithTestDuration(time.Second),
+		reporters.WithTestEvents(failure)))
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestFlaky", tags.TestStatus_FAIL, code, reporters.WithTestDuration(10*time.Millisecond),
+		reporters.WithTestEvents(failure), reporters.WithTestAttempt(1)))
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestFlaky", tags.TestStatus_PASS, code, reporters.WithTestDuration(10*time.Millisecond),
+		reporters.WithTestAttempt(2)))
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestSkip", tags.TestStatus_SKIP, code, reporters.WithTestDuration(0),
+		reporters.WithTestEvents(skip)))
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestCache", tags.TestStatus_CACHE, code, reporters.WithTestDuration(0)))
+
+	progress := buffer.String()
+	if !strings.HasPrefix(progress, "FAIL example.com/calc.TestFail (1s) [2 tests, 1 passed, 1 failed, 0 skipped, 0 cached]\n") {
+		t.Fatalf("unexpected progress output:\n%s", progress)
+	}
+	buffer.Reset()
+
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	summary := buffer.String()
+	for _, value := range []string{
+		"example.com/calc  5      2       1       1        1       4.02s",
+		"Failed tests (1):\n  example.com/calc.TestFail\n      expected 1 ... (/src/calc_test.go:12)\n      /src/calc_test.go:10\n",
+		"Flaky tests (1):\n  example.com/calc.TestFlaky (2 attempts)\n      expected 1 ...",
+		"Skipped tests (1):\n  example.com/calc.TestSkip\n      not supported\n",
+		"Cached tests (1):\n  example.com/calc.TestCache\n",
+		"Slowest tests:\n          3s  example.com/calc.TestPass\n          1s  example.com/calc.TestFail\n\n",
+		"FAIL: 5 tests, 1 failed, 1 flaky, 1 skipped, 1 cached\n",
+	} {
+		if !strings.Contains(summary, value) {
+			t.Fatalf("the summary doesn't contain '%s':\n%s", value, summary)
+		}
+	}
+	if strings.Contains(summary, "\033[") {
+		t.Fatal("the output must not be colored if it's not a terminal")
+	}
+
+	// The summary is printed only once
+	buffer.Reset()
+	if err := recorder.Stop(); err != nil || buffer.Len() != 0 {
+		t.Fatalf("the summary has been printed again: %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+package html
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go.undefinedlabs.com/scopeagent/reporters"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Span recorder that writes a self-contained HTML report of the test spans and their child spans
+	Recorder struct {
+		path string
+
+		mu      sync.Mutex
+		results []*reporters.TestResult
+		spans   map[uuid.UUID][]tracer.RawSpan // Child spans by trace id
+	}
+
+	report struct {
+		Generated string
+		Tests     int
+		Passed    int
+		Failed    int
+		Skipped   int
+		Flaky     int
+		Duration  string
+		Suites    []*suiteView
+	}
+
+	suiteView struct {
+		Name     string
+		Status   string
+		Tests    int
+		Failed   int
+		Duration string
+		Runs     []*testView
+	}
+
+	testView struct {
+		ID       string
+		Name     string
+		Status   string
+		Duration string
+		Flaky    bool
+		Retried  bool
+		Code     string
+		Snippet  *snippet
+		Attempts []*attemptView
+	}
+
+	attemptView struct {
+		Number   int
+		Status   string
+		Duration string
+		Events   []eventView
+		Spans    []spanView
+	}
+
+	eventView struct {
+		Offset  string
+		Kind    string
+		Level   string
+		Message string
+		Source  string
+		Stack   string
+	}
+
+	spanView struct {
+		Operation string
+		Detail    string
+		Duration  string
+		Offset    string
+		Width     string
+		Depth     int
+		Error     bool
+	}
+
+	snippet struct {
+		File  string
+		Lines []snippetLine
+	}
+
+	snippetLine struct {
+		Number    int
+		Text      string
+		Highlight bool
+	}
+)
+
+const maxSnippetLines = 200
+
+var (
+	sourceLineRegex = regexp.MustCompile(`([^\s:()]+\.go):(\d+)`)
+	reportTemplate  = template.Must(template.New("report").Funcs(template.FuncMap{
+		"lower": strings.ToLower,
+		"indent": func(depth int) string {
+			return fmt.Sprintf("%dpx", depth*12)
+		},
+	}).Parse(reportHTML))
+)
+
+// Creates a new HTML recorder writing the report to the given path
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path, spans: map[uuid.UUID][]tracer.RawSpan{}}
+}
+
+// Gets the report path
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Records a span, the non test spans are included in the waterfall of the test with the same trace id
+func (r *Recorder) RecordSpan(span tracer.RawSpan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reporters.IsTestSpan(span) {
+		r.results = append(r.results, reporters.NewTestResult(span))
+		return
+	}
+	r.spans[span.Context.TraceID] = append(r.spans[span.Context.TraceID], span)
+}
+
+// Writes the report file, can be called more than once (the file is overwritten)
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmpPath := r.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err = writeReport(file, r.results, r.spans); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, r.path)
+}
+
+// Writes the HTML report of the test results
+func writeReport(w io.Writer, results []*reporters.TestResult, spans map[uuid.UUID][]tracer.RawSpan) error {
+	rep := report{Generated: time.Now().Format(time.RFC1123)}
+	suiteMap := map[string]*suiteView{}
+	suiteDuration := map[string]time.Duration{}
+	sources := map[string][]string{}
+	var totalDuration time.Duration
+
+	for idx, run := range reporters.GroupTestRuns(results) {
+		suite, ok := suiteMap[run.Suite]
+		if !ok {
+			suite = &suiteView{Name: run.Suite, Status: tags.TestStatus_PASS}
+			suiteMap[run.Suite] = suite
+			rep.Suites = append(rep.Suites, suite)
+		}
+		test := newTestView(idx, run, spans, sources)
+		suite.Runs = append(suite.Runs, test)
+		suite.Tests++
+		rep.Tests++
+		switch test.Status {
+		case tags.TestStatus_FAIL:
+			suite.Failed++
+			suite.Status = tags.TestStatus_FAIL
+			rep.Failed++
+		case tags.TestStatus_SKIP, tags.TestStatus_CACHE:
+			rep.Skipped++
+		default:
+			rep.Passed++
+		}
+		if test.Flaky {
+			rep.Flaky++
+		}
+		suiteDuration[run.Suite] += run.Duration()
+		totalDuration += run.Duration()
+	}
+	for _, suite := range rep.Suites {
+		suite.Duration = formatDuration(suiteDuration[suite.Name])
+	}
+	rep.Duration = formatDuration(totalDuration)
+	return reportTemplate.Execute(w, rep)
+}
+
+// Creates the view of all the executions of a test
+func newTestView(idx int, run *reporters.TestRun, spans map[uuid.UUID][]tracer.RawSpan, sources map[string][]string) *testView {
+	final := run.Final()
+	test := &testView{
+		ID:       fmt.Sprintf("test-%d", idx),
+		Name:     run.Name,
+		Status:   run.Status(),
+		Duration: formatDuration(run.Duration()),
+		Flaky:    run.IsFlaky(),
+		Retried:  len(run.Attempts) > 1,
+		Code:     final.Code,
+	}
+	highlights := map[string]map[int]bool{}
+	for number, attempt := range run.Attempts {
+		view := &attemptView{
+			Number:   number + 1,
+			Status:   attempt.Status,
+			Duration: formatDuration(attempt.Duration),
+			Spans:    newSpanViews(attempt, spans[attempt.Context.TraceID]),
+		}
+		for _, event := range attempt.Events {
+			view.Events = append(view.Events, newEventView(attempt, event))
+			for _, match := range sourceLineRegex.FindAllStringSubmatch(event.Source+"\n"+event.Message+"\n"+event.Stack, -1) {
+				line, _ := strconv.Atoi(match[2])
+				file := filepath.Clean(match[1])
+				if highlights[file] == nil {
+					highlights[file] = map[int]bool{}
+				}
+				highlights[file][line] = true
+			}
+		}
+		test.Attempts = append(test.Attempts, view)
+	}
+	if file, startLine, endLine, ok := final.CodeLocation(); ok {
+		test.Snippet = newSnippet(file, startLine, endLine, highlights[filepath.Clean(file)], sources)
+	}
+	return test
+}
+
+func newEventView(attempt *reporters.TestResult, event reporters.TestEvent) eventView {
+	view := eventView{
+		Offset:  formatDuration(event.Timestamp.Sub(attempt.Start)),
+		Kind:    event.Type,
+		Level:   event.Level,
+		Message: event.Message,
+		Source:  event.Source,
+		Stack:   event.Stack,
+	}
+	switch {
+	case event.IsException():
+		view.Kind = "exception"
+	case event.Type == tags.EventTestFailure:
+		view.Kind = "failure"
+	case event.Type == tags.EventTestSkip:
+		view.Kind = "skip"
+	}
+	return view
+}
+
+// Creates the waterfall of the child spans of a test, sorted by start and nested by parent
+func newSpanViews(attempt *reporters.TestResult, traceSpans []tracer.RawSpan) []spanView {
+	children := map[uint64][]tracer.RawSpan{}
+	for _, span := range traceSpans {
+		children[span.ParentSpanID] = append(children[span.ParentSpanID], span)
+	}
+	total := attempt.Duration
+	if total <= 0 {
+		total = time.Nanosecond
+	}
+	var views []spanView
+	var walk func(parent uint64, depth int)
+	walk = func(parent uint64, depth int) {
+		spans := children[parent]
+		delete(children, parent) // Prevents cycles
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+		for _, span := range spans {
+			offset := clampRatio(float64(span.Start.Sub(attempt.Start)) / float64(total))
+			width := clampRatio(float64(span.Duration) / float64(total))
+			if offset+width > 1 {
+				width = 1 - offset
+			}
+			isError, _ := span.Tags["error"].(bool)
+			views = append(views, spanView{
+				Operation: span.Operation,
+				Detail:    getSpanDetail(span),
+				Duration:  formatDuration(span.Duration),
+				Offset:    fmt.Sprintf("%.2f%%", offset*100),
+				Width:     fmt.Sprintf("%.2f%%", width*100),
+				Depth:     depth,
+				Error:     isError,
+			})
+			walk(span.Context.SpanID, depth+1)
+		}
+	}
+	walk(attempt.Context.SpanID, 0)
+	return views
+}
+
+// Gets a short description of a child span (HTTP request, SQL statement or gRPC method)
+func getSpanDetail(span tracer.RawSpan) string {
+	value := func(key string) string {
+		if v, ok := span.Tags[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	switch {
+	case value("http.method") != "":
+		detail := value("http.method") + " " + value("http.url")
+		if status := value("http.status_code"); status != "" {
+			detail += " [" + status + "]"
+		}
+		return detail
+	case value("db.prepare_statement") != "":
+		return value("db.prepare_statement")
+	case value("grpc.method_name") != "":
+		detail := value("grpc.method_name")
+		if status := value("grpc.status"); status != "" {
+			detail += " [" + status + "]"
+		}
+		return detail
+	}
+	return value("component")
+}
+
+// Creates the source snippet of the test func, the lines referenced by the events are highlighted
+func newSnippet(file string, startLine int, endLine int, highlights map[int]bool, sources map[string][]string) *snippet {
+	lines, ok := sources[file]
+	if !ok {
+		lines = readLines(file)
+		sources[file] = lines
+	}
+	if len(lines) == 0 || startLine < 1 || startLine > len(lines) {
+		return nil
+	}
+	if endLine > len(lines) {
+		endLine = len(lines)
+	}
+	if endLine-startLine >= maxSnippetLines {
+		endLine = startLine + maxSnippetLines - 1
+	}
+	snip := &snippet{File: file}
+	for number := startLine; number <= endLine; number++ {
+		snip.Lines = append(snip.Lines, snippetLine{
+			Number:    number,
+			Text:      lines[number-1],
+			Highlight: highlights[number],
+		})
+	}
+	return snip
+}
+
+func readLines(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines
+}
+
+func clampRatio(value float64) float64 {
+	if value < 0 {
+		return 0
+	}
+	if value > 1 {
+		return 1
+	}
+	return value
+}
+
+func formatDuration(duration time.Duration) string {
+	switch {
+	case duration < time.Millisecond:
+		return duration.Round(time.Microsecond).String()
+	case duration < time.Second:
+		return duration.Round(10 * time.Microsecond).String()
+	}
+	return duration.Round(time.Millisecond).String()
+}
@@ -0,0 +1,106 @@
+package html
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/reporters"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+const testSource = `package calc
+
+func TestCalc(t *testing.T) {
+	if sum(1, 1) != 3 {
+		t.Fatal("expected <3>")
+	}
+}
+`
+
+func TestHTMLReport(t *testing.T) {
+	const suite = "example.com/calc"
+	folder, err := ioutil.TempDir("", "scope-html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	sourcePath := filepath.Join(folder, "calc_test.go")
+	if err := ioutil.WriteFile(sourcePath, []byte(testSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(folder, "report", "index.html")
+	code := fmt.Sprintf("%s:3:7", sourcePath)
+
+	failure := []log.Field{
+		log.String(tags.EventType, tags.EventTestFailure),
+		log.String(tags.EventMessage, "expected <3>"),
+		log.String(tags.EventSource, sourcePath+":5"),
+	}
+	info := []log.Field{
+		log.String(tags.EventType, tags.LogEvent),
+		log.String(tags.LogEventLevel, tags.LogLevel_INFO),
+		log.String(tags.EventMessage, "computing the sum"),
+	}
+
+	recorder := NewRecorder(path)
+	failed := reporters.NewTestSpan(suite, "TestCalc", tags.TestStatus_FAIL, reporters.WithTestCode(code),
+		reporters.WithTestEvents(info, failure))
+	recorder.RecordSpan(failed)
+	recorder.RecordSpan(tracer.RawSpan{
+		Context:      tracer.SpanContext{TraceID: failed.Context.TraceID, SpanID: 2},
+		ParentSpanID: 1,
+		Operation:    "HTTP GET",
+		Start:        failed.Start.Add(25 * time.Millisecond),
+		Duration:     50 * time.Millisecond,
+		Tags:         opentracing.Tags{"span.kind": "client", "http.method": "GET", "http.url": "http://localhost/sum"},
+	})
+	recorder.RecordSpan(tracer.RawSpan{
+		Context:      tracer.SpanContext{TraceID: failed.Context.TraceID, SpanID: 3},
+		ParentSpanID: 2,
+		Operation:    "ExecContext",
+		Start:        failed.Start.Add(30 * time.Millisecond),
+		Duration:     10 * time.Millisecond,
+		Tags:         opentracing.Tags{"db.prepare_statement": "SELECT 1"},
+	})
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestFlaky", tags.TestStatus_FAIL, reporters.WithTestCode(code),
+		reporters.WithTestEvents(failure), reporters.WithTestAttempt(1)))
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestFlaky", tags.TestStatus_PASS, reporters.WithTestCode(code),
+		reporters.WithTestAttempt(2)))
+	recorder.RecordSpan(reporters.NewTestSpan(suite, "TestSkip", tags.TestStatus_SKIP))
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+	for _, value := range []string{
+		"<span>3 tests</span>",
+		`<span class="badge flaky">FLAKY</span>TestFlaky`,
+		"Attempt 2",
+		"computing the sum",
+		"expected &lt;3&gt;",
+		`<tr class="highlight"><td class="number">5</td>`,
+		"GET http://localhost/sum",
+		"left: 25.00%; width: 50.00%",
+		`<td style="padding-left: 12px">ExecContext <small>SELECT 1</small></td>`,
+	} {
+		if !strings.Contains(report, value) {
+			t.Fatalf("the report doesn't contain '%s':\n%s", value, report)
+		}
+	}
+	if strings.Contains(report, "<3>") {
+		t.Fatal("the report contents must be escaped")
+	}
+}
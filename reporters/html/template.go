@@ -0,0 +1,122 @@
+package html
+
+// Template of the HTML report, the styles and scripts are inlined so the file can be opened without a server
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Scope Test Report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #1f2328; background: #f6f8fa; }
+header { background: #24292f; color: #fff; padding: 16px 24px; }
+header h1 { margin: 0 0 4px; font-size: 20px; }
+header small { color: #b6bcc3; }
+main { padding: 16px 24px; }
+.totals span { display: inline-block; margin-right: 16px; font-weight: 600; }
+.filters { margin: 12px 0; }
+.filters label { margin-right: 12px; }
+details { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; margin: 6px 0; }
+details > summary { cursor: pointer; padding: 6px 10px; list-style-position: inside; }
+details details { margin: 4px 10px; }
+.body { padding: 4px 12px 10px; }
+.badge { display: inline-block; min-width: 44px; padding: 1px 6px; border-radius: 10px; font-size: 11px; font-weight: 700; text-align: center; color: #fff; margin-right: 6px; }
+.pass { background: #1a7f37; }
+.fail { background: #cf222e; }
+.skip, .cache { background: #8c959f; }
+.flaky { background: #bf8700; }
+.retried { background: #6639ba; }
+.duration { float: right; color: #57606a; font-size: 12px; }
+pre { background: #f6f8fa; border: 1px solid #d0d7de; border-radius: 4px; padding: 6px; overflow-x: auto; font-size: 12px; margin: 4px 0; }
+table { border-collapse: collapse; width: 100%; font-size: 12px; }
+td { padding: 2px 6px; vertical-align: top; }
+.events td:first-child, .waterfall td:last-child { white-space: nowrap; color: #57606a; }
+.exception td, .failure td, .level-error td { color: #cf222e; }
+.level-warning td { color: #9a6700; }
+.code { font-family: SFMono-Regular, Consolas, monospace; white-space: pre; }
+.code .number { color: #8c959f; text-align: right; user-select: none; width: 1%; }
+.code tr.highlight { background: #ffebe9; }
+.waterfall .bar-cell { width: 40%; }
+.track { position: relative; height: 10px; background: #eaeef2; border-radius: 2px; }
+.bar { position: absolute; top: 0; height: 10px; min-width: 2px; background: #0969da; border-radius: 2px; }
+.bar.error { background: #cf222e; }
+h4 { margin: 10px 0 4px; font-size: 13px; }
+</style>
+</head>
+<body>
+<header>
+<h1>Scope Test Report</h1>
+<small>Generated on {{.Generated}}</small>
+</header>
+<main>
+<div class="totals">
+<span>{{.Tests}} tests</span>
+<span><span class="badge pass">PASS</span>{{.Passed}}</span>
+<span><span class="badge fail">FAIL</span>{{.Failed}}</span>
+<span><span class="badge skip">SKIP</span>{{.Skipped}}</span>
+<span><span class="badge flaky">FLAKY</span>{{.Flaky}}</span>
+<span>{{.Duration}}</span>
+</div>
+<div class="filters">
+<label><input type="checkbox" data-status="pass" checked> Passed</label>
+<label><input type="checkbox" data-status="fail" checked> Failed</label>
+<label><input type="checkbox" data-status="skip" checked> Skipped</label>
+<label><input type="checkbox" data-status="cache" checked> Cached</label>
+</div>
+{{range .Suites}}
+<details class="suite"{{if eq .Status "FAIL"}} open{{end}}>
+<summary><span class="badge {{lower .Status}}">{{.Status}}</span><strong>{{.Name}}</strong> ({{.Tests}} tests{{if .Failed}}, {{.Failed}} failed{{end}})<span class="duration">{{.Duration}}</span></summary>
+{{range .Runs}}
+<details class="test" id="{{.ID}}" data-status="{{lower .Status}}"{{if eq .Status "FAIL"}} open{{end}}>
+<summary><span class="badge {{lower .Status}}">{{.Status}}</span>{{if .Flaky}}<span class="badge flaky">FLAKY</span>{{else if .Retried}}<span class="badge retried">RETRIED</span>{{end}}{{.Name}}<span class="duration">{{.Duration}}</span></summary>
+<div class="body">
+{{range .Attempts}}
+<h4>Attempt {{.Number}} <span class="badge {{lower .Status}}">{{.Status}}</span> <small>{{.Duration}}</small></h4>
+{{if .Events}}
+<table class="events">
+{{range .Events}}
+<tr class="{{.Kind}}{{if .Level}} level-{{lower .Level}}{{end}}">
+<td>+{{.Offset}}</td>
+<td>{{if .Level}}[{{.Level}}]{{else}}[{{.Kind}}]{{end}}</td>
+<td>{{.Message}}{{if .Source}} <small>({{.Source}})</small>{{end}}{{if .Stack}}<pre>{{.Stack}}</pre>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{if .Spans}}
+<table class="waterfall">
+{{range .Spans}}
+<tr>
+<td style="padding-left: {{indent .Depth}}">{{.Operation}}{{if .Detail}} <small>{{.Detail}}</small>{{end}}</td>
+<td class="bar-cell"><div class="track"><div class="bar{{if .Error}} error{{end}}" style="left: {{.Offset}}; width: {{.Width}}"></div></div></td>
+<td>{{.Duration}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+{{with .Snippet}}
+<h4>{{.File}}</h4>
+<table class="code">
+{{range .Lines}}<tr{{if .Highlight}} class="highlight"{{end}}><td class="number">{{.Number}}</td><td>{{.Text}}</td></tr>
+{{end}}
+</table>
+{{else}}{{if .Code}}<h4>{{.Code}}</h4>{{end}}
+{{end}}
+</div>
+</details>
+{{end}}
+</details>
+{{end}}
+</main>
+<script>
+document.querySelectorAll(".filters input").forEach(function (input) {
+  input.addEventListener("change", function () {
+    document.querySelectorAll("details.test[data-status='" + input.dataset.status + "']").forEach(function (test) {
+      test.style.display = input.checked ? "" : "none";
+    });
+  });
+});
+</script>
+</body>
+</html>
+`
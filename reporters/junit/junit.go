@@ -0,0 +1,328 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/reporters"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Span recorder that writes the test spans as a JUnit XML report
+	Recorder struct {
+		path string
+
+		mu      sync.Mutex
+		results []*reporters.TestResult
+	}
+
+	testSuites struct {
+		XMLName  xml.Name    `xml:"testsuites"`
+		Name     string      `xml:"name,attr,omitempty"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Errors   int         `xml:"errors,attr"`
+		Skipped  int         `xml:"skipped,attr"`
+		Time     string      `xml:"time,attr"`
+		Suites   []testSuite `xml:"testsuite"`
+	}
+
+	testSuite struct {
+		Name      string     `xml:"name,attr"`
+		Tests     int        `xml:"tests,attr"`
+		Failures  int        `xml:"failures,attr"`
+		Errors    int        `xml:"errors,attr"`
+		Skipped   int        `xml:"skipped,attr"`
+		Time      string     `xml:"time,attr"`
+		Timestamp string     `xml:"timestamp,attr,omitempty"`
+		Cases     []testCase `xml:"testcase"`
+	}
+
+	testCase struct {
+		Name          string          `xml:"name,attr"`
+		ClassName     string          `xml:"classname,attr"`
+		Time          string          `xml:"time,attr"`
+		File          string          `xml:"file,attr,omitempty"`
+		Line          int             `xml:"line,attr,omitempty"`
+		Properties    *properties     `xml:"properties,omitempty"`
+		Skipped       *result         `xml:"skipped,omitempty"`
+		Failure       *result         `xml:"failure,omitempty"`
+		Error         *result         `xml:"error,omitempty"`
+		FlakyFailures []rerunResult   `xml:"flakyFailure,omitempty"`
+		FlakyErrors   []rerunResult   `xml:"flakyError,omitempty"`
+		RerunFailures []rerunResult   `xml:"rerunFailure,omitempty"`
+		RerunErrors   []rerunResult   `xml:"rerunError,omitempty"`
+		SystemOut     *outputContents `xml:"system-out,omitempty"`
+		SystemErr     *outputContents `xml:"system-err,omitempty"`
+	}
+
+	properties struct {
+		Properties []property `xml:"property"`
+	}
+
+	property struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+
+	result struct {
+		Message  string `xml:"message,attr,omitempty"`
+		Type     string `xml:"type,attr,omitempty"`
+		Contents string `xml:",chardata"`
+	}
+
+	rerunResult struct {
+		Message    string          `xml:"message,attr,omitempty"`
+		Type       string          `xml:"type,attr,omitempty"`
+		StackTrace string          `xml:"stackTrace,omitempty"`
+		SystemOut  *outputContents `xml:"system-out,omitempty"`
+		SystemErr  *outputContents `xml:"system-err,omitempty"`
+	}
+
+	outputContents struct {
+		Contents string `xml:",chardata"`
+	}
+)
+
+// Creates a new JUnit recorder writing the report to the given path
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path}
+}
+
+// Gets the report path
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Records a span, only test spans are included in the report
+func (r *Recorder) RecordSpan(span tracer.RawSpan) {
+	if !reporters.IsTestSpan(span) {
+		return
+	}
+	testResult := reporters.NewTestResult(span)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, testResult)
+}
+
+// Writes the report file, can be called more than once (the file is overwritten)
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmpPath := r.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err = writeReport(file, r.results); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, r.path)
+}
+
+// Writes the JUnit XML report of the test results
+func writeReport(w io.Writer, results []*reporters.TestResult) error {
+	report := testSuites{}
+	var suites []*testSuite
+	suiteMap := map[string]*testSuite{}
+	var totalDuration time.Duration
+	suiteDuration := map[string]time.Duration{}
+
+	for _, run := range reporters.GroupTestRuns(results) {
+		suite, ok := suiteMap[run.Suite]
+		if !ok {
+			suite = &testSuite{
+				Name:      run.Suite,
+				Timestamp: run.Attempts[0].Start.UTC().Format("2006-01-02T15:04:05"),
+			}
+			suiteMap[run.Suite] = suite
+			suites = append(suites, suite)
+		}
+		tCase := newTestCase(run)
+		suite.Tests++
+		switch {
+		case tCase.Error != nil:
+			suite.Errors++
+		case tCase.Failure != nil:
+			suite.Failures++
+		case tCase.Skipped != nil:
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tCase)
+		suiteDuration[run.Suite] += run.Duration()
+		totalDuration += run.Duration()
+	}
+
+	for _, suite := range suites {
+		suite.Time = formatDuration(suiteDuration[suite.Name])
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, *suite)
+	}
+	report.Time = formatDuration(totalDuration)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Creates the test case from all the executions of a test
+func newTestCase(run *reporters.TestRun) testCase {
+	final := run.Final()
+	tCase := testCase{
+		Name:      run.Name,
+		ClassName: run.Suite,
+		Time:      formatDuration(run.Duration()),
+	}
+	if file, line, _, ok := final.CodeLocation(); ok {
+		tCase.File = file
+		tCase.Line = line
+	}
+	if len(run.Attempts) > 1 {
+		tCase.addProperty("test.attempts", fmt.Sprint(len(run.Attempts)))
+	}
+
+	switch final.Status {
+	case tags.TestStatus_FAIL:
+		res, isError := newResult(final)
+		if isError {
+			tCase.Error = res
+		} else {
+			tCase.Failure = res
+		}
+	case tags.TestStatus_SKIP:
+		tCase.Skipped = &result{}
+		if skip := final.SkipEvent(); skip != nil {
+			tCase.Skipped.Message = skip.Message
+		}
+	case tags.TestStatus_CACHE:
+		tCase.Skipped = &result{Message: "cached test result"}
+		tCase.addProperty("test.status", tags.TestStatus_CACHE)
+	}
+
+	// Previous executions of the test (runner retries)
+	flaky := run.IsFlaky()
+	for _, attempt := range run.Attempts[:len(run.Attempts)-1] {
+		if attempt.Status != tags.TestStatus_FAIL {
+			continue
+		}
+		res, isError := newResult(attempt)
+		rerun := rerunResult{
+			Message:    res.Message,
+			Type:       res.Type,
+			StackTrace: res.Contents,
+		}
+		rerun.SystemOut, rerun.SystemErr = getOutputs(attempt)
+		switch {
+		case flaky && isError:
+			tCase.FlakyErrors = append(tCase.FlakyErrors, rerun)
+		case flaky:
+			tCase.FlakyFailures = append(tCase.FlakyFailures, rerun)
+		case isError:
+			tCase.RerunErrors = append(tCase.RerunErrors, rerun)
+		default:
+			tCase.RerunFailures = append(tCase.RerunFailures, rerun)
+		}
+	}
+
+	tCase.SystemOut, tCase.SystemErr = getOutputs(final)
+	return tCase
+}
+
+func (c *testCase) addProperty(name string, value string) {
+	if c.Properties == nil {
+		c.Properties = &properties{}
+	}
+	c.Properties.Properties = append(c.Properties.Properties, property{Name: name, Value: value})
+}
+
+// Creates the failure or error result of a failed test, an exception in the test is reported as an error
+func newResult(testResult *reporters.TestResult) (*result, bool) {
+	res := &result{}
+	event := testResult.FirstFailure()
+	if event == nil {
+		res.Type = "failure"
+		return res, false
+	}
+	res.Message = firstLine(event.Message)
+	res.Contents = event.Message
+	if event.Source != "" {
+		res.Contents += "\n" + event.Source
+	}
+	if event.IsException() {
+		res.Type = "panic"
+		if event.Stack != "" {
+			res.Contents = event.Message + "\n" + event.Stack
+		}
+		return res, true
+	}
+	res.Type = event.Type
+	return res, false
+}
+
+// Gets the standard output and error contents from the test log records
+func getOutputs(testResult *reporters.TestResult) (stdOut *outputContents, stdErr *outputContents) {
+	var outBuilder, errBuilder strings.Builder
+	for _, event := range testResult.Events {
+		if event.Type != tags.LogEvent {
+			continue
+		}
+		line := fmt.Sprintf("%s [%s] %s", event.Timestamp.UTC().Format(time.RFC3339Nano), event.Level, event.Message)
+		if event.Source != "" {
+			line += " (" + event.Source + ")"
+		}
+		if event.Level == tags.LogLevel_ERROR || event.Level == tags.LogLevel_WARNING {
+			errBuilder.WriteString(line)
+			errBuilder.WriteString("\n")
+		} else {
+			outBuilder.WriteString(line)
+			outBuilder.WriteString("\n")
+		}
+	}
+	if outBuilder.Len() > 0 {
+		stdOut = &outputContents{Contents: outBuilder.String()}
+	}
+	if errBuilder.Len() > 0 {
+		stdErr = &outputContents{Contents: errBuilder.String()}
+	}
+	return
+}
+
+func formatDuration(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+func firstLine(value string) string {
+	if idx := strings.IndexByte(value, '\n'); idx >= 0 {
+		return value[:idx]
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package junit
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/reporters"
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func newTestSpan(name string, status string, opts ...reporters.TestSpanOption) tracer.RawSpan {
+	opts = append([]reporters.TestSpanOption{
+		reporters.WithTestCode("/src/junit_test.go:10:20"),
+		reporters.WithTestDuration(1500 * time.Millisecond),
+	}, opts...)
+	return reporters.NewTestSpan("go.undefinedlabs.com/scopeagent/reporters/junit", name, status, opts...)
+}
+
+func TestJUnitReport(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scope-junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	path := filepath.Join(folder, "report", "junit.xml")
+
+	failure := []log.Field{
+		log.String(tags.EventType, tags.EventTestFailure),
+		log.String(tags.EventMessage, "expected 1\ngot 2"),
+	}
+	panicEvent := []log.Field{
+		log.String(tags.EventType, "error"),
+		log.String(tags.EventMessage, "runtime error"),
+		log.String(tags.EventStack, "goroutine 1"),
+	}
+	info := []log.Field{
+		log.String(tags.EventType, tags.LogEvent),
+		log.String(tags.LogEventLevel, tags.LogLevel_INFO),
+		log.String(tags.EventMessage, "hello"),
+	}
+	warning := []log.Field{
+		log.String(tags.EventType, tags.LogEvent),
+		log.String(tags.LogEventLevel, tags.LogLevel_WARNING),
+		log.String(tags.EventMessage, "careful"),
+	}
+
+	recorder := NewRecorder(path)
+	recorder.RecordSpan(tracer.RawSpan{Operation: "HTTP GET", Tags: opentracing.Tags{"span.kind": "client"}})
+	recorder.RecordSpan(newTestSpan("TestPass", tags.TestStatus_PASS, reporters.WithTestEvents(info, warning)))
+	recorder.RecordSpan(newTestSpan("TestFail", tags.TestStatus_FAIL, reporters.WithTestEvents(failure)))
+	recorder.RecordSpan(newTestSpan("TestPanic", tags.TestStatus_FAIL, reporters.WithTestEvents(panicEvent)))
+	recorder.RecordSpan(newTestSpan("TestSkip", tags.TestStatus_SKIP))
+	recorder.RecordSpan(newTestSpan("TestCache", tags.TestStatus_CACHE))
+	recorder.RecordSpan(newTestSpan("TestFlaky", tags.TestStatus_FAIL, reporters.WithTestEvents(failure), reporters.WithTestAttempt(1)))
+	recorder.RecordSpan(newTestSpan("TestFlaky", tags.TestStatus_PASS, reporters.WithTestAttempt(2)))
+	recorder.RecordSpan(newTestSpan("TestBroken", tags.TestStatus_FAIL, reporters.WithTestEvents(failure), reporters.WithTestAttempt(1)))
+	recorder.RecordSpan(newTestSpan("TestBroken", tags.TestStatus_FAIL, reporters.WithTestEvents(failure), reporters.WithTestAttempt(2)))
+	// Repetitions of a test (`go test -count=2`) are different test cases
+	recorder.RecordSpan(newTestSpan("TestCount", tags.TestStatus_FAIL, reporters.WithTestEvents(failure), reporters.WithTestAttempt(1)))
+	recorder.RecordSpan(newTestSpan("TestCount", tags.TestStatus_PASS, reporters.WithTestAttempt(1)))
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report testSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tests != 9 || report.Failures != 3 || report.Errors != 1 || report.Skipped != 2 {
+		t.Fatalf("unexpected totals: %d tests, %d failures, %d errors, %d skipped",
+			report.Tests, report.Failures, report.Errors, report.Skipped)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("expected one test suite, got %d", len(report.Suites))
+	}
+
+	cases := map[string]testCase{}
+	for _, tCase := range report.Suites[0].Cases {
+		cases[tCase.Name] = tCase
+	}
+	if c := cases["TestPass"]; c.File != "/src/junit_test.go" || c.Line != 10 || c.Time != "1.500" {
+		t.Fatalf("unexpected test case: %+v", c)
+	}
+	if c := cases["TestPass"]; c.SystemOut == nil || c.SystemErr == nil {
+		t.Fatal("the test logs are missing")
+	}
+	if c := cases["TestFail"]; c.Failure == nil || c.Failure.Message != "expected 1" {
+		t.Fatalf("unexpected failure: %+v", c.Failure)
+	}
+	if c := cases["TestPanic"]; c.Error == nil || c.Error.Type != "panic" {
+		t.Fatalf("unexpected error: %+v", c.Error)
+	}
+	if c := cases["TestCache"]; c.Skipped == nil || c.Properties == nil {
+		t.Fatal("the cached test is not reported as skipped")
+	}
+	if c := cases["TestFlaky"]; c.Failure != nil || len(c.FlakyFailures) != 1 {
+		t.Fatalf("unexpected flaky test: %+v", c)
+	}
+	if c := cases["TestCount"]; len(c.FlakyFailures) != 0 || len(c.RerunFailures) != 0 {
+		t.Fatalf("the repetitions of a test must not be reported as retries: %+v", c)
+	}
+	if c := cases["TestBroken"]; c.Failure == nil || len(c.RerunFailures) != 1 {
+		t.Fatalf("unexpected retried test: %+v", c)
+	}
+
+	// The report is rewritten if the recorder is stopped again
+	recorder.RecordSpan(newTestSpan("TestLate", tags.TestStatus_PASS))
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadFile(path)
+	report = testSuites{}
+	if err := xml.Unmarshal(data, &report); err != nil || report.Tests != 10 {
+		t.Fatalf("the report has not been rewritten: %v", err)
+	}
+}
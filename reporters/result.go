@@ -0,0 +1,228 @@
+package reporters
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Test result extracted from a test span
+	TestResult struct {
+		Suite     string
+		Name      string
+		Status    string
+		Type      string
+		Framework string
+		Code      string
+		Start     time.Time
+		Duration  time.Duration
+		Context   tracer.SpanContext
+		Tags      map[string]interface{}
+		Events    []TestEvent
+	}
+
+	// Event (log, failure, skip or exception) of a test span
+	TestEvent struct {
+		Timestamp time.Time
+		Type      string
+		Level     string
+		Message   string
+		Source    string
+		Stack     string
+	}
+
+	// All the executions of the same test in a run (the runner retries a test on failure)
+	TestRun struct {
+		Suite    string
+		Name     string
+		Attempts []*TestResult
+	}
+)
+
+// Gets if the span is a test span
+func IsTestSpan(span tracer.RawSpan) bool {
+	return span.Tags["span.kind"] == "test"
+}
+
+// Creates a new test result from a test span
+func NewTestResult(span tracer.RawSpan) *TestResult {
+	result := &TestResult{
+		Suite:     tagString(span.Tags, "test.suite"),
+		Name:      tagString(span.Tags, "test.name"),
+		Status:    tagString(span.Tags, "test.status"),
+		Type:      tagString(span.Tags, "test.type"),
+		Framework: tagString(span.Tags, "test.framework"),
+		Code:      tagString(span.Tags, "test.code"),
+		Start:     span.Start,
+		Duration:  span.Duration,
+		Context:   span.Context,
+		Tags:      map[string]interface{}{},
+	}
+	if result.Name == "" {
+		result.Name = span.Operation
+	}
+	for k, v := range span.Tags {
+		result.Tags[k] = v
+	}
+	for _, record := range span.Logs {
+		event := TestEvent{Timestamp: record.Timestamp}
+		for _, field := range record.Fields {
+			switch field.Key() {
+			case tags.EventType:
+				event.Type = fmt.Sprint(field.Value())
+			case tags.LogEventLevel:
+				event.Level = fmt.Sprint(field.Value())
+			case tags.EventMessage:
+				event.Message = fmt.Sprint(field.Value())
+			case tags.EventSource:
+				event.Source = fmt.Sprint(field.Value())
+			case tags.EventStack:
+				event.Stack = fmt.Sprint(field.Value())
+			}
+		}
+		result.Events = append(result.Events, event)
+	}
+	return result
+}
+
+// Gets the first failure event (exception, test failure or error log) of the test
+func (r *TestResult) FirstFailure() *TestEvent {
+	for idx := range r.Events {
+		event := &r.Events[idx]
+		if event.IsException() || event.Type == tags.EventTestFailure {
+			return event
+		}
+	}
+	for idx := range r.Events {
+		event := &r.Events[idx]
+		if event.Type == tags.LogEvent && event.Level == tags.LogLevel_ERROR {
+			return event
+		}
+	}
+	return nil
+}
+
+// Gets the first skip event of the test
+func (r *TestResult) SkipEvent() *TestEvent {
+	for idx := range r.Events {
+		if r.Events[idx].Type == tags.EventTestSkip {
+			return &r.Events[idx]
+		}
+	}
+	return nil
+}
+
+// Gets the source file and lines of the test func from the `test.code` tag
+func (r *TestResult) CodeLocation() (file string, startLine int, endLine int, ok bool) {
+	return ParseCodeBoundaries(r.Code)
+}
+
+// Gets the attempt number of the test from the `test.attempt` tag, 0 if the tag is missing
+func (r *TestResult) Attempt() int {
+	switch value := r.Tags[tags.TestAttempt].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	case string:
+		attempt, _ := strconv.Atoi(value)
+		return attempt
+	}
+	return 0
+}
+
+// Gets if the event is an exception (panic or error)
+func (e *TestEvent) IsException() bool {
+	return e.Type == "error"
+}
+
+// Gets the last attempt of the test
+func (r *TestRun) Final() *TestResult {
+	return r.Attempts[len(r.Attempts)-1]
+}
+
+// Gets the final status of the test
+func (r *TestRun) Status() string {
+	return r.Final().Status
+}
+
+// Gets if the test failed in a previous attempt and passed in the last one
+func (r *TestRun) IsFlaky() bool {
+	if r.Status() != tags.TestStatus_PASS {
+		return false
+	}
+	for _, attempt := range r.Attempts[:len(r.Attempts)-1] {
+		if attempt.Status == tags.TestStatus_FAIL {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the total duration of all the attempts
+func (r *TestRun) Duration() time.Duration {
+	var duration time.Duration
+	for _, attempt := range r.Attempts {
+		duration += attempt.Duration
+	}
+	return duration
+}
+
+// Groups the executions of the same test (suite and name) in runs, sorted by suite and first execution. A result
+// is a retry of the previous run of the test only if its attempt (`test.attempt` tag) is greater than 1, so the
+// repetitions of a test (`go test -count=N`) are different runs
+func GroupTestRuns(results []*TestResult) []*TestRun {
+	var runs []*TestRun
+	lastRuns := map[string]*TestRun{}
+	for _, result := range results {
+		key := result.Suite + "." + result.Name
+		if run, ok := lastRuns[key]; ok && result.Attempt() > 1 {
+			run.Attempts = append(run.Attempts, result)
+			continue
+		}
+		run := &TestRun{
+			Suite:    result.Suite,
+			Name:     result.Name,
+			Attempts: []*TestResult{result},
+		}
+		lastRuns[key] = run
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Suite < runs[j].Suite
+	})
+	return runs
+}
+
+// Parses the code boundaries with format `{file}:{start line}:{end line}`
+func ParseCodeBoundaries(code string) (file string, startLine int, endLine int, ok bool) {
+	parts := strings.Split(code, ":")
+	if len(parts) < 3 {
+		return "", 0, 0, false
+	}
+	end, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	start, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	// The file path can contain ':' (Windows volumes)
+	return strings.Join(parts[:len(parts)-2], ":"), start, end, true
+}
+
+func tagString(values map[string]interface{}, key string) string {
+	if value, ok := values[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package reporters
+
+import (
+	"testing"
+
+	"go.undefinedlabs.com/scopeagent/tags"
+)
+
+func newResult(name string, status string, attempt int) *TestResult {
+	result := &TestResult{Suite: "example.com/calc", Name: name, Status: status, Tags: map[string]interface{}{}}
+	if attempt > 0 {
+		result.Tags[tags.TestAttempt] = attempt
+	}
+	return result
+}
+
+func TestGroupTestRuns(t *testing.T) {
+	runs := GroupTestRuns([]*TestResult{
+		// Retried by the runner
+		newResult("TestRetry", tags.TestStatus_FAIL, 1),
+		newResult("TestRetry", tags.TestStatus_PASS, 2),
+		// Repeated with `go test -count=2`
+		newResult("TestCount", tags.TestStatus_FAIL, 1),
+		newResult("TestCount", tags.TestStatus_PASS, 1),
+		// Repeated without runner
+		newResult("TestNoRunner", tags.TestStatus_FAIL, 0),
+		newResult("TestNoRunner", tags.TestStatus_PASS, 0),
+	})
+	if len(runs) != 5 {
+		t.Fatalf("expected 5 runs, got %d", len(runs))
+	}
+	if runs[0].Name != "TestRetry" || len(runs[0].Attempts) != 2 || !runs[0].IsFlaky() {
+		t.Fatalf("the retried test must be a flaky run with 2 attempts: %+v", runs[0])
+	}
+	for _, run := range runs[1:] {
+		if len(run.Attempts) != 1 || run.IsFlaky() {
+			t.Fatalf("the repetitions of a test must be different runs: %+v", run)
+		}
+	}
+	if runs[1].Status() != tags.TestStatus_FAIL || runs[2].Status() != tags.TestStatus_PASS {
+		t.Fatal("the runs must keep the execution order")
+	}
+}
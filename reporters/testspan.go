@@ -0,0 +1,67 @@
+package reporters
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"go.undefinedlabs.com/scopeagent/tags"
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+type (
+	// Option of a test span created with NewTestSpan
+	TestSpanOption func(*tracer.RawSpan)
+)
+
+// Creates a test span as recorded by the agent, used to test the reporters. The span lasts 100ms by default.
+func NewTestSpan(suite string, name string, status string, opts ...TestSpanOption) tracer.RawSpan {
+	span := tracer.RawSpan{
+		Context:   tracer.SpanContext{TraceID: uuid.New(), SpanID: 1},
+		Operation: name,
+		Start:     time.Now(),
+		Duration:  100 * time.Millisecond,
+		Tags: opentracing.Tags{
+			"span.kind":   "test",
+			"test.name":   name,
+			"test.suite":  suite,
+			"test.status": status,
+		},
+	}
+	for _, opt := range opts {
+		opt(&span)
+	}
+	return span
+}
+
+// Sets the test code boundaries (`{file}:{start line}:{end line}`)
+func WithTestCode(code string) TestSpanOption {
+	return func(span *tracer.RawSpan) {
+		span.Tags["test.code"] = code
+	}
+}
+
+// Sets the test duration
+func WithTestDuration(duration time.Duration) TestSpanOption {
+	return func(span *tracer.RawSpan) {
+		span.Duration = duration
+	}
+}
+
+// Sets the attempt of the test (runner retries)
+func WithTestAttempt(attempt int) TestSpanOption {
+	return func(span *tracer.RawSpan) {
+		span.Tags[tags.TestAttempt] = attempt
+	}
+}
+
+// Adds the events (log records) to the test span
+func WithTestEvents(events ...[]log.Field) TestSpanOption {
+	return func(span *tracer.RawSpan) {
+		for _, fields := range events {
+			span.Logs = append(span.Logs, opentracing.LogRecord{Timestamp: span.Start, Fields: fields})
+		}
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	goerrors "github.com/go-errors/errors"
 
@@ -17,10 +19,11 @@ import (
 
 type (
 	testRunner struct {
-		m          *testing.M
-		options    Options
-		failed     bool
-		failedLock sync.Mutex
+		m            *testing.M
+		options      Options
+		failed       bool
+		failedLock   sync.Mutex
+		totalRetries int
 	}
 	testDescriptor struct {
 		runner        *testRunner
@@ -31,30 +34,59 @@ type (
 		error         bool
 		skipped       bool
 		ignoreRetries bool
+		quarantined   bool
+		retries       int
+		attempt       int
+		timeout       time.Duration
+
+		attemptMutex sync.Mutex
+		attemptDone  chan struct{}
+		timedOut     bool
 	}
 	Options struct {
 		FailRetries int
 		PanicAsFail bool
 		Logger      *log.Logger
 		OnPanic     func(t *testing.T, err interface{})
+		// Gets if a test is quarantined, the failures of a quarantined test don't fail the test run
+		IsQuarantined func(test testing.InternalTest) bool
+		// Runs only the share of the tests of the shard (zero based index) when the total is greater than 1
+		ShardIndex int
+		ShardTotal int
+		// Gets the historical duration of a test, used to balance the shards
+		ShardDurations func(test testing.InternalTest) (time.Duration, bool)
+		// Gets the retries of a test (ex: per package or per test configuration), overrides FailRetries
+		GetFailRetries func(test testing.InternalTest) (int, bool)
+		// Delay before the first retry, multiplied by the backoff in the next retries
+		RetryDelay   time.Duration
+		RetryBackoff float64
+		// Failure kinds retried, all the failures are retried if empty
+		RetryOn []FailureKind
+		// Max number of retries of all the tests in the run, unlimited if zero
+		MaxTotalRetries int
+		// Shuffles the tests order using the seed
+		Shuffle     bool
+		ShuffleSeed int64
+		// Max duration of each test attempt, disabled if zero
+		TestTimeout time.Duration
+		// Gets the timeout of a test, overrides TestTimeout
+		GetTestTimeout func(test testing.InternalTest) (time.Duration, bool)
+		// Called when a test exceeds its timeout with the stacks of all the goroutines
+		OnTimeout func(t *testing.T, timeout time.Duration, goroutineStacks []byte)
 	}
 )
 
 var (
 	runner          *testRunner
-	runnerRegexName = regexp.MustCompile(`(?m)([\w -:_]*)\/\[runner.[\w:]*](\/[\w -:_]*)?`)
+	runnerRegexName = regexp.MustCompile(`\/\[runner\.[\w:]*]`)
 
 	descByTestMutex = sync.RWMutex{}
 	descByTestMap   = map[*testing.T]*testDescriptor{}
 )
 
-// Gets the test name
+// Gets the test name (without the runner sub tests, the sub tests run by `RunSubTest` are nested)
 func GetOriginalTestName(name string) string {
-	match := runnerRegexName.FindStringSubmatch(name)
-	if match == nil || len(match) == 0 {
-		return name
-	}
-	return match[1] + match[2]
+	return runnerRegexName.ReplaceAllString(name, "")
 }
 
 // Runs a test suite
@@ -70,7 +102,10 @@ func Run(m *testing.M, options Options) int {
 		options: options,
 		failed:  false,
 	}
-	runner.init(options.FailRetries > 0 || options.PanicAsFail)
+	runner.shard()
+	runner.shuffle()
+	runner.init(options.FailRetries > 0 || options.PanicAsFail || options.IsQuarantined != nil ||
+		options.GetFailRetries != nil || options.TestTimeout > 0 || options.GetTestTimeout != nil)
 	return runner.m.Run()
 }
 
@@ -87,6 +122,11 @@ func (r *testRunner) init(enableRunner bool) {
 					ran:    0,
 					failed: false,
 				}
+				if r.options.IsQuarantined != nil {
+					td.quarantined = r.options.IsQuarantined(test)
+				}
+				td.retries = r.options.getFailRetries(test)
+				td.timeout = r.options.getTestTimeout(test)
 				tests = append(tests, testing.InternalTest{
 					Name: test.Name,
 					F:    td.run,
@@ -112,6 +152,40 @@ func (r *testRunner) init(enableRunner bool) {
 	}
 }
 
+// Removes the tests not included in the current shard
+func (r *testRunner) shard() {
+	if r.options.ShardTotal <= 1 {
+		return
+	}
+	if tPointer, err := reflection.GetFieldPointerOf(r.m, "tests"); err == nil {
+		internalTests := (*[]testing.InternalTest)(tPointer)
+		tests := GetShardTests(*internalTests, r.options.ShardIndex, r.options.ShardTotal, r.options.ShardDurations)
+		r.options.Logger.Printf("shard %d/%d: running %d of %d tests", r.options.ShardIndex, r.options.ShardTotal,
+			len(tests), len(*internalTests))
+		*internalTests = tests
+	}
+}
+
+// Shuffles the tests order, the seed is printed so the order can be reproduced
+func (r *testRunner) shuffle() {
+	if !r.options.Shuffle {
+		return
+	}
+	if tPointer, err := reflection.GetFieldPointerOf(r.m, "tests"); err == nil {
+		internalTests := (*[]testing.InternalTest)(tPointer)
+		ShuffleTests(*internalTests, r.options.ShuffleSeed)
+		fmt.Printf("[SCOPE SHUFFLE] seed: %d\n", r.options.ShuffleSeed)
+		r.options.Logger.Printf("tests shuffled with seed: %d", r.options.ShuffleSeed)
+	}
+}
+
+// Shuffles the tests using the seed
+func ShuffleTests(tests []testing.InternalTest, seed int64) {
+	rand.New(rand.NewSource(seed)).Shuffle(len(tests), func(i, j int) {
+		tests[i], tests[j] = tests[j], tests[i]
+	})
+}
+
 // Internal test runner, each test calls this method in order to handle retries and process exiting
 func (td *testDescriptor) run(t *testing.T) {
 	run := 1
@@ -120,6 +194,8 @@ func (td *testDescriptor) run(t *testing.T) {
 
 	for {
 		var innerTest *testing.T
+		panicked := false
+		td.attempt = run
 		title := "Run"
 		if run > 1 {
 			title = "Retry:" + strconv.Itoa(run-1)
@@ -130,10 +206,12 @@ func (td *testDescriptor) run(t *testing.T) {
 			// https://stackoverflow.com/a/53950628
 			setChattyFlag(it, false) // avoid the [exec] subtest in stdout
 			it.Run("[exec]", func(gt *testing.T) {
+				defer td.endAttempt()
 				defer func() {
 					if rc := recover(); rc != nil {
 						// using go-errors to preserve stacktrace
 						innerError = goerrors.Wrap(rc, 2)
+						panicked = true
 						gt.FailNow()
 					}
 					unlinkTestDescriptor(gt)
@@ -142,6 +220,7 @@ func (td *testDescriptor) run(t *testing.T) {
 				setTestName(gt, strings.Replace(it.Name(), "[exec]", "", -1)) // removes [exec] from name
 				linkTestDescriptor(gt, td)
 				innerTest = gt
+				td.startAttempt(gt)
 				td.test.F(gt)
 			})
 			if reflection.GetIsParallel(innerTest) && !reflection.GetIsParallel(t) {
@@ -149,7 +228,7 @@ func (td *testDescriptor) run(t *testing.T) {
 			}
 		})
 		if innerError != nil {
-			if !options.PanicAsFail {
+			if !options.PanicAsFail && !td.quarantined {
 				options.OnPanic(t, innerError)
 				panic(innerError.ErrorStack())
 			}
@@ -177,12 +256,30 @@ func (td *testDescriptor) run(t *testing.T) {
 			break
 		}
 
-		if run > options.FailRetries {
+		if run > td.retries {
 			break
 		}
 		if td.ignoreRetries {
 			break
 		}
+		failureKind := FailureAssertion
+		if panicked {
+			failureKind = FailurePanic
+		} else if td.isTimedOut() {
+			failureKind = FailureTimeout
+		}
+		if !options.shouldRetry(failureKind) {
+			options.Logger.Printf("test '%s' %s - the %s failure is not retried", t.Name(), title, failureKind)
+			break
+		}
+		if !td.runner.reserveRetry() {
+			options.Logger.Printf("test '%s' %s - the max total retries (%d) has been reached", t.Name(), title,
+				options.MaxTotalRetries)
+			break
+		}
+		if delay := options.getRetryDelay(run); delay > 0 {
+			time.Sleep(delay)
+		}
 		run++
 	}
 
@@ -190,7 +287,7 @@ func (td *testDescriptor) run(t *testing.T) {
 	td.refreshGlobalFailedFlag(t)
 
 	if td.error {
-		if !options.PanicAsFail {
+		if !options.PanicAsFail && !td.quarantined {
 			// If after all recovers and retries the test finish with error and we have the exitOnError flag,
 			// we panic with the latest recovered data
 			options.OnPanic(t, innerError)
@@ -199,16 +296,24 @@ func (td *testDescriptor) run(t *testing.T) {
 		fmt.Printf("panic info for test '%s': %v\n", t.Name(), innerError)
 		options.Logger.Printf("panic info for test '%s': %v", t.Name(), innerError)
 	}
-	if !td.error && !td.failed {
-		// If test pass or flaky
+	if td.quarantined && (td.error || td.failed) {
+		options.Logger.Printf("test '%s' is quarantined, the failure is ignored", t.Name())
+	}
+	if !td.hasFailed() {
+		// If test pass, flaky or quarantined
 		setTestFailureFlag(t, false)
 	}
 }
 
+// Gets if the test has failed (the failures of quarantined tests are ignored)
+func (td *testDescriptor) hasFailed() bool {
+	return (td.failed || td.error) && !td.quarantined
+}
+
 func (td *testDescriptor) refreshGlobalFailedFlag(t *testing.T) {
 	td.runner.failedLock.Lock()
 	defer td.runner.failedLock.Unlock()
-	td.runner.failed = td.runner.failed || td.failed || td.error
+	td.runner.failed = td.runner.failed || td.hasFailed()
 	tParent := getTestParent(t)
 	if tParent != nil {
 		setTestFailureFlag(tParent, td.runner.failed)
@@ -303,6 +408,38 @@ func IgnoreRetries(t *testing.T) {
 	}
 }
 
+// Runs a sub test of a test handled by the runner with the retries and quarantine of the parent test (ex: the
+// tests of a testify suite). Returns false if the sub test has failed after all the retries
+func RunSubTest(t *testing.T, name string, f func(t *testing.T)) bool {
+	parent := getTestDescriptor(t)
+	if parent == nil {
+		return t.Run(name, f)
+	}
+	td := &testDescriptor{
+		runner:      parent.runner,
+		test:        testing.InternalTest{Name: name, F: f},
+		retries:     parent.retries,
+		quarantined: parent.quarantined,
+	}
+
+	// The failures of the sub test attempts are propagated to all the ancestors, so we keep the current
+	// failure flags in order to restore them if the sub test finally passes
+	var ancestors []*testing.T
+	var failed []bool
+	for tAncestor := t; tAncestor != nil; tAncestor = getTestParent(tAncestor) {
+		ancestors = append(ancestors, tAncestor)
+		failed = append(failed, tAncestor.Failed())
+	}
+	t.Run(name, td.run)
+	if td.hasFailed() {
+		return false
+	}
+	for idx, tAncestor := range ancestors {
+		setTestFailureFlag(tAncestor, failed[idx])
+	}
+	return true
+}
+
 // Gets the runner options
 func GetRunnerOptions() *Options {
 	if runner == nil {
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Kind of a test failure, used to decide if a failed test is retried
+type FailureKind string
+
+const (
+	// The test failed by an assertion (ex: t.Fail, t.Error, t.Fatal)
+	FailureAssertion FailureKind = "assertion"
+	// The test panicked (only with PanicAsFail)
+	FailurePanic FailureKind = "panic"
+	// The test exceeded its timeout
+	FailureTimeout FailureKind = "timeout"
+)
+
+const maxRetryDelay = time.Minute
+
+// Parses the failure kinds
+func ParseFailureKinds(values []string) ([]FailureKind, error) {
+	var kinds []FailureKind
+	for _, value := range values {
+		kind := FailureKind(strings.ToLower(strings.TrimSpace(value)))
+		switch kind {
+		case FailureAssertion, FailurePanic, FailureTimeout:
+			kinds = append(kinds, kind)
+		case "":
+		default:
+			return kinds, fmt.Errorf("unknown failure kind: %s", value)
+		}
+	}
+	return kinds, nil
+}
+
+// Gets the max retries of the test
+func (o *Options) getFailRetries(test testing.InternalTest) int {
+	if o.GetFailRetries != nil {
+		if retries, ok := o.GetFailRetries(test); ok {
+			return retries
+		}
+	}
+	return o.FailRetries
+}
+
+// Gets if a failure kind is retried
+func (o *Options) shouldRetry(kind FailureKind) bool {
+	if len(o.RetryOn) == 0 {
+		return true
+	}
+	for _, retryKind := range o.RetryOn {
+		if retryKind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the delay before a retry (1 based), the delay is multiplied by the backoff in each retry
+func (o *Options) getRetryDelay(retry int) time.Duration {
+	if o.RetryDelay <= 0 {
+		return 0
+	}
+	delay := float64(o.RetryDelay)
+	if o.RetryBackoff > 1 {
+		for i := 1; i < retry; i++ {
+			delay *= o.RetryBackoff
+		}
+	}
+	if delay > float64(maxRetryDelay) {
+		return maxRetryDelay
+	}
+	return time.Duration(delay)
+}
+
+// Reserves a retry from the max total retries of the run
+func (r *testRunner) reserveRetry() bool {
+	r.failedLock.Lock()
+	defer r.failedLock.Unlock()
+	if r.options.MaxTotalRetries > 0 && r.totalRetries >= r.options.MaxTotalRetries {
+		return false
+	}
+	r.totalRetries++
+	return true
+}
+
+// Marks the current attempt of the test as failed by a timeout
+func SetTimeoutFailure(t *testing.T) {
+	td := getTestDescriptor(t)
+	if td != nil {
+		td.attemptMutex.Lock()
+		defer td.attemptMutex.Unlock()
+		td.timedOut = true
+	}
+}
+
+// Gets the attempt number (starting at 1) of a test run by the runner, 0 if the test is not handled by the runner
+func GetTestAttempt(t *testing.T) int {
+	td := getTestDescriptor(t)
+	if td == nil {
+		return 0
+	}
+	return td.attempt
+}
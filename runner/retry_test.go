@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	kinds, err := ParseFailureKinds([]string{"Panic", " timeout"})
+	if err != nil || !reflect.DeepEqual(kinds, []FailureKind{FailurePanic, FailureTimeout}) {
+		t.Fatalf("unexpected failure kinds: %v %v", kinds, err)
+	}
+	if _, err := ParseFailureKinds([]string{"other"}); err == nil {
+		t.Fatal("an error was expected for an unknown failure kind")
+	}
+
+	options := Options{
+		FailRetries:  1,
+		RetryDelay:   time.Second,
+		RetryBackoff: 2,
+		RetryOn:      kinds,
+		GetFailRetries: func(test testing.InternalTest) (int, bool) {
+			return 5, test.Name == "TestOverride"
+		},
+	}
+	if options.shouldRetry(FailureAssertion) || !options.shouldRetry(FailurePanic) {
+		t.Fatal("unexpected retry decision")
+	}
+	if options.getFailRetries(testing.InternalTest{Name: "TestOverride"}) != 5 ||
+		options.getFailRetries(testing.InternalTest{Name: "TestOther"}) != 1 {
+		t.Fatal("unexpected retries")
+	}
+	for retry, delay := range map[int]time.Duration{1: time.Second, 3: 4 * time.Second, 10: maxRetryDelay} {
+		if d := options.getRetryDelay(retry); d != delay {
+			t.Errorf("unexpected delay for retry %d: %v", retry, d)
+		}
+	}
+
+	r := &testRunner{options: Options{MaxTotalRetries: 2}}
+	if !r.reserveRetry() || !r.reserveRetry() || r.reserveRetry() {
+		t.Fatal("the max total retries has not been applied")
+	}
+}
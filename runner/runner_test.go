@@ -2,6 +2,7 @@ package runner
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -12,6 +13,10 @@ var (
 	errorCount  = 0
 	flakyCount  = 0
 	failSubTest = 0
+
+	quarantinedCount  = 0
+	flakySubTestCount = 0
+	okSubTestCount    = 0
 )
 
 func TestMain(m *testing.M) {
@@ -22,6 +27,9 @@ func TestMain(m *testing.M) {
 		OnPanic: func(t *testing.T, err interface{}) {
 			fmt.Printf("the test '%s' has paniked with error: %s", t.Name(), err)
 		},
+		IsQuarantined: func(test testing.InternalTest) bool {
+			return test.Name == "TestQuarantined"
+		},
 	})
 	fmt.Println(okCount, failCount, errorCount, flakyCount, failSubTest)
 	if okCount != 1 {
@@ -39,6 +47,12 @@ func TestMain(m *testing.M) {
 	if failCount != 5 {
 		panic("TestFailSubTest ran an unexpected number of times")
 	}
+	if quarantinedCount != 5 {
+		panic("TestQuarantined ran an unexpected number of times")
+	}
+	if flakySubTestCount != 2 || okSubTestCount != 1 {
+		panic("TestRunSubTest ran an unexpected number of times")
+	}
 }
 
 func TestOk(t *testing.T) {
@@ -75,6 +89,11 @@ func TestFlaky(t *testing.T) {
 	}
 }
 
+func TestQuarantined(t *testing.T) {
+	quarantinedCount++
+	t.Fatal("the failure of a quarantined test is ignored")
+}
+
 func TestFailSubTest(t *testing.T) {
 	t.Run("SubTest", func(t *testing.T) {
 		if GetOriginalTestName(t.Name()) != "TestFailSubTest/SubTest" {
@@ -92,6 +111,24 @@ func TestFailSubTest(t *testing.T) {
 	})
 }
 
+func TestRunSubTest(t *testing.T) {
+	ok := RunSubTest(t, "Flaky", func(t *testing.T) {
+		if GetOriginalTestName(t.Name()) != "TestRunSubTest/Flaky" {
+			t.Fatal("test name is invalid.")
+		}
+		flakySubTestCount++
+		if flakySubTestCount < 2 {
+			t.Fatal("this is flaky")
+		}
+	})
+	if !ok || t.Failed() {
+		t.Fatal("the failure of the flaky sub test must be ignored")
+	}
+	RunSubTest(t, "Ok", func(t *testing.T) {
+		okSubTestCount++
+	})
+}
+
 func TestParallelPass(t *testing.T) {
 	t.Parallel()
 
@@ -129,3 +166,31 @@ func TestParallelPanic(t *testing.T) {
 	<-time.After(1 * time.Second)
 	panic("forced parallel test panic")
 }
+
+func TestShuffleTests(t *testing.T) {
+	names := func(tests []testing.InternalTest) string {
+		var names []string
+		for _, test := range tests {
+			names = append(names, test.Name)
+		}
+		return strings.Join(names, ",")
+	}
+	newTests := func() []testing.InternalTest {
+		var tests []testing.InternalTest
+		for i := 0; i < 10; i++ {
+			tests = append(tests, testing.InternalTest{Name: fmt.Sprintf("Test%d", i)})
+		}
+		return tests
+	}
+	original := names(newTests())
+	first, second, other := newTests(), newTests(), newTests()
+	ShuffleTests(first, 42)
+	ShuffleTests(second, 42)
+	ShuffleTests(other, 43)
+	if names(first) != names(second) {
+		t.Fatal("the same seed must generate the same order")
+	}
+	if names(first) == original || names(first) == names(other) {
+		t.Fatal("the tests have not been shuffled")
+	}
+}
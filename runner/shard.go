@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"hash/fnv"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Gets the tests of the shard (zero based index), the selection is deterministic for the same tests list.
+// If the durations func is set, the tests are balanced by duration between shards, if not the tests are
+// distributed by the hash of the name, so adding or removing a test doesn't move the other tests.
+func GetShardTests(tests []testing.InternalTest, index int, total int,
+	durations func(test testing.InternalTest) (time.Duration, bool)) []testing.InternalTest {
+	if total <= 1 || index < 0 || index >= total {
+		return tests
+	}
+	shardOf := make(map[string]int, len(tests))
+	if durations != nil {
+		shardOf = balanceShards(tests, total, durations)
+	} else {
+		for _, test := range tests {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(test.Name))
+			shardOf[test.Name] = int(h.Sum32() % uint32(total))
+		}
+	}
+	var shardTests []testing.InternalTest
+	for _, test := range tests {
+		if shardOf[test.Name] == index {
+			shardTests = append(shardTests, test)
+		}
+	}
+	return shardTests
+}
+
+// Assigns each test to the shard with the lowest load, from the longest to the shortest test.
+// The tests without a duration use the average duration
+func balanceShards(tests []testing.InternalTest, total int,
+	durations func(test testing.InternalTest) (time.Duration, bool)) map[string]int {
+	type weightedTest struct {
+		name     string
+		duration time.Duration
+		known    bool
+	}
+	weighted := make([]weightedTest, len(tests))
+	var sum time.Duration
+	var known int
+	for i, test := range tests {
+		weighted[i].name = test.Name
+		weighted[i].duration, weighted[i].known = durations(test)
+		if weighted[i].known {
+			sum += weighted[i].duration
+			known++
+		}
+	}
+	average := time.Millisecond
+	if known > 0 {
+		average = sum / time.Duration(known)
+	}
+	for i := range weighted {
+		if !weighted[i].known {
+			weighted[i].duration = average
+		}
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		if weighted[i].duration == weighted[j].duration {
+			return weighted[i].name < weighted[j].name
+		}
+		return weighted[i].duration > weighted[j].duration
+	})
+
+	loads := make([]time.Duration, total)
+	shardOf := make(map[string]int, len(tests))
+	for _, test := range weighted {
+		shard := 0
+		for i := 1; i < total; i++ {
+			if loads[i] < loads[shard] {
+				shard = i
+			}
+		}
+		loads[shard] += test.duration
+		shardOf[test.name] = shard
+	}
+	return shardOf
+}
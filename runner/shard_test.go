@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestShardTests(t *testing.T) {
+	var tests []testing.InternalTest
+	for i := 0; i < 20; i++ {
+		tests = append(tests, testing.InternalTest{Name: fmt.Sprintf("Test%d", i)})
+	}
+	durations := func(test testing.InternalTest) (time.Duration, bool) {
+		if test.Name == "Test0" {
+			return 10 * time.Second, true
+		}
+		return time.Second, test.Name != "Test1"
+	}
+
+	for _, dFunc := range []func(testing.InternalTest) (time.Duration, bool){nil, durations} {
+		seen := map[string]int{}
+		var shards [][]testing.InternalTest
+		for i := 0; i < 3; i++ {
+			shard := GetShardTests(tests, i, 3, dFunc)
+			shards = append(shards, shard)
+			for _, test := range shard {
+				seen[test.Name]++
+			}
+			if again := GetShardTests(tests, i, 3, dFunc); len(again) != len(shard) {
+				t.Fatal("the shard selection is not deterministic")
+			}
+		}
+		if len(seen) != len(tests) {
+			t.Fatalf("the shards don't include all the tests: %v", seen)
+		}
+		for name, count := range seen {
+			if count != 1 {
+				t.Fatalf("the test %s is in %d shards", name, count)
+			}
+		}
+		if dFunc != nil {
+			// Test0 (10s) is alone in its shard and the other 19 tests (1s) are split in the other two
+			for _, shard := range shards {
+				if shard[0].Name == "Test0" && len(shard) != 1 {
+					t.Fatalf("the shards are not balanced: %v", len(shard))
+				}
+			}
+		}
+	}
+}
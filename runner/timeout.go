@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Time to wait for a timed out test to finish before stopping the test run
+const timeoutGracePeriod = 5 * time.Second
+
+// Gets the timeout of the test
+func (o *Options) getTestTimeout(test testing.InternalTest) time.Duration {
+	if o.GetTestTimeout != nil {
+		if timeout, ok := o.GetTestTimeout(test); ok {
+			return timeout
+		}
+	}
+	return o.TestTimeout
+}
+
+// Starts a new attempt of the test, the timeout watcher is started if the test has a timeout
+func (td *testDescriptor) startAttempt(t *testing.T) {
+	td.attemptMutex.Lock()
+	defer td.attemptMutex.Unlock()
+	td.timedOut = false
+	td.attemptDone = make(chan struct{})
+	if td.timeout > 0 {
+		go td.watchTimeout(t, td.attemptDone)
+	}
+}
+
+// Ends the current attempt of the test
+func (td *testDescriptor) endAttempt() {
+	td.attemptMutex.Lock()
+	defer td.attemptMutex.Unlock()
+	if td.attemptDone != nil {
+		close(td.attemptDone)
+		td.attemptDone = nil
+	}
+}
+
+// Gets if the current attempt of the test has timed out
+func (td *testDescriptor) isTimedOut() bool {
+	td.attemptMutex.Lock()
+	defer td.attemptMutex.Unlock()
+	return td.timedOut
+}
+
+// Fails the test if the attempt exceeds the timeout. The goroutine of a hung test can't be stopped,
+// so if the test doesn't finish after a grace period the test run is stopped
+func (td *testDescriptor) watchTimeout(t *testing.T, done chan struct{}) {
+	options := td.runner.options
+	timer := time.NewTimer(td.timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	goroutineStacks := getGoroutineStacks()
+	td.attemptMutex.Lock()
+	if td.attemptDone != done {
+		// The attempt has finished
+		td.attemptMutex.Unlock()
+		return
+	}
+	td.timedOut = true
+	t.Errorf("test timed out after %v", td.timeout)
+	td.attemptMutex.Unlock()
+
+	options.Logger.Printf("test '%s' timed out after %v", t.Name(), td.timeout)
+	if options.OnTimeout != nil {
+		options.OnTimeout(t, td.timeout, goroutineStacks)
+	}
+
+	select {
+	case <-done:
+		return
+	case <-time.After(timeoutGracePeriod):
+	}
+	err := fmt.Errorf("test '%s' timed out after %v and is still running", t.Name(), td.timeout)
+	options.OnPanic(t, err)
+	fmt.Printf("%v\n\n%s\n", err, goroutineStacks)
+	panic(err)
+}
+
+// Gets the stacks of all the goroutines
+func getGoroutineStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 64*1024*1024 {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
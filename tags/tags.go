@@ -9,6 +9,7 @@ const (
 	AgentType    = "agent.type"
 	AgentID      = "agent.id"
 	AgentVersion = "agent.version"
+	BatchID      = "batch.id"
 
 	PlatformName         = "platform.name"
 	PlatformArchitecture = "platform.architecture"
@@ -69,6 +70,29 @@ const (
 	ConfigurationKeys = "configuration.keys"
 
 	Coverage = "test.coverage"
+
+	TestQuarantined = "test.quarantined"
+	TestAttempt     = "test.attempt"
+	TestTimeout     = "test.timeout"
+
+	TestGoroutineLeaks = "test.goroutine_leaks"
+
+	TestHook = "test.hook"
+
+	ShardIndex = "shard.index"
+	ShardTotal = "shard.total"
+
+	ShuffleSeed = "shuffle.seed"
+
+	TestImpactBaseCommit   = "test_impact.base_commit"
+	TestImpactSkippedTests = "test_impact.skipped_tests"
+
+	BenchmarkBaselineCommit = "benchmark.baseline.commit"
+
+	BenchmarkVerdict             = "benchmark.verdict"
+	BenchmarkVerdict_REGRESSION  = "REGRESSION"
+	BenchmarkVerdict_IMPROVEMENT = "IMPROVEMENT"
+	BenchmarkVerdict_UNCHANGED   = "UNCHANGED"
 )
 
 func GetValidValue(value interface{}) (interface{}, bool) {
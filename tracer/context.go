@@ -15,6 +15,9 @@ type SpanContext struct {
 
 	// The span's associated baggage.
 	Baggage map[string]string // initialized on first use
+
+	// The W3C `tracestate` of the trace, propagated as is to the child spans.
+	TraceState string
 }
 
 // ForeachBaggageItem belongs to the opentracing.SpanContext interface
@@ -40,5 +43,5 @@ func (c SpanContext) WithBaggageItem(key, val string) SpanContext {
 		newBaggage[key] = val
 	}
 	// Use positional parameters so the compiler will help catch new fields.
-	return SpanContext{c.TraceID, c.SpanID, c.Sampled, newBaggage}
+	return SpanContext{c.TraceID, c.SpanID, c.Sampled, newBaggage, c.TraceState}
 }
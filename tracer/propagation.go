@@ -1,14 +1,125 @@
 package tracer
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/google/uuid"
 	opentracing "github.com/opentracing/opentracing-go"
 )
 
+// Format used to propagate the span context in TextMap and HTTPHeaders carriers
+type PropagationFormat string
+
+const (
+	// OpenTracing format (`ot-tracer-*` and `ot-baggage-*` keys)
+	PropagationOpenTracing PropagationFormat = "ot"
+	// W3C Trace Context format (`traceparent`, `tracestate` and `baggage` headers)
+	PropagationW3C PropagationFormat = "tracecontext"
+	// B3 single header format (`b3` header)
+	PropagationB3 PropagationFormat = "b3"
+	// B3 multiple headers format (`X-B3-*` headers)
+	PropagationB3Multi PropagationFormat = "b3multi"
+)
+
+type propagator interface {
+	Inject(spanContext opentracing.SpanContext, carrier interface{}) error
+	Extract(carrier interface{}) (opentracing.SpanContext, error)
+}
+
 type accessorPropagator struct {
 	tracer *tracerImpl
 }
 
+// Propagator that injects all the formats and extracts the first one found in the carrier
+type compositePropagator struct {
+	propagators []propagator
+}
+
+// Parses the propagation formats names (ex: from an environment variable)
+func ParsePropagationFormats(values []string) ([]PropagationFormat, error) {
+	var formats []PropagationFormat
+	for _, value := range values {
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "":
+			continue
+		case "ot", "opentracing":
+			formats = append(formats, PropagationOpenTracing)
+		case "tracecontext", "w3c":
+			formats = append(formats, PropagationW3C)
+		case "b3":
+			formats = append(formats, PropagationB3)
+		case "b3multi":
+			formats = append(formats, PropagationB3Multi)
+		default:
+			return nil, fmt.Errorf("unknown propagation format: %s", value)
+		}
+	}
+	return formats, nil
+}
+
+// Creates the propagator for the TextMap and HTTPHeaders carriers
+func newHTTPPropagator(tracer *tracerImpl, formats []PropagationFormat) propagator {
+	var propagators []propagator
+	added := map[PropagationFormat]bool{}
+	for _, format := range formats {
+		if added[format] {
+			continue
+		}
+		switch format {
+		case PropagationOpenTracing:
+			propagators = append(propagators, tracer.textPropagator)
+		case PropagationW3C:
+			propagators = append(propagators, &w3cPropagator{tracer})
+		case PropagationB3:
+			propagators = append(propagators, &b3Propagator{tracer: tracer, singleHeader: true})
+		case PropagationB3Multi:
+			propagators = append(propagators, &b3Propagator{tracer: tracer})
+		default:
+			continue
+		}
+		added[format] = true
+	}
+	if len(propagators) == 0 {
+		return tracer.textPropagator
+	}
+	if len(propagators) == 1 {
+		return propagators[0]
+	}
+	return &compositePropagator{propagators}
+}
+
+func (p *compositePropagator) Inject(
+	spanContext opentracing.SpanContext,
+	carrier interface{},
+) error {
+	for _, prop := range p.propagators {
+		if err := prop.Inject(spanContext, carrier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *compositePropagator) Extract(
+	carrier interface{},
+) (opentracing.SpanContext, error) {
+	var firstError error
+	for _, prop := range p.propagators {
+		sc, err := prop.Extract(carrier)
+		if err == nil {
+			return sc, nil
+		}
+		if err != opentracing.ErrSpanContextNotFound && firstError == nil {
+			firstError = err
+		}
+	}
+	if firstError != nil {
+		return nil, firstError
+	}
+	return nil, opentracing.ErrSpanContextNotFound
+}
+
 // DelegatingCarrier is a flexible carrier interface which can be implemented
 // by types which have a means of storing the trace metadata and already know
 // how to serialize themselves (for example, protocol buffers).
@@ -0,0 +1,144 @@
+package tracer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// B3 propagator (https://github.com/openzipkin/b3-propagation) with single or multiple headers
+type b3Propagator struct {
+	tracer       *tracerImpl
+	singleHeader bool
+}
+
+const (
+	fieldNameB3Single  = "b3"
+	fieldNameB3TraceID = "x-b3-traceid"
+	fieldNameB3SpanID  = "x-b3-spanid"
+	fieldNameB3Sampled = "x-b3-sampled"
+	fieldNameB3Flags   = "x-b3-flags"
+
+	headerB3TraceID = "X-B3-TraceId"
+	headerB3SpanID  = "X-B3-SpanId"
+	headerB3Sampled = "X-B3-Sampled"
+)
+
+func (p *b3Propagator) Inject(
+	spanContext opentracing.SpanContext,
+	opaqueCarrier interface{},
+) error {
+	sc, ok := spanContext.(SpanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	carrier, ok := opaqueCarrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	sampled := "0"
+	if sc.Sampled {
+		sampled = "1"
+	}
+	if p.singleHeader {
+		carrier.Set(fieldNameB3Single, fmt.Sprintf("%s-%016x-%s", UUIDToString(sc.TraceID), sc.SpanID, sampled))
+	} else {
+		carrier.Set(headerB3TraceID, UUIDToString(sc.TraceID))
+		carrier.Set(headerB3SpanID, fmt.Sprintf("%016x", sc.SpanID))
+		carrier.Set(headerB3Sampled, sampled)
+	}
+	return nil
+}
+
+func (p *b3Propagator) Extract(
+	opaqueCarrier interface{},
+) (opentracing.SpanContext, error) {
+	carrier, ok := opaqueCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	var single, traceID, spanID, sampled, flags string
+	err := carrier.ForeachKey(func(k, v string) error {
+		switch strings.ToLower(k) {
+		case fieldNameB3Single:
+			single = v
+		case fieldNameB3TraceID:
+			traceID = v
+		case fieldNameB3SpanID:
+			spanID = v
+		case fieldNameB3Sampled:
+			sampled = v
+		case fieldNameB3Flags:
+			flags = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.singleHeader {
+		if single == "" {
+			return nil, opentracing.ErrSpanContextNotFound
+		}
+		// `{trace-id}-{span-id}-{sampling}-{parent-span-id}`, the sampling and parent are optional
+		parts := strings.Split(strings.TrimSpace(single), "-")
+		if len(parts) == 1 {
+			// Only a sampling decision, there isn't a span context to continue
+			return nil, opentracing.ErrSpanContextNotFound
+		}
+		if len(parts) > 4 {
+			return nil, opentracing.ErrSpanContextCorrupted
+		}
+		traceID, spanID = parts[0], parts[1]
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+	} else if traceID == "" && spanID == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	sc, err := p.parse(traceID, spanID, sampled, flags)
+	if err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+func (p *b3Propagator) parse(traceID, spanID, sampled, flags string) (SpanContext, error) {
+	// 64 bits trace ids are left padded to 128 bits
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if len(traceID) != 32 || len(spanID) != 16 {
+		return SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	tID, err := StringToUUID(strings.ToLower(traceID))
+	if err != nil || tID == (uuid.UUID{}) {
+		return SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	sID, err := strconv.ParseUint(spanID, 16, 64)
+	if err != nil || sID == 0 {
+		return SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	sc := SpanContext{
+		TraceID: tID,
+		SpanID:  sID,
+		Baggage: make(map[string]string),
+	}
+	switch strings.ToLower(sampled) {
+	case "1", "true", "d":
+		sc.Sampled = true
+	case "0", "false":
+		sc.Sampled = false
+	case "":
+		// Debug flag or deferred sampling decision
+		sc.Sampled = flags == "1" || p.tracer.sampleDeferred(tID)
+	default:
+		return SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	return sc, nil
+}
@@ -97,3 +97,132 @@ func TestSpanPropagator(t *testing.T) {
 		}
 	}
 }
+
+func TestHTTPPropagationFormats(t *testing.T) {
+	recorder := tracer.NewInMemoryRecorder()
+	opts := tracer.DefaultOptions()
+	opts.Recorder = recorder
+	opts.Propagation = []tracer.PropagationFormat{
+		tracer.PropagationW3C, tracer.PropagationB3, tracer.PropagationB3Multi, tracer.PropagationOpenTracing,
+	}
+	tr := tracer.NewWithOptions(opts)
+
+	sp := tr.StartSpan("parent")
+	sp.SetBaggageItem("foo", "bar baz")
+	sc := sp.Context().(tracer.SpanContext)
+
+	headers := http.Header{}
+	if err := tr.Inject(sp.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers)); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"traceparent", "baggage", "b3", "X-B3-TraceId", "X-B3-SpanId", "X-B3-Sampled", "ot-tracer-traceid"} {
+		if headers.Get(key) == "" {
+			t.Fatalf("header %s has not been injected: %v", key, headers)
+		}
+	}
+
+	// Each format is extracted on its own
+	for _, format := range opts.Propagation {
+		formatOpts := tracer.DefaultOptions()
+		formatOpts.Propagation = []tracer.PropagationFormat{format}
+		formatTracer := tracer.NewWithOptions(formatOpts)
+		extracted, err := formatTracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		esc := extracted.(tracer.SpanContext)
+		if esc.TraceID != sc.TraceID || esc.SpanID != sc.SpanID || esc.Sampled != sc.Sampled {
+			t.Fatalf("%s: expected %v, got %v", format, sc, esc)
+		}
+	}
+	sp.Finish()
+}
+
+func TestW3CTraceContext(t *testing.T) {
+	opts := tracer.DefaultOptions()
+	opts.Recorder = tracer.NewInMemoryRecorder()
+	opts.Propagation = []tracer.PropagationFormat{tracer.PropagationW3C}
+	tr := tracer.NewWithOptions(opts)
+
+	carrier := opentracing.TextMapCarrier{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		"tracestate":  "congo=t61rcWkgMzE",
+		"baggage":     "userId=alice,serverNode=DF%2028;prop",
+	}
+	extracted, err := tr.Extract(opentracing.TextMap, carrier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := extracted.(tracer.SpanContext)
+	if tracer.UUIDToString(sc.TraceID) != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID != 0x00f067aa0ba902b7 || sc.Sampled {
+		t.Fatalf("unexpected span context: %v", sc)
+	}
+	if sc.Baggage["userId"] != "alice" || sc.Baggage["serverNode"] != "DF 28" {
+		t.Fatalf("unexpected baggage: %v", sc.Baggage)
+	}
+
+	// The tracestate is kept in the child spans
+	child := tr.StartSpan("child", opentracing.ChildOf(extracted))
+	out := opentracing.TextMapCarrier{}
+	if err := tr.Inject(child.Context(), opentracing.TextMap, out); err != nil {
+		t.Fatal(err)
+	}
+	if out["tracestate"] != "congo=t61rcWkgMzE" {
+		t.Fatalf("unexpected tracestate: %v", out)
+	}
+	child.Finish()
+
+	for _, invalid := range []string{
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	} {
+		if _, err := tr.Extract(opentracing.TextMap, opentracing.TextMapCarrier{"traceparent": invalid}); err != opentracing.ErrSpanContextCorrupted {
+			t.Fatalf("%s: expected a corrupted span context, got %v", invalid, err)
+		}
+	}
+	if _, err := tr.Extract(opentracing.TextMap, opentracing.TextMapCarrier{}); err != opentracing.ErrSpanContextNotFound {
+		t.Fatalf("expected span context not found, got %v", err)
+	}
+}
+
+func TestB3SingleHeader(t *testing.T) {
+	opts := tracer.DefaultOptions()
+	opts.Propagation = []tracer.PropagationFormat{tracer.PropagationB3}
+	tr := tracer.NewWithOptions(opts)
+
+	extracted, err := tr.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+		"b3": "a3ce929d0e0e4736-00f067aa0ba902b7-d-5b4185666d50f68b",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := extracted.(tracer.SpanContext)
+	if tracer.UUIDToString(sc.TraceID) != "0000000000000000a3ce929d0e0e4736" || sc.SpanID != 0x00f067aa0ba902b7 || !sc.Sampled {
+		t.Fatalf("unexpected span context: %v", sc)
+	}
+	if _, err := tr.Extract(opentracing.TextMap, opentracing.TextMapCarrier{"b3": "0"}); err != opentracing.ErrSpanContextNotFound {
+		t.Fatalf("expected span context not found, got %v", err)
+	}
+}
+
+func TestB3DeferredSampling(t *testing.T) {
+	carrier := opentracing.TextMapCarrier{"b3": "a3ce929d0e0e4736-00f067aa0ba902b7"}
+	for _, sampled := range []bool{true, false} {
+		opts := tracer.DefaultOptions()
+		opts.Propagation = []tracer.PropagationFormat{tracer.PropagationB3}
+		value := sampled
+		opts.Sampler = tracer.SamplerFunc(func(params tracer.SamplingParameters) bool {
+			return value
+		})
+		tr := tracer.NewWithOptions(opts)
+		extracted, err := tr.Extract(opentracing.TextMap, carrier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sc := extracted.(tracer.SpanContext); sc.Sampled != sampled {
+			t.Fatalf("the deferred sampling decision must be taken by the sampler, expected %v", sampled)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package tracer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// W3C Trace Context propagator (https://www.w3.org/TR/trace-context/)
+type w3cPropagator struct {
+	tracer *tracerImpl
+}
+
+const (
+	fieldNameTraceParent = "traceparent"
+	fieldNameTraceState  = "tracestate"
+	fieldNameW3CBaggage  = "baggage"
+
+	traceParentVersion = "00"
+	traceFlagSampled   = 0x01
+)
+
+func (p *w3cPropagator) Inject(
+	spanContext opentracing.SpanContext,
+	opaqueCarrier interface{},
+) error {
+	sc, ok := spanContext.(SpanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	carrier, ok := opaqueCarrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	var flags byte
+	if sc.Sampled {
+		flags |= traceFlagSampled
+	}
+	carrier.Set(fieldNameTraceParent, fmt.Sprintf("%s-%s-%016x-%02x", traceParentVersion, UUIDToString(sc.TraceID), sc.SpanID, flags))
+	if sc.TraceState != "" {
+		carrier.Set(fieldNameTraceState, sc.TraceState)
+	}
+	if len(sc.Baggage) > 0 {
+		items := make([]string, 0, len(sc.Baggage))
+		for k, v := range sc.Baggage {
+			items = append(items, url.PathEscape(k)+"="+url.PathEscape(v))
+		}
+		carrier.Set(fieldNameW3CBaggage, strings.Join(items, ","))
+	}
+	return nil
+}
+
+func (p *w3cPropagator) Extract(
+	opaqueCarrier interface{},
+) (opentracing.SpanContext, error) {
+	carrier, ok := opaqueCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	var traceParent string
+	var traceState []string
+	var baggage []string
+	err := carrier.ForeachKey(func(k, v string) error {
+		switch strings.ToLower(k) {
+		case fieldNameTraceParent:
+			traceParent = v
+		case fieldNameTraceState:
+			traceState = append(traceState, v)
+		case fieldNameW3CBaggage:
+			baggage = append(baggage, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if traceParent == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	traceID, spanID, flags, err := parseTraceParent(traceParent)
+	if err != nil {
+		return nil, err
+	}
+	sc := SpanContext{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		Sampled:    flags&traceFlagSampled == traceFlagSampled,
+		Baggage:    make(map[string]string),
+		TraceState: strings.Join(traceState, ","),
+	}
+	for _, header := range baggage {
+		for _, item := range strings.Split(header, ",") {
+			// Baggage item properties (`;` separated) are not supported
+			if idx := strings.IndexByte(item, ';'); idx >= 0 {
+				item = item[:idx]
+			}
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			key, kErr := url.PathUnescape(strings.TrimSpace(kv[0]))
+			value, vErr := url.PathUnescape(strings.TrimSpace(kv[1]))
+			if kErr != nil || vErr != nil || key == "" {
+				continue
+			}
+			sc.Baggage[key] = value
+		}
+	}
+	return sc, nil
+}
+
+// Parses a `traceparent` header with format `{version}-{trace-id}-{parent-id}-{trace-flags}`
+func parseTraceParent(value string) (uuid.UUID, uint64, byte, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return uuid.UUID{}, 0, 0, opentracing.ErrSpanContextCorrupted
+	}
+	version, err := hex.DecodeString(parts[0])
+	// Version 255 is invalid, and the version 00 doesn't allow more fields
+	if err != nil || version[0] == 0xff || (parts[0] == traceParentVersion && len(parts) != 4) {
+		return uuid.UUID{}, 0, 0, opentracing.ErrSpanContextCorrupted
+	}
+	traceID, err := StringToUUID(strings.ToLower(parts[1]))
+	if err != nil || traceID == (uuid.UUID{}) {
+		return uuid.UUID{}, 0, 0, opentracing.ErrSpanContextCorrupted
+	}
+	spanID, err := strconv.ParseUint(parts[2], 16, 64)
+	if err != nil || spanID == 0 {
+		return uuid.UUID{}, 0, 0, opentracing.ErrSpanContextCorrupted
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return uuid.UUID{}, 0, 0, opentracing.ErrSpanContextCorrupted
+	}
+	return traceID, spanID, flags[0], nil
+}
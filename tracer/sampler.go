@@ -0,0 +1,167 @@
+package tracer
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+)
+
+type (
+	// Data available to decide if a new span is sampled
+	SamplingParameters struct {
+		TraceID   uuid.UUID
+		Operation string
+		Tags      opentracing.Tags
+		// Context of the parent span (local or extracted from a carrier), nil for root spans
+		Parent *SpanContext
+	}
+
+	// Sampler decides if a new span is sampled
+	Sampler interface {
+		ShouldSample(params SamplingParameters) bool
+	}
+
+	// Func adapter to use ordinary functions as samplers
+	SamplerFunc func(params SamplingParameters) bool
+
+	probabilisticSampler struct {
+		boundary uint64
+		always   bool
+	}
+
+	rateLimitingSampler struct {
+		rate    float64
+		mu      sync.Mutex
+		buckets map[string]*tokenBucket
+		now     func() time.Time
+	}
+
+	tokenBucket struct {
+		tokens     float64
+		lastUpdate time.Time
+	}
+
+	testSampler struct {
+		delegate Sampler
+	}
+
+	parentBasedSampler struct {
+		root Sampler
+	}
+
+	andSampler struct {
+		samplers []Sampler
+	}
+)
+
+// Max number of operations with their own rate limit, the rest share a single bucket
+const maxRateLimitedOperations = 1000
+
+func (f SamplerFunc) ShouldSample(params SamplingParameters) bool {
+	return f(params)
+}
+
+// Creates a sampler that samples all the spans
+func NewAlwaysSampler() Sampler {
+	return SamplerFunc(func(SamplingParameters) bool { return true })
+}
+
+// Creates a sampler that samples a ratio (0 to 1) of the traces.
+// The decision is made from the trace id, so all the agents sample the same traces.
+func NewProbabilisticSampler(rate float64) Sampler {
+	if rate >= 1 {
+		return &probabilisticSampler{always: true}
+	}
+	if rate <= 0 {
+		return &probabilisticSampler{}
+	}
+	return &probabilisticSampler{boundary: uint64(rate * math.MaxUint64)}
+}
+
+func (s *probabilisticSampler) ShouldSample(params SamplingParameters) bool {
+	if s.always {
+		return true
+	}
+	// The first 2 bits of the 9th byte are the uuid variant, so we skip them
+	return binary.BigEndian.Uint64(params.TraceID[8:])<<2 < s.boundary
+}
+
+// Creates a sampler that samples up to the given number of spans per second of each operation
+func NewRateLimitingSampler(spansPerSecond float64) Sampler {
+	return &rateLimitingSampler{
+		rate:    spansPerSecond,
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *rateLimitingSampler) ShouldSample(params SamplingParameters) bool {
+	if s.rate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	key := params.Operation
+	bucket, ok := s.buckets[key]
+	if !ok && len(s.buckets) >= maxRateLimitedOperations {
+		key = ""
+		bucket, ok = s.buckets[key]
+	}
+	if !ok {
+		bucket = &tokenBucket{tokens: math.Max(1, s.rate), lastUpdate: now}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(math.Max(1, s.rate), bucket.tokens+now.Sub(bucket.lastUpdate).Seconds()*s.rate)
+	bucket.lastUpdate = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// Creates a sampler that always samples the test spans and its children, the rest of spans are sampled by the delegate
+func NewTestSampler(delegate Sampler) Sampler {
+	return &testSampler{delegate: delegate}
+}
+
+func (s *testSampler) ShouldSample(params SamplingParameters) bool {
+	if params.Tags["span.kind"] == "test" {
+		return true
+	}
+	if params.Parent != nil && params.Parent.Baggage["trace.kind"] == "test" {
+		return true
+	}
+	return s.delegate.ShouldSample(params)
+}
+
+// Creates a sampler that respects the parent span decision, the root spans are sampled by the root sampler
+func NewParentBasedSampler(root Sampler) Sampler {
+	return &parentBasedSampler{root: root}
+}
+
+func (s *parentBasedSampler) ShouldSample(params SamplingParameters) bool {
+	if params.Parent != nil {
+		return params.Parent.Sampled
+	}
+	return s.root.ShouldSample(params)
+}
+
+// Creates a sampler that samples a span only if all the samplers agree
+func NewAndSampler(samplers ...Sampler) Sampler {
+	return &andSampler{samplers: samplers}
+}
+
+func (s *andSampler) ShouldSample(params SamplingParameters) bool {
+	for _, sampler := range s.samplers {
+		if !sampler.ShouldSample(params) {
+			return false
+		}
+	}
+	return true
+}
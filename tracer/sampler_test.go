@@ -0,0 +1,74 @@
+package tracer_test
+
+import (
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+
+	"go.undefinedlabs.com/scopeagent/tracer"
+)
+
+func TestProbabilisticSampler(t *testing.T) {
+	recorder := tracer.NewInMemoryRecorder()
+	opts := tracer.DefaultOptions()
+	opts.Recorder = recorder
+	opts.Sampler = tracer.NewParentBasedSampler(tracer.NewProbabilisticSampler(0.25))
+	tr := tracer.NewWithOptions(opts)
+
+	const traces = 4000
+	for i := 0; i < traces; i++ {
+		root := tr.StartSpan("root")
+		child := tr.StartSpan("child", opentracing.ChildOf(root.Context()))
+		child.Finish()
+		root.Finish()
+	}
+	spans := recorder.GetSpans()
+	sampled := recorder.GetSampledSpans()
+	if ratio := float64(len(sampled)) / float64(len(spans)); ratio < 0.2 || ratio > 0.3 {
+		t.Fatalf("unexpected sampling ratio: %v", ratio)
+	}
+	// The children keep the root decision
+	decisions := map[uint64]bool{}
+	for _, span := range spans {
+		if span.ParentSpanID == 0 {
+			decisions[span.Context.SpanID] = span.Context.Sampled
+		}
+	}
+	for _, span := range spans {
+		if span.ParentSpanID != 0 && decisions[span.ParentSpanID] != span.Context.Sampled {
+			t.Fatal("the child span doesn't follow the root span decision")
+		}
+	}
+}
+
+func TestRateLimitingSampler(t *testing.T) {
+	sampler := tracer.NewRateLimitingSampler(2)
+	sampledByOperation := map[string]int{}
+	for i := 0; i < 10; i++ {
+		for _, operation := range []string{"a", "b"} {
+			if sampler.ShouldSample(tracer.SamplingParameters{Operation: operation}) {
+				sampledByOperation[operation]++
+			}
+		}
+	}
+	if sampledByOperation["a"] != 2 || sampledByOperation["b"] != 2 {
+		t.Fatalf("unexpected sampled spans: %v", sampledByOperation)
+	}
+}
+
+func TestTestSampler(t *testing.T) {
+	opts := tracer.DefaultOptions()
+	opts.Recorder = tracer.NewInMemoryRecorder()
+	opts.Sampler = tracer.NewParentBasedSampler(tracer.NewTestSampler(tracer.NewProbabilisticSampler(0)))
+	tr := tracer.NewWithOptions(opts)
+
+	test := tr.StartSpan("TestSomething", opentracing.Tags{"span.kind": "test"})
+	child := tr.StartSpan("HTTP GET", opentracing.ChildOf(test.Context()))
+	other := tr.StartSpan("HTTP GET")
+	if !test.Context().(tracer.SpanContext).Sampled || !child.Context().(tracer.SpanContext).Sampled {
+		t.Fatal("the test trace is not sampled")
+	}
+	if other.Context().(tracer.SpanContext).Sampled {
+		t.Fatal("the span has been sampled")
+	}
+}
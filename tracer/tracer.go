@@ -30,6 +30,10 @@ type Options struct {
 	//   func(traceID uuid.UUID) { return true }
 	//
 	ShouldSample func(traceID uuid.UUID) bool
+	// Sampler, if set, replaces ShouldSample and is called for every new Span
+	// (not only the root ones) with the operation, tags and parent context, so
+	// it's responsible of keeping the parent decision (see NewParentBasedSampler).
+	Sampler Sampler
 	// TrimUnsampledSpans turns potentially expensive operations on unsampled
 	// Spans into no-ops. More precisely, tags and log events are silently
 	// discarded. If NewSpanEventListener is set, the callbacks will still fire.
@@ -95,6 +99,11 @@ type Options struct {
 	EnableSpanPool bool
 	// Func to call when a panic has been detected when a span is finalizing
 	OnSpanFinishPanic func(rSpan *RawSpan, err **errors.Error)
+	// Propagation sets the formats used to inject and extract the span context
+	// with the TextMap and HTTPHeaders carriers. All the formats are injected,
+	// and on extraction they are tried in order. If empty, only the OpenTracing
+	// format (`ot-tracer-*` keys) is used.
+	Propagation []PropagationFormat
 }
 
 // DefaultOptions returns an Options object with a 1 in 64 sampling rate and
@@ -111,6 +120,7 @@ func DefaultOptions() Options {
 func NewWithOptions(opts Options) opentracing.Tracer {
 	rval := &tracerImpl{options: opts}
 	rval.textPropagator = &textMapPropagator{rval}
+	rval.httpPropagator = newHTTPPropagator(rval, opts.Propagation)
 	rval.binaryPropagator = &binaryPropagator{rval}
 	rval.accessorPropagator = &accessorPropagator{rval}
 	rval.envVarPropagator = &envVarPropagator{rval}
@@ -131,6 +141,7 @@ func New(recorder SpanRecorder) opentracing.Tracer {
 type tracerImpl struct {
 	options            Options
 	textPropagator     *textMapPropagator
+	httpPropagator     propagator
 	binaryPropagator   *binaryPropagator
 	accessorPropagator *accessorPropagator
 	envVarPropagator   *envVarPropagator
@@ -172,6 +183,7 @@ func (t *tracerImpl) StartSpanWithOptions(
 	// Build the new span. This is the only allocation: We'll return this as
 	// an opentracing.Span.
 	sp := t.getSpan()
+	var parent *SpanContext
 	// Look for a parent in the list of References.
 	//
 	// TODO: would be nice if basictracer did something with all
@@ -183,9 +195,11 @@ ReferencesLoop:
 			opentracing.FollowsFromRef:
 
 			refCtx := ref.ReferencedContext.(SpanContext)
+			parent = &refCtx
 			sp.raw.Context.TraceID = refCtx.TraceID
 			sp.raw.Context.SpanID = getRandomId()
 			sp.raw.Context.Sampled = refCtx.Sampled
+			sp.raw.Context.TraceState = refCtx.TraceState
 			sp.raw.ParentSpanID = refCtx.SpanID
 
 			if l := len(refCtx.Baggage); l > 0 {
@@ -202,7 +216,22 @@ ReferencesLoop:
 		// the Sampled status.
 		sp.raw.Context.TraceID = uuid.New()
 		sp.raw.Context.SpanID = getRandomId()
-		sp.raw.Context.Sampled = t.options.ShouldSample(sp.raw.Context.TraceID)
+		if t.options.Sampler != nil {
+			sp.raw.Context.Sampled = t.options.Sampler.ShouldSample(SamplingParameters{
+				TraceID:   sp.raw.Context.TraceID,
+				Operation: operationName,
+				Tags:      tags,
+			})
+		} else {
+			sp.raw.Context.Sampled = t.options.ShouldSample(sp.raw.Context.TraceID)
+		}
+	} else if t.options.Sampler != nil {
+		sp.raw.Context.Sampled = t.options.Sampler.ShouldSample(SamplingParameters{
+			TraceID:   sp.raw.Context.TraceID,
+			Operation: operationName,
+			Tags:      tags,
+			Parent:    parent,
+		})
 	}
 
 	return t.startSpanInternal(
@@ -213,6 +242,15 @@ ReferencesLoop:
 	)
 }
 
+// Gets the sampling decision of an extracted trace without it (ex: B3 deferred sampling), the decision
+// is taken as for a root span
+func (t *tracerImpl) sampleDeferred(traceID uuid.UUID) bool {
+	if t.options.Sampler != nil {
+		return t.options.Sampler.ShouldSample(SamplingParameters{TraceID: traceID})
+	}
+	return t.options.ShouldSample == nil || t.options.ShouldSample(traceID)
+}
+
 func (t *tracerImpl) startSpanInternal(
 	sp *spanImpl,
 	operationName string,
@@ -242,7 +280,7 @@ var Delegator delegatorType
 func (t *tracerImpl) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
 	switch format {
 	case opentracing.TextMap, opentracing.HTTPHeaders:
-		return t.textPropagator.Inject(sc, carrier)
+		return t.httpPropagator.Inject(sc, carrier)
 	case opentracing.Binary:
 		return t.binaryPropagator.Inject(sc, carrier)
 	case EnvironmentVariableFormat:
@@ -257,7 +295,7 @@ func (t *tracerImpl) Inject(sc opentracing.SpanContext, format interface{}, carr
 func (t *tracerImpl) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
 	switch format {
 	case opentracing.TextMap, opentracing.HTTPHeaders:
-		return t.textPropagator.Extract(carrier)
+		return t.httpPropagator.Extract(carrier)
 	case opentracing.Binary:
 		return t.binaryPropagator.Extract(carrier)
 	case EnvironmentVariableFormat: